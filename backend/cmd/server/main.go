@@ -1,22 +1,29 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"real-time-forum/internal/database"
 	"real-time-forum/internal/handlers"
+	"real-time-forum/internal/mail"
 	"real-time-forum/internal/middleware"
 	"real-time-forum/internal/websocket"
 )
 
 func main() {
+	// Schema management: server migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	fmt.Println("🚀 Starting Real-Time Forum Server...")
 
 	// Initialize database
@@ -26,23 +33,46 @@ func main() {
 	}
 	defer db.Close()
 
+	// Storage layer shared by the handlers
+	store := database.NewSQLStore(db)
+
+	// Outgoing email (verification and password reset links)
+	mailer := mail.FromEnv()
+
 	// Create handlers and middleware
-	authHandler := handlers.NewAuthHandler(db)
-	authMiddleware := middleware.NewAuthMiddleware(db)
-	postsHandler := handlers.NewPostsHandler(db, authMiddleware)
-	commentsHandler := handlers.NewCommentsHandler(db, authMiddleware)
-	votesHandler := handlers.NewVotesHandler(db, authMiddleware)
+	authMiddleware := middleware.NewAuthMiddleware(store, store)
+	authMiddleware.SetRequireVerifiedEmail(requireEmailVerification())
+	verificationHandler := handlers.NewVerificationHandler(store, mailer, authMiddleware, getBaseURL())
+	authHandler := handlers.NewAuthHandler(store, store, authMiddleware, verificationHandler)
 
 	// Create WebSocket hub
 	hub := websocket.NewHub()
+	presenceHandler := handlers.NewPresenceHandler(store, hub, authMiddleware)
+	if err := presenceHandler.ResetPresence(); err != nil {
+		log.Printf("⚠️ Error resetting presence: %v", err)
+	}
+	hub.SetPresenceStore(presenceHandler)
 	go hub.Run() // Start hub in a goroutine
 	log.Println("🔌 WebSocket hub initialized")
 
+	// Create content handlers (these publish live updates through the hub)
+	postsHandler := handlers.NewPostsHandler(store, hub, authMiddleware)
+	commentsHandler := handlers.NewCommentsHandler(store, hub, authMiddleware)
+	votesHandler := handlers.NewVotesHandler(store, hub, authMiddleware)
+	searchHandler := handlers.NewSearchHandler(store)
+	revisionsHandler := handlers.NewRevisionsHandler(store, authMiddleware)
+	sessionsHandler := handlers.NewSessionsHandler(store, hub, authMiddleware)
+	passwordHandler := handlers.NewPasswordHandler(store, mailer, hub, authMiddleware, getBaseURL())
+
 	// Create messages handler
-	messagesHandler := handlers.NewMessagesHandler(db, hub, authMiddleware)
+	messagesHandler := handlers.NewMessagesHandler(store, hub, authMiddleware)
+	messagesHandler.RegisterCommands(hub.Router)
+	hub.SetTypingGuard(messagesHandler)
+	roomsHandler := handlers.NewRoomsHandler(store, hub, authMiddleware)
+	roomsHandler.RegisterCommands(hub.Router)
 
 	// Set up routes
-	setupRoutes(authHandler, authMiddleware, postsHandler, commentsHandler, votesHandler, hub, messagesHandler)
+	setupRoutes(authHandler, authMiddleware, sessionsHandler, passwordHandler, verificationHandler, postsHandler, commentsHandler, votesHandler, searchHandler, revisionsHandler, hub, messagesHandler, presenceHandler, roomsHandler)
 
 	// Start cleanup routine
 	go startSessionCleanup(authMiddleware)
@@ -55,15 +85,29 @@ func main() {
 	fmt.Println("   - GET  /register, POST /register")
 	fmt.Println("   - GET  /login, POST /login")
 	fmt.Println("   - GET  /logout")
+	fmt.Println("   - GET  /api/sessions, DELETE /api/sessions?id=X")
+	fmt.Println("   - POST /api/sessions/revoke-others")
+	fmt.Println("   - POST /api/password/forgot, POST /api/password/reset")
+	fmt.Println("   - POST /api/email/verify, POST /api/email/resend")
 	fmt.Println("   - GET  /posts (list posts)")
 	fmt.Println("   - GET  /posts/create, POST /posts/create")
 	fmt.Println("   - GET  /posts/view?id=X")
 	fmt.Println("   - POST /comments/create")
-	fmt.Println("   - POST /vote")
+	fmt.Println("   - GET  /comments/replies")
+	fmt.Println("   - PUT, DELETE /api/posts?id=X, /api/comments?id=X")
+	fmt.Println("   - GET  /api/revisions?type=post|comment&id=X")
+	fmt.Println("   - POST /vote, POST /api/votes")
+	fmt.Println("   - GET  /api/search?q=X")
 	fmt.Println("   - WS   /ws (WebSocket connection)")
 	fmt.Println("   - POST /api/messages/send")
 	fmt.Println("   - GET  /api/messages/history")
+	fmt.Println("   - POST /api/messages/read")
+	fmt.Println("   - GET  /api/conversations")
 	fmt.Println("   - GET  /api/online-users")
+	fmt.Println("   - GET  /api/users/presence")
+	fmt.Println("   - GET  /api/rooms, POST /api/rooms/create")
+	fmt.Println("   - POST /api/rooms/members/add, POST /api/rooms/members/remove")
+	fmt.Println("   - GET  /api/rooms/messages, POST /api/rooms/messages/send")
 
 	// Graceful shutdown
 	setupGracefulShutdown(db)
@@ -73,8 +117,11 @@ func main() {
 }
 
 func setupRoutes(authHandler *handlers.AuthHandler, authMiddleware *middleware.AuthMiddleware,
+	sessionsHandler *handlers.SessionsHandler, passwordHandler *handlers.PasswordHandler, verificationHandler *handlers.VerificationHandler,
 	postsHandler *handlers.PostsHandler, commentsHandler *handlers.CommentsHandler,
-	votesHandler *handlers.VotesHandler, hub *websocket.Hub, messagesHandler *handlers.MessagesHandler) {
+	votesHandler *handlers.VotesHandler, searchHandler *handlers.SearchHandler,
+	revisionsHandler *handlers.RevisionsHandler, hub *websocket.Hub, messagesHandler *handlers.MessagesHandler,
+	presenceHandler *handlers.PresenceHandler, roomsHandler *handlers.RoomsHandler) {
 
 	// Home page
 	http.HandleFunc("/", logRequest(homeHandler(authMiddleware)))
@@ -83,28 +130,55 @@ func setupRoutes(authHandler *handlers.AuthHandler, authMiddleware *middleware.A
 	http.HandleFunc("/register", logRequest(authHandler.RegisterHandler))
 	http.HandleFunc("/login", logRequest(authHandler.LoginHandler))
 	http.HandleFunc("/logout", logRequest(authHandler.LogoutHandler))
+	http.HandleFunc("/api/sessions", logRequest(authMiddleware.RequireAuth(sessionsHandler.Sessions)))
+	http.HandleFunc("/api/sessions/revoke-others", logRequest(authMiddleware.RequireAuth(sessionsHandler.RevokeOthers)))
+	http.HandleFunc("/api/password/forgot", logRequest(passwordHandler.ForgotPassword))
+	http.HandleFunc("/api/password/reset", logRequest(passwordHandler.ResetPassword))
+	http.HandleFunc("/api/email/verify", logRequest(verificationHandler.VerifyEmail))
+	http.HandleFunc("/api/email/resend", logRequest(authMiddleware.RequireAuth(verificationHandler.ResendVerification)))
 
 	// Posts routes
-	http.HandleFunc("/posts", logRequest(postsHandler.ListPostsHandler))
-	http.HandleFunc("/posts/create", logRequest(authMiddleware.RequireAuth(postsHandler.CreatePostHandler)))
-	http.HandleFunc("/posts/view", logRequest(postsHandler.ViewPostHandler))
+	http.HandleFunc("/posts", logRequest(authMiddleware.AddUserToContext(postsHandler.ListPostsHandler)))
+	http.HandleFunc("/posts/create", logRequest(authMiddleware.RequireVerifiedEmail(postsHandler.CreatePostHandler)))
+	http.HandleFunc("/posts/view", logRequest(authMiddleware.AddUserToContext(postsHandler.ViewPostHandler)))
 
 	// Comments routes
-	http.HandleFunc("/comments/create", logRequest(authMiddleware.RequireAuth(commentsHandler.CreateCommentHandler)))
+	http.HandleFunc("/comments/create", logRequest(authMiddleware.RequireVerifiedEmail(commentsHandler.CreateCommentHandler)))
+	http.HandleFunc("/comments/replies", logRequest(authMiddleware.AddUserToContext(postsHandler.RepliesHandler)))
+
+	// Editing routes
+	http.HandleFunc("/api/posts", logRequest(authMiddleware.RequireAuth(postsHandler.EditPostHandler)))
+	http.HandleFunc("/api/comments", logRequest(authMiddleware.RequireAuth(commentsHandler.EditCommentHandler)))
+	http.HandleFunc("/api/revisions", logRequest(authMiddleware.AddUserToContext(revisionsHandler.ListRevisions)))
 
 	// Voting routes
 	http.HandleFunc("/vote", logRequest(authMiddleware.RequireAuth(votesHandler.VoteHandler)))
+	http.HandleFunc("/api/votes", logRequest(authMiddleware.RequireAuth(votesHandler.VoteAPIHandler)))
+
+	// Search route
+	http.HandleFunc("/api/search", logRequest(searchHandler.Search))
 
 	// Message API routes
-	http.HandleFunc("/api/messages/send", logRequest(authMiddleware.RequireAuth(messagesHandler.SendMessage)))
+	http.HandleFunc("/api/messages/send", logRequest(authMiddleware.RequireVerifiedEmail(messagesHandler.SendMessage)))
 	http.HandleFunc("/api/messages/history", logRequest(authMiddleware.RequireAuth(messagesHandler.GetMessageHistory)))
+	http.HandleFunc("/api/messages/read", logRequest(authMiddleware.RequireAuth(messagesHandler.MarkRead)))
+	http.HandleFunc("/api/conversations", logRequest(authMiddleware.RequireAuth(messagesHandler.GetConversations)))
 	http.HandleFunc("/api/online-users", logRequest(authMiddleware.RequireAuth(messagesHandler.GetOnlineUsers)))
+	http.HandleFunc("/api/users/presence", logRequest(authMiddleware.RequireAuth(presenceHandler.GetPresence)))
 	log.Println("💬 Message API endpoints registered")
 
+	// Chat room API routes
+	http.HandleFunc("/api/rooms", logRequest(authMiddleware.RequireAuth(roomsHandler.ListRooms)))
+	http.HandleFunc("/api/rooms/create", logRequest(authMiddleware.RequireVerifiedEmail(roomsHandler.CreateRoom)))
+	http.HandleFunc("/api/rooms/members/add", logRequest(authMiddleware.RequireAuth(roomsHandler.AddMember)))
+	http.HandleFunc("/api/rooms/members/remove", logRequest(authMiddleware.RequireAuth(roomsHandler.RemoveMember)))
+	http.HandleFunc("/api/rooms/messages", logRequest(authMiddleware.RequireAuth(roomsHandler.GetRoomHistory)))
+	http.HandleFunc("/api/rooms/messages/send", logRequest(authMiddleware.RequireVerifiedEmail(roomsHandler.SendRoomMessage)))
+
 	// WebSocket endpoint
 	http.HandleFunc("/ws", logRequest(func(w http.ResponseWriter, r *http.Request) {
-		websocket.HandleWebSocket(hub, func(req *http.Request) (int, error) {
-			return getUserIDFromRequest(req, authMiddleware)
+		websocket.HandleWebSocket(hub, func(req *http.Request) (int, int, error) {
+			return getSessionFromRequest(req, authMiddleware)
 		})(w, r)
 	}))
 	log.Println("🔌 WebSocket endpoint registered: /ws")
@@ -151,6 +225,21 @@ func getPort() string {
 	return port
 }
 
+// requireEmailVerification reports whether users must verify their email
+// before posting or messaging. On unless REQUIRE_EMAIL_VERIFICATION is false.
+func requireEmailVerification() bool {
+	required, err := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+	return err != nil || required
+}
+
+// getBaseURL returns the public address of the site for links in emails
+func getBaseURL() string {
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "http://localhost" + getPort()
+}
+
 func startSessionCleanup(authMiddleware *middleware.AuthMiddleware) {
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
@@ -165,7 +254,7 @@ func startSessionCleanup(authMiddleware *middleware.AuthMiddleware) {
 	}
 }
 
-func setupGracefulShutdown(db *sql.DB) {
+func setupGracefulShutdown(db *database.Conn) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
@@ -181,12 +270,12 @@ func setupGracefulShutdown(db *sql.DB) {
 	}()
 }
 
-// getUserIDFromRequest extracts user ID from session cookie
-// This is needed for WebSocket authentication
-func getUserIDFromRequest(r *http.Request, authMiddleware *middleware.AuthMiddleware) (int, error) {
-	user := authMiddleware.GetCurrentUser(r)
-	if user == nil {
-		return 0, fmt.Errorf("user not authenticated")
+// getSessionFromRequest extracts the user and session IDs from the session cookie
+// This is needed for WebSocket authentication, and so revoking a session can close its connections
+func getSessionFromRequest(r *http.Request, authMiddleware *middleware.AuthMiddleware) (int, int, error) {
+	session := authMiddleware.GetCurrentSession(r)
+	if session == nil {
+		return 0, 0, fmt.Errorf("user not authenticated")
 	}
-	return user.ID, nil
+	return session.UserID, session.ID, nil
 }
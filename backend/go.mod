@@ -7,3 +7,5 @@ require github.com/mattn/go-sqlite3 v1.14.29
 require golang.org/x/crypto v0.40.0
 
 require github.com/gorilla/websocket v1.5.3
+
+require github.com/lib/pq v1.10.9
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour of the connected database
+type Dialect string
+
+// Supported dialects, named after their database/sql drivers
+const (
+	SQLite   Dialect = "sqlite3"
+	Postgres Dialect = "postgres"
+)
+
+// postgresTypes maps SQLite column definitions to their PostgreSQL equivalents
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY",
+	"DATETIME", "TIMESTAMPTZ",
+	"BOOLEAN DEFAULT 0", "BOOLEAN DEFAULT FALSE",
+	"BOOLEAN DEFAULT 1", "BOOLEAN DEFAULT TRUE",
+)
+
+// Rewrite adapts a query written for SQLite to the dialect.
+// Queries use ? placeholders; PostgreSQL gets $1, $2, ... and its own column types in DDL.
+// A ? inside a quoted string or identifier is left as it is.
+func (d Dialect) Rewrite(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	trimmed := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(trimmed, "CREATE TABLE") || strings.HasPrefix(trimmed, "ALTER TABLE") {
+		query = postgresTypes.Replace(query)
+	}
+
+	var b strings.Builder
+	n := 0
+	var quote rune // the open quote character, or 0 outside quotes
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			// A doubled quote closes and reopens, which leaves it open as intended
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Conn is a database connection that rewrites queries for its dialect.
+// All queries in this codebase are written for SQLite with ? placeholders.
+type Conn struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Exec runs a statement rewritten for the connection's dialect
+func (c *Conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(c.Dialect.Rewrite(query), args...)
+}
+
+// Query runs a query rewritten for the connection's dialect
+func (c *Conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(c.Dialect.Rewrite(query), args...)
+}
+
+// QueryRow runs a single-row query rewritten for the connection's dialect
+func (c *Conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(c.Dialect.Rewrite(query), args...)
+}
+
+// Begin starts a transaction that rewrites queries the same way
+func (c *Conn) Begin() (*Tx, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: c.Dialect}, nil
+}
+
+// Insert runs an INSERT and returns the new row's id column
+func (c *Conn) Insert(query string, args ...interface{}) (int, error) {
+	return insertID(c, c.Dialect, query, args...)
+}
+
+// Tx is a transaction that rewrites queries for its dialect
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+// Exec runs a statement rewritten for the transaction's dialect
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.Dialect.Rewrite(query), args...)
+}
+
+// Query runs a query rewritten for the transaction's dialect
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.Dialect.Rewrite(query), args...)
+}
+
+// QueryRow runs a single-row query rewritten for the transaction's dialect
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.Dialect.Rewrite(query), args...)
+}
+
+// Insert runs an INSERT and returns the new row's id column
+func (tx *Tx) Insert(query string, args ...interface{}) (int, error) {
+	return insertID(tx, tx.Dialect, query, args...)
+}
+
+// insertID uses RETURNING on PostgreSQL, whose driver has no LastInsertId
+func insertID(q dbtx, d Dialect, query string, args ...interface{}) (int, error) {
+	if d == Postgres {
+		var id int
+		err := q.QueryRow(strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func TestRewritePlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"none", "SELECT 1", "SELECT 1"},
+		{"numbered in order", "SELECT * FROM posts WHERE id = ? AND user_id = ?", "SELECT * FROM posts WHERE id = $1 AND user_id = $2"},
+		{"string literal", "SELECT * FROM posts WHERE title = '?' AND id = ?", "SELECT * FROM posts WHERE title = '?' AND id = $1"},
+		{"escaped quote", "SELECT 'it''s ?' , ?", "SELECT 'it''s ?' , $1"},
+		{"quoted identifier", `SELECT "a?b" FROM t WHERE x = ?`, `SELECT "a?b" FROM t WHERE x = $1`},
+		{"after literal", "UPDATE t SET s = 'x', n = ? WHERE id = ?", "UPDATE t SET s = 'x', n = $1 WHERE id = $2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Postgres.Rewrite(tt.query); got != tt.want {
+				t.Errorf("Rewrite(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+			if got := SQLite.Rewrite(tt.query); got != tt.query {
+				t.Errorf("SQLite rewrote %q to %q", tt.query, got)
+			}
+		})
+	}
+}
+
+func TestRewriteDDL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			"create table",
+			"CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, at DATETIME, done BOOLEAN DEFAULT 0, live BOOLEAN DEFAULT 1)",
+			"CREATE TABLE t (id SERIAL PRIMARY KEY, at TIMESTAMPTZ, done BOOLEAN DEFAULT FALSE, live BOOLEAN DEFAULT TRUE)",
+		},
+		{
+			"alter table",
+			"  alter table t ADD COLUMN seen DATETIME",
+			"  alter table t ADD COLUMN seen TIMESTAMPTZ",
+		},
+		{
+			// Only DDL gets the type mapping; data that happens to match is kept
+			"not ddl",
+			"INSERT INTO notes (body) VALUES ('DATETIME')",
+			"INSERT INTO notes (body) VALUES ('DATETIME')",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Postgres.Rewrite(tt.query); got != tt.want {
+				t.Errorf("Rewrite(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPostgresMigrations runs every migration up, down and up again against the
+// database in POSTGRES_TEST_DSN, which should be an empty throwaway database.
+// It is skipped when the variable is unset.
+func TestPostgresMigrations(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	sqlDB, err := sql.Open(string(Postgres), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if err := sqlDB.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := NewMigrator(&Conn{DB: sqlDB, Dialect: Postgres})
+	t.Cleanup(func() {
+		if _, err := migrator.Down(len(migrations)); err != nil {
+			t.Errorf("cleaning up: %v", err)
+		}
+	})
+
+	if n, err := migrator.Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	} else if n != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d; the database must start empty", n, len(migrations))
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %03d_%s not applied", status.Version, status.Name)
+		}
+	}
+
+	if n, err := migrator.Down(len(migrations)); err != nil {
+		t.Fatalf("down: %v", err)
+	} else if n != len(migrations) {
+		t.Fatalf("rolled back %d migrations, want %d", n, len(migrations))
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+}
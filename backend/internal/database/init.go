@@ -4,47 +4,85 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // DB is the global database connection that other packages can use
-var DB *sql.DB
+var DB *Conn
 
-// Initialize sets up the database connection and creates all required tables
-func Initialize() (*sql.DB, error) {
-	log.Println("📊 Initializing database connection...")
+// Initialize opens the database and applies any pending schema migrations
+func Initialize() (*Conn, error) {
+	db, err := Open()
+	if err != nil {
+		return nil, err
+	}
 
-	// Open database connection to forum.db file
-	db, err := sql.Open("sqlite3", "./forum.db")
+	applied, err := NewMigrator(db).Up()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if applied > 0 {
+		log.Printf("✅ Applied %d migration(s)", applied)
+	}
+
+	log.Println("✅ Database initialized successfully")
+	return db, nil
+}
+
+// DataSource returns the driver and DSN selected by the DATABASE_URL environment variable.
+// postgres:// and postgresql:// URLs select PostgreSQL; anything else is a SQLite file path.
+func DataSource() (Dialect, string) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return Postgres, dsn
+	}
+	if dsn == "" {
+		dsn = "./forum.db"
+	}
+	return SQLite, dsn
+}
+
+// Open sets up the database connection without touching the schema
+func Open() (*Conn, error) {
+	dialect, dsn := DataSource()
+	log.Printf("📊 Initializing %s database connection...", dialect)
+
+	// Open database connection (forum.db file by default)
+	sqlDB, err := sql.Open(string(dialect), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Test that we can actually connect to the database
-	if err := db.Ping(); err != nil {
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Store in global variable so other packages can access it
-	DB = db
+	db := &Conn{DB: sqlDB, Dialect: dialect}
 
-	// Enable foreign key constraints in SQLite
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
-	if err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	// Enable foreign key constraints in SQLite (always on in PostgreSQL)
+	if dialect == SQLite {
+		if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+		}
 	}
 
-	// Create all tables and indexes
-	if err := createTables(db); err != nil {
-		return nil, err
-	}
+	// Store in global variable so other packages can access it
+	DB = db
 
-	log.Println("✅ Database initialized successfully")
 	return db, nil
 }
 
-func createTables(db *sql.DB) error {
+// createTables creates the initial schema: users, sessions, posts, comments,
+// votes and private messages, plus the default categories
+func createTables(db dbtx) error {
 	queries := []string{
 		// Users table
 		`CREATE TABLE IF NOT EXISTS users (
@@ -162,7 +200,7 @@ func createTables(db *sql.DB) error {
 	// Insert default categories if they don't exist
 	categories := []string{"Technology", "Gaming", "Sports", "General"}
 	for _, category := range categories {
-		_, err := db.Exec("INSERT OR IGNORE INTO categories (name) VALUES (?)", category)
+		_, err := db.Exec("INSERT INTO categories (name) VALUES (?) ON CONFLICT (name) DO NOTHING", category)
 		if err != nil {
 			log.Printf("⚠️ Error inserting category %s: %v", category, err)
 		}
@@ -0,0 +1,1421 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store with in-process maps.
+// It is meant for tests and throwaway instances; nothing is persisted.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users         map[int]User
+	sessions      map[string]Session
+	posts         map[int]Post
+	categories    map[int]Category
+	postCats      map[int][]int // post ID -> category IDs
+	comments      map[int]Comment
+	votes         map[memoryVoteKey]int        // -> vote_type
+	messages      []Message                    // ordered by ID
+	revisions     []Revision                   // ordered by ID
+	resets        map[string]PasswordReset     // token hash -> reset
+	verifications map[string]EmailVerification // token hash -> verification
+	rooms         map[int]Room                 // members kept in join order
+	roomMessages  []RoomMessage                // ordered by ID
+	presence      map[int]UserPresence
+
+	nextID map[string]int // per-table ID sequence
+}
+
+// memoryVoteKey identifies one user's vote on a post or comment
+type memoryVoteKey struct {
+	userID     int
+	targetType string
+	targetID   int
+}
+
+// NewMemoryStore creates an empty store seeded with the default categories
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		users:         make(map[int]User),
+		sessions:      make(map[string]Session),
+		posts:         make(map[int]Post),
+		categories:    make(map[int]Category),
+		postCats:      make(map[int][]int),
+		comments:      make(map[int]Comment),
+		votes:         make(map[memoryVoteKey]int),
+		resets:        make(map[string]PasswordReset),
+		verifications: make(map[string]EmailVerification),
+		rooms:         make(map[int]Room),
+		presence:      make(map[int]UserPresence),
+		nextID:        make(map[string]int),
+	}
+
+	for _, name := range []string{"Technology", "Gaming", "Sports", "General"} {
+		id := s.newID("categories")
+		s.categories[id] = Category{ID: id, Name: name, CreatedAt: time.Now().UTC()}
+	}
+
+	return s
+}
+
+// newID returns the next ID for a table. Callers must hold the write lock.
+func (s *MemoryStore) newID(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// USERS
+
+// CreateUser inserts a user and sets its ID
+func (s *MemoryStore) CreateUser(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	user.ID = s.newID("users")
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
+	s.users[user.ID] = *user
+	return nil
+}
+
+// GetUserByID returns a user's public profile
+func (s *MemoryStore) GetUserByID(id int) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user.PasswordHash = ""
+	return &user, nil
+}
+
+// GetUserByLogin finds a user by username or email, including the password hash
+func (s *MemoryStore) GetUserByLogin(login string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Username == login || user.Email == login {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UserExists reports whether the username or email is already taken
+func (s *MemoryStore) UserExists(username, email string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Username == username || user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetUsersByIDs returns the users with the given IDs
+func (s *MemoryStore) GetUsersByIDs(ids []int) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []User{}
+	for _, id := range ids {
+		if user, ok := s.users[id]; ok {
+			user.PasswordHash = ""
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// SESSIONS
+
+// CreateSession inserts a session and sets its ID
+func (s *MemoryStore) CreateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.ID = s.newID("sessions")
+	session.CreatedAt = time.Now().UTC()
+	if session.LastUsedAt.IsZero() {
+		session.LastUsedAt = session.CreatedAt
+	}
+	if session.AbsoluteExpiresAt.IsZero() {
+		session.AbsoluteExpiresAt = session.ExpiresAt
+	}
+	s.sessions[session.Token] = *session
+	return nil
+}
+
+// GetSession looks up a session by its token
+func (s *MemoryStore) GetSession(token string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// ListUserSessions returns a user's unexpired sessions, most recently used first
+func (s *MemoryStore) ListUserSessions(userID int, now time.Time) ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// TouchSession records that a session was just used
+func (s *MemoryStore) TouchSession(token string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[token]; ok {
+		session.LastUsedAt = at
+		s.sessions[token] = session
+	}
+	return nil
+}
+
+// DeleteSession removes a single session
+func (s *MemoryStore) DeleteSession(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+	return nil
+}
+
+// DeleteUserSessions removes every session of a user
+func (s *MemoryStore) DeleteUserSessions(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, token)
+		}
+	}
+	return nil
+}
+
+// DeleteUserSession removes one of a user's sessions.
+// Returns ErrNotFound if the session does not exist or belongs to someone else.
+func (s *MemoryStore) DeleteUserSession(userID, sessionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if session.ID == sessionID && session.UserID == userID {
+			delete(s.sessions, token)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteOtherSessions removes every session of a user except keepID and
+// returns the IDs of the sessions it removed
+func (s *MemoryStore) DeleteOtherSessions(userID, keepID int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := []int{}
+	for token, session := range s.sessions {
+		if session.UserID == userID && session.ID != keepID {
+			ids = append(ids, session.ID)
+			delete(s.sessions, token)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteExpiredSessions removes sessions that expired at or before now
+func (s *MemoryStore) DeleteExpiredSessions(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(s.sessions, token)
+		}
+	}
+	return nil
+}
+
+// ExtendSession moves a session's expiry
+func (s *MemoryStore) ExtendSession(token string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[token]; ok {
+		session.ExpiresAt = expiresAt
+		s.sessions[token] = session
+	}
+	return nil
+}
+
+// CountSessions returns the total, unexpired and distinct-user session counts
+func (s *MemoryStore) CountSessions(now time.Time) (total, active, users int, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	activeUsers := make(map[int]bool)
+	for _, session := range s.sessions {
+		total++
+		if session.ExpiresAt.After(now) {
+			active++
+			activeUsers[session.UserID] = true
+		}
+	}
+	return total, active, len(activeUsers), nil
+}
+
+// POSTS
+
+// CreatePost inserts a post with its categories and sets its ID
+func (s *MemoryStore) CreatePost(post *Post, categoryIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	post.ID = s.newID("posts")
+	post.CreatedAt = now
+	post.UpdatedAt = now
+	s.posts[post.ID] = Post{
+		ID:        post.ID,
+		UserID:    post.UserID,
+		Title:     post.Title,
+		Content:   post.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.postCats[post.ID] = append([]int(nil), categoryIDs...)
+	return nil
+}
+
+// GetPost returns a post with its author and categories.
+// Soft-deleted posts are returned redacted.
+func (s *MemoryStore) GetPost(id int) (*Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	s.fillPost(&post)
+	post.Author.Email = s.users[post.UserID].Email
+	post.redact()
+	return &post, nil
+}
+
+// ListPosts returns posts matching the filter with authors, categories and vote counts,
+// ordered by filter.Rank or filter.SortBy (newest first by default).
+// Ranking scores are computed on the fly rather than cached.
+func (s *MemoryStore) ListPosts(filter PostFilter) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := s.filterPosts(filter)
+	for i := range posts {
+		s.fillPost(&posts[i])
+	}
+
+	stats := make(map[int]*VoteStats, len(posts))
+	for i := range posts {
+		stats[posts[i].ID] = s.voteStats(VoteTargetPost, posts[i].ID, filter.ViewerID)
+		posts[i].setVoteStats(stats[posts[i].ID])
+	}
+
+	asc := filter.Rank == "" && strings.ToUpper(filter.SortOrder) == SortAsc
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+		if asc {
+			a, b = b, a
+		}
+		sa, sb := stats[a.ID], stats[b.ID]
+
+		switch {
+		case filter.Rank == RankHot:
+			ha := HotScore(sa.LikeCount, sa.DislikeCount, a.CreatedAt)
+			hb := HotScore(sb.LikeCount, sb.DislikeCount, b.CreatedAt)
+			if ha != hb {
+				return ha > hb
+			}
+		case filter.Rank == RankTop:
+			if sa.NetScore != sb.NetScore {
+				return sa.NetScore > sb.NetScore
+			}
+		case filter.Rank == RankControversial:
+			ca := ControversyScore(sa.LikeCount, sa.DislikeCount)
+			cb := ControversyScore(sb.LikeCount, sb.DislikeCount)
+			if ca != cb {
+				return ca > cb
+			}
+		case filter.Rank == "" && filter.SortBy == PostSortTitle:
+			if ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title); ta != tb {
+				return ta > tb
+			}
+		case filter.Rank == "" && filter.SortBy == PostSortLikes:
+			if sa.LikeCount != sb.LikeCount {
+				return sa.LikeCount > sb.LikeCount
+			}
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	if filter.Limit > 0 {
+		posts = paginate(posts, filter.Offset, filter.Limit)
+	}
+	return posts, nil
+}
+
+// CountPosts returns how many posts match the filter, ignoring Limit and Offset
+func (s *MemoryStore) CountPosts(filter PostFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.filterPosts(filter)), nil
+}
+
+// filterPosts returns the unsorted posts matching the filter, leaving out
+// soft-deleted ones. Callers must hold the lock.
+func (s *MemoryStore) filterPosts(filter PostFilter) []Post {
+	terms := searchTerms(filter.Search)
+
+	var topStart *time.Time
+	if filter.Rank == RankTop {
+		topStart = WindowStart(filter.Window, time.Now().UTC())
+	}
+
+	var posts []Post
+	for _, post := range s.posts {
+		if post.DeletedAt != nil {
+			continue
+		}
+		if filter.UserID != nil && post.UserID != *filter.UserID {
+			continue
+		}
+		if filter.CategoryID != nil && !containsInt(s.postCats[post.ID], *filter.CategoryID) {
+			continue
+		}
+		if filter.LikedBy != nil && s.votes[memoryVoteKey{*filter.LikedBy, VoteTargetPost, post.ID}] != VoteLike {
+			continue
+		}
+		if filter.DislikedBy != nil && s.votes[memoryVoteKey{*filter.DislikedBy, VoteTargetPost, post.ID}] != VoteDislike {
+			continue
+		}
+		if filter.MinLikes != nil && s.voteStats(VoteTargetPost, post.ID, 0).LikeCount < *filter.MinLikes {
+			continue
+		}
+		if filter.MaxAge != nil && post.CreatedAt.Before(time.Now().UTC().AddDate(0, 0, -*filter.MaxAge)) {
+			continue
+		}
+		if topStart != nil && post.CreatedAt.Before(*topStart) {
+			continue
+		}
+		if len(terms) > 0 {
+			_, _, titleMatched := markTerms(post.Title, terms)
+			_, _, contentMatched := markTerms(post.Content, terms)
+			if !allMatched(terms, titleMatched, contentMatched) {
+				continue
+			}
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// fillPost attaches the author, categories and comment count. Callers must hold the lock.
+func (s *MemoryStore) fillPost(post *Post) {
+	post.Author = &User{ID: post.UserID, Username: s.users[post.UserID].Username}
+	post.Categories = nil
+	for _, id := range s.postCats[post.ID] {
+		if category, ok := s.categories[id]; ok {
+			post.Categories = append(post.Categories, category)
+		}
+	}
+	sort.Slice(post.Categories, func(i, j int) bool { return post.Categories[i].Name < post.Categories[j].Name })
+
+	post.CommentCount = 0
+	for _, comment := range s.comments {
+		if comment.PostID == post.ID {
+			post.CommentCount++
+		}
+	}
+}
+
+// PostExists reports whether a post with the given ID exists and is not deleted
+func (s *MemoryStore) PostExists(id int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, ok := s.posts[id]
+	return ok && post.DeletedAt == nil, nil
+}
+
+// ListCategories returns every category ordered by name
+func (s *MemoryStore) ListCategories() ([]Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var categories []Category
+	for _, category := range s.categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+// COMMENTS
+
+// CreateComment inserts a comment and sets its ID and timestamps
+func (s *MemoryStore) CreateComment(comment *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if comment.ParentID != nil {
+		parent, ok := s.comments[*comment.ParentID]
+		if !ok || parent.PostID != comment.PostID || parent.DeletedAt != nil {
+			return ErrInvalidParent
+		}
+	}
+
+	now := time.Now().UTC()
+	comment.ID = s.newID("comments")
+	comment.CreatedAt = now
+	comment.UpdatedAt = now
+	s.comments[comment.ID] = Comment{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		UserID:    comment.UserID,
+		Content:   comment.Content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return nil
+}
+
+// ListReplies returns up to limit replies to each of parentIDs with an ID
+// greater than afterID, with their authors and reply counts, grouped by parent
+// and oldest first. A nil parentIDs lists the post's top-level comments.
+// Soft-deleted comments keep their place in the thread, redacted.
+func (s *MemoryStore) ListReplies(postID int, parentIDs []int, afterID, limit int) ([]Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	replyCounts := make(map[int]int)
+	for _, comment := range s.comments {
+		if comment.ParentID != nil {
+			replyCounts[*comment.ParentID]++
+		}
+	}
+
+	var comments []Comment
+	for _, comment := range s.comments {
+		if comment.PostID != postID || comment.ID <= afterID {
+			continue
+		}
+		if parentIDs == nil && comment.ParentID != nil {
+			continue
+		}
+		if parentIDs != nil && (comment.ParentID == nil || !containsInt(parentIDs, *comment.ParentID)) {
+			continue
+		}
+		comment.Author = &User{ID: comment.UserID, Username: s.users[comment.UserID].Username}
+		comment.ReplyCount = replyCounts[comment.ID]
+		comment.redact()
+		comments = append(comments, comment)
+	}
+
+	sort.Slice(comments, func(i, j int) bool {
+		if a, b := parentKey(comments[i]), parentKey(comments[j]); a != b {
+			return a < b
+		}
+		return comments[i].ID < comments[j].ID
+	})
+
+	// Keep the first limit replies of each parent
+	page := comments[:0]
+	taken := make(map[int]int)
+	for _, comment := range comments {
+		key := parentKey(comment)
+		if taken[key] < limit {
+			taken[key]++
+			page = append(page, comment)
+		}
+	}
+	return page, nil
+}
+
+// parentKey groups top-level comments under zero
+func parentKey(comment Comment) int {
+	if comment.ParentID == nil {
+		return 0
+	}
+	return *comment.ParentID
+}
+
+// GetComment returns a comment with its author, redacted if soft-deleted
+func (s *MemoryStore) GetComment(id int) (*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	comment.Author = &User{ID: comment.UserID, Username: s.users[comment.UserID].Username}
+	comment.redact()
+	return &comment, nil
+}
+
+// GetCommentPostID returns the post a comment belongs to
+func (s *MemoryStore) GetCommentPostID(commentID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.comments[commentID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return comment.PostID, nil
+}
+
+// REVISIONS
+
+// UpdatePost changes a post's title and content and records the revision
+func (s *MemoryStore) UpdatePost(postID, editorID int, title, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[postID]
+	if !ok || post.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	s.appendRevision(
+		Revision{TargetType: VoteTargetPost, TargetID: postID, EditorID: post.UserID, Title: post.Title, Content: post.Content, CreatedAt: post.CreatedAt},
+		Revision{TargetType: VoteTargetPost, TargetID: postID, EditorID: editorID, Action: RevisionEdit, Title: title, Content: content, CreatedAt: now},
+	)
+	post.Title, post.Content, post.UpdatedAt = title, content, now
+	s.posts[postID] = post
+	return nil
+}
+
+// DeletePost soft-deletes a post and records the revision
+func (s *MemoryStore) DeletePost(postID, editorID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[postID]
+	if !ok || post.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	s.appendRevision(
+		Revision{TargetType: VoteTargetPost, TargetID: postID, EditorID: post.UserID, Title: post.Title, Content: post.Content, CreatedAt: post.CreatedAt},
+		Revision{TargetType: VoteTargetPost, TargetID: postID, EditorID: editorID, Action: RevisionDelete, Title: post.Title, Content: post.Content, CreatedAt: now},
+	)
+	post.DeletedAt = &now
+	s.posts[postID] = post
+	return nil
+}
+
+// UpdateComment changes a comment's content and records the revision
+func (s *MemoryStore) UpdateComment(commentID, editorID int, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[commentID]
+	if !ok || comment.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	s.appendRevision(
+		Revision{TargetType: VoteTargetComment, TargetID: commentID, EditorID: comment.UserID, Content: comment.Content, CreatedAt: comment.CreatedAt},
+		Revision{TargetType: VoteTargetComment, TargetID: commentID, EditorID: editorID, Action: RevisionEdit, Content: content, CreatedAt: now},
+	)
+	comment.Content, comment.UpdatedAt = content, now
+	s.comments[commentID] = comment
+	return nil
+}
+
+// DeleteComment soft-deletes a comment and records the revision
+func (s *MemoryStore) DeleteComment(commentID, editorID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[commentID]
+	if !ok || comment.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	s.appendRevision(
+		Revision{TargetType: VoteTargetComment, TargetID: commentID, EditorID: comment.UserID, Content: comment.Content, CreatedAt: comment.CreatedAt},
+		Revision{TargetType: VoteTargetComment, TargetID: commentID, EditorID: editorID, Action: RevisionDelete, Content: comment.Content, CreatedAt: now},
+	)
+	comment.DeletedAt = &now
+	s.comments[commentID] = comment
+	return nil
+}
+
+// appendRevision records next as the newest version of a post or comment,
+// diffed against current, recording current first as the original when the
+// target has no history yet. Callers must hold the write lock.
+func (s *MemoryStore) appendRevision(current, next Revision) {
+	hasHistory := false
+	for _, rev := range s.revisions {
+		if rev.TargetType == current.TargetType && rev.TargetID == current.TargetID {
+			hasHistory = true
+			break
+		}
+	}
+	if !hasHistory {
+		current.ID = s.newID("revisions")
+		current.Action = RevisionCreate
+		s.revisions = append(s.revisions, current)
+	}
+
+	next.ID = s.newID("revisions")
+	next.Diff = LineDiff(revisionText(current), revisionText(next))
+	s.revisions = append(s.revisions, next)
+}
+
+// ListRevisions returns every version of a post or comment, oldest first
+func (s *MemoryStore) ListRevisions(targetType string, targetID int) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := []Revision{}
+	for _, rev := range s.revisions {
+		if rev.TargetType == targetType && rev.TargetID == targetID {
+			rev.Editor = &User{ID: rev.EditorID, Username: s.users[rev.EditorID].Username}
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions, nil
+}
+
+// VOTES
+
+// ToggleVote applies a vote from userID on a post or comment and returns the new stats
+func (s *MemoryStore) ToggleVote(userID int, targetType string, targetID int, voteType int) (*VoteStats, error) {
+	if voteType != VoteLike && voteType != VoteDislike {
+		return nil, ErrInvalidVote
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch targetType {
+	case VoteTargetPost:
+		if post, ok := s.posts[targetID]; !ok || post.DeletedAt != nil {
+			return nil, ErrVoteTargetNotFound
+		}
+	case VoteTargetComment:
+		if comment, ok := s.comments[targetID]; !ok || comment.DeletedAt != nil {
+			return nil, ErrVoteTargetNotFound
+		}
+	default:
+		return nil, ErrInvalidVote
+	}
+
+	key := memoryVoteKey{userID, targetType, targetID}
+	if s.votes[key] == voteType {
+		delete(s.votes, key)
+	} else {
+		s.votes[key] = voteType
+	}
+
+	return s.voteStats(targetType, targetID, userID), nil
+}
+
+// GetVoteStats returns the vote counts of a post or comment
+func (s *MemoryStore) GetVoteStats(targetType string, targetID int, userID int) (*VoteStats, error) {
+	if targetType != VoteTargetPost && targetType != VoteTargetComment {
+		return nil, ErrInvalidVote
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.voteStats(targetType, targetID, userID), nil
+}
+
+// GetVoteStatsBatch returns the vote counts of many posts or comments, keyed by target ID
+func (s *MemoryStore) GetVoteStatsBatch(targetType string, targetIDs []int, userID int) (map[int]*VoteStats, error) {
+	if _, _, err := voteTarget(targetType); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[int]*VoteStats, len(targetIDs))
+	for _, id := range targetIDs {
+		stats[id] = s.voteStats(targetType, id, userID)
+	}
+	return stats, nil
+}
+
+// voteStats counts votes on a target. Callers must hold the lock.
+func (s *MemoryStore) voteStats(targetType string, targetID int, userID int) *VoteStats {
+	var stats VoteStats
+	for key, voteType := range s.votes {
+		if key.targetType != targetType || key.targetID != targetID {
+			continue
+		}
+		if voteType == VoteLike {
+			stats.LikeCount++
+		} else {
+			stats.DislikeCount++
+		}
+		if userID != 0 && key.userID == userID {
+			isLike := voteType == VoteLike
+			stats.UserVote = &isLike
+		}
+	}
+	stats.NetScore = stats.LikeCount - stats.DislikeCount
+	stats.TotalVotes = stats.LikeCount + stats.DislikeCount
+	return &stats
+}
+
+// MESSAGES
+
+// CreateMessage inserts a message and sets its ID
+func (s *MemoryStore) CreateMessage(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.ID = s.newID("messages")
+	msg.SetStatus()
+	s.messages = append(s.messages, *msg)
+	return nil
+}
+
+// MarkDelivered records when a message reached the receiver
+func (s *MemoryStore) MarkDelivered(messageID int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.messages {
+		if s.messages[i].ID == messageID {
+			s.messages[i].DeliveredAt = &at
+			s.messages[i].SetStatus()
+		}
+	}
+	return nil
+}
+
+// MarkConversationDelivered marks every undelivered message from senderID to receiverID as delivered
+func (s *MemoryStore) MarkConversationDelivered(senderID, receiverID int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.messages {
+		msg := &s.messages[i]
+		if msg.SenderID == senderID && msg.ReceiverID == receiverID && msg.DeliveredAt == nil {
+			msg.DeliveredAt = &at
+			msg.SetStatus()
+		}
+	}
+	return nil
+}
+
+// MarkRead marks unread messages from senderID to readerID with id <= upToID as read
+func (s *MemoryStore) MarkRead(readerID, senderID, upToID int, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for i := range s.messages {
+		msg := &s.messages[i]
+		if msg.SenderID != senderID || msg.ReceiverID != readerID || msg.ID > upToID || msg.ReadAt != nil {
+			continue
+		}
+		msg.IsRead = true
+		msg.ReadAt = &at
+		if msg.DeliveredAt == nil {
+			msg.DeliveredAt = &at
+		}
+		msg.SetStatus()
+		count++
+	}
+	return count, nil
+}
+
+// ListMessages returns one page of the conversation between two users
+func (s *MemoryStore) ListMessages(userID, otherID int, page MessagePage) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := []Message{}
+	inConversation := func(msg Message) bool {
+		return (msg.SenderID == userID && msg.ReceiverID == otherID) ||
+			(msg.SenderID == otherID && msg.ReceiverID == userID)
+	}
+
+	if page.AfterID > 0 {
+		for _, msg := range s.messages {
+			if len(messages) == page.Limit {
+				break
+			}
+			if msg.ID > page.AfterID && inConversation(msg) {
+				messages = append(messages, msg)
+			}
+		}
+		return messages, nil
+	}
+
+	for i := len(s.messages) - 1; i >= 0 && len(messages) < page.Limit; i-- {
+		msg := s.messages[i]
+		if page.BeforeID > 0 && msg.ID >= page.BeforeID {
+			continue
+		}
+		if inConversation(msg) {
+			messages = append(messages, msg)
+		}
+	}
+	reverseMessages(messages)
+	return messages, nil
+}
+
+// ListConversations returns one entry per counterpart, most recent first
+func (s *MemoryStore) ListConversations(userID int) ([]Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byUser := make(map[int]*Conversation)
+	for _, msg := range s.messages {
+		otherID := msg.SenderID
+		if msg.SenderID == userID {
+			otherID = msg.ReceiverID
+		} else if msg.ReceiverID != userID {
+			continue
+		}
+
+		conv, ok := byUser[otherID]
+		if !ok {
+			conv = &Conversation{OtherUserID: otherID, OtherUsername: s.users[otherID].Username}
+			byUser[otherID] = conv
+		}
+		conv.LastMessage = msg // Messages are kept in ID order
+		if msg.ReceiverID == userID && msg.ReadAt == nil {
+			conv.UnreadCount++
+		}
+	}
+
+	conversations := []Conversation{}
+	for _, conv := range byUser {
+		conversations = append(conversations, *conv)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].LastMessage.ID > conversations[j].LastMessage.ID
+	})
+	return conversations, nil
+}
+
+// ROOMS
+
+// CreateRoom inserts a room owned by room.CreatedBy with the given members
+// and sets its ID and timestamps
+func (s *MemoryStore) CreateRoom(room *Room, memberIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	room.ID = s.newID("conversations")
+	room.CreatedAt = now
+	room.UpdatedAt = now
+
+	stored := *room
+	stored.Members = []RoomMember{{UserID: room.CreatedBy, Role: RoomRoleOwner, JoinedAt: now}}
+	for _, memberID := range memberIDs {
+		stored.Members = append(stored.Members, RoomMember{UserID: memberID, Role: RoomRoleMember, JoinedAt: now})
+	}
+	s.rooms[room.ID] = stored
+	return nil
+}
+
+// GetRoom returns a room with its members
+func (s *MemoryStore) GetRoom(roomID int) (*Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	room = s.fillRoom(room)
+	return &room, nil
+}
+
+// ListUserRooms returns every room a user belongs to with their members,
+// most recently active first
+func (s *MemoryStore) ListUserRooms(userID int) ([]Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := []Room{}
+	for _, room := range s.rooms {
+		if roomMemberIndex(room, userID) >= 0 {
+			rooms = append(rooms, s.fillRoom(room))
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if !rooms[i].UpdatedAt.Equal(rooms[j].UpdatedAt) {
+			return rooms[i].UpdatedAt.After(rooms[j].UpdatedAt)
+		}
+		return rooms[i].ID > rooms[j].ID
+	})
+	return rooms, nil
+}
+
+// fillRoom returns a copy of room with its members' usernames set.
+// Callers must hold the lock.
+func (s *MemoryStore) fillRoom(room Room) Room {
+	members := make([]RoomMember, len(room.Members))
+	for i, member := range room.Members {
+		member.Username = s.users[member.UserID].Username
+		members[i] = member
+	}
+	room.Members = members
+	return room
+}
+
+// roomMemberIndex returns the position of userID in room.Members, or -1
+func roomMemberIndex(room Room, userID int) int {
+	for i, member := range room.Members {
+		if member.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetMemberRole returns a user's role in a room, or ErrNotFound if they aren't a member
+func (s *MemoryStore) GetMemberRole(roomID, userID int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	i := roomMemberIndex(room, userID)
+	if i < 0 {
+		return "", ErrNotFound
+	}
+	return room.Members[i].Role, nil
+}
+
+// ListMemberIDs returns the user IDs of every member of a room
+func (s *MemoryStore) ListMemberIDs(roomID int) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []int
+	for _, member := range s.rooms[roomID].Members {
+		ids = append(ids, member.UserID)
+	}
+	return ids, nil
+}
+
+// AddRoomMember adds a user to a room with the given role, or returns
+// ErrRoomFull if the room already has maxMembers members
+func (s *MemoryStore) AddRoomMember(roomID, userID int, role string, maxMembers int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return ErrNotFound
+	}
+	if roomMemberIndex(room, userID) >= 0 {
+		return errors.New("user is already a member")
+	}
+	if len(room.Members) >= maxMembers {
+		return ErrRoomFull
+	}
+	room.Members = append(room.Members, RoomMember{UserID: userID, Role: role, JoinedAt: time.Now().UTC()})
+	s.rooms[roomID] = room
+	return nil
+}
+
+// RemoveRoomMember removes a user from a room. When the owner leaves, ownership
+// passes to the member who joined earliest; when the last member leaves, the
+// room is deleted. Returns ErrNotFound if the user isn't a member, and reports
+// whether the room was deleted.
+func (s *MemoryStore) RemoveRoomMember(roomID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	i := roomMemberIndex(room, userID)
+	if i < 0 {
+		return false, ErrNotFound
+	}
+	role := room.Members[i].Role
+	room.Members = append(room.Members[:i:i], room.Members[i+1:]...)
+
+	if len(room.Members) == 0 {
+		delete(s.rooms, roomID)
+		kept := s.roomMessages[:0]
+		for _, msg := range s.roomMessages {
+			if msg.RoomID != roomID {
+				kept = append(kept, msg)
+			}
+		}
+		s.roomMessages = kept
+		return true, nil
+	}
+
+	// Members are kept in join order
+	if role == RoomRoleOwner {
+		room.Members[0].Role = RoomRoleOwner
+	}
+	s.rooms[roomID] = room
+	return false, nil
+}
+
+// CreateRoomMessage inserts a message, sets its ID and bumps the room's updated_at
+func (s *MemoryStore) CreateRoomMessage(msg *RoomMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[msg.RoomID]
+	if !ok {
+		return ErrNotFound
+	}
+	room.UpdatedAt = msg.CreatedAt
+	s.rooms[msg.RoomID] = room
+
+	msg.ID = s.newID("conversation_messages")
+	s.roomMessages = append(s.roomMessages, *msg)
+	return nil
+}
+
+// ListRoomMessages returns one page of a room's messages with their senders'
+// usernames, oldest first
+func (s *MemoryStore) ListRoomMessages(roomID int, page MessagePage) ([]RoomMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := []RoomMessage{}
+	if page.AfterID > 0 {
+		for _, msg := range s.roomMessages {
+			if len(messages) == page.Limit {
+				break
+			}
+			if msg.RoomID == roomID && msg.ID > page.AfterID {
+				msg.SenderUsername = s.users[msg.SenderID].Username
+				messages = append(messages, msg)
+			}
+		}
+		return messages, nil
+	}
+
+	for i := len(s.roomMessages) - 1; i >= 0 && len(messages) < page.Limit; i-- {
+		msg := s.roomMessages[i]
+		if msg.RoomID != roomID || (page.BeforeID > 0 && msg.ID >= page.BeforeID) {
+			continue
+		}
+		msg.SenderUsername = s.users[msg.SenderID].Username
+		messages = append(messages, msg)
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// PRESENCE
+
+// SavePresence records a user's online state and last-seen time
+func (s *MemoryStore) SavePresence(userID int, online bool, lastSeen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.presence[userID] = UserPresence{ID: userID, Online: online, LastSeen: &lastSeen}
+	return nil
+}
+
+// ResetPresence marks every user offline
+func (s *MemoryStore) ResetPresence() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, presence := range s.presence {
+		presence.Online = false
+		s.presence[userID] = presence
+	}
+	return nil
+}
+
+// ListPresence returns every user with their last recorded presence, ordered by username
+func (s *MemoryStore) ListPresence() ([]UserPresence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []UserPresence{}
+	for _, user := range s.users {
+		presence := s.presence[user.ID]
+		presence.ID = user.ID
+		presence.Username = user.Username
+		users = append(users, presence)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// SEARCH
+
+// Search matches posts and comments containing a word starting with every query term.
+// Relevance is the number of matching words.
+func (s *MemoryStore) Search(q SearchQuery) ([]SearchResult, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := []SearchResult{}
+	terms := searchTerms(q.Query)
+	if len(terms) == 0 {
+		return results, 0, nil
+	}
+
+	matches := func(userID, postID int, createdAt time.Time) bool {
+		if s.posts[postID].DeletedAt != nil {
+			return false
+		}
+		if q.CategoryID != nil && !containsInt(s.postCats[postID], *q.CategoryID) {
+			return false
+		}
+		if q.Author != "" && !strings.EqualFold(s.users[userID].Username, q.Author) {
+			return false
+		}
+		if q.Since != nil && createdAt.Before(*q.Since) {
+			return false
+		}
+		if q.Until != nil && !createdAt.Before(*q.Until) {
+			return false
+		}
+		return true
+	}
+
+	if q.Type != SearchTypeComment {
+		for _, post := range s.posts {
+			if !matches(post.UserID, post.ID, post.CreatedAt) {
+				continue
+			}
+			_, titleHits, titleMatched := markTerms(post.Title, terms)
+			content, contentHits, contentMatched := markTerms(post.Content, terms)
+			if !allMatched(terms, titleMatched, contentMatched) {
+				continue
+			}
+			results = append(results, SearchResult{
+				Type:      SearchTypePost,
+				ID:        post.ID,
+				Title:     post.Title,
+				Content:   highlight(excerpt(content)),
+				Author:    s.users[post.UserID].Username,
+				CreatedAt: post.CreatedAt,
+				Relevance: float64(2*titleHits + contentHits),
+				URL:       searchResultURL(post.ID),
+			})
+		}
+	}
+
+	if q.Type != SearchTypePost {
+		for _, comment := range s.comments {
+			if comment.DeletedAt != nil || !matches(comment.UserID, comment.PostID, comment.CreatedAt) {
+				continue
+			}
+			content, hits, matched := markTerms(comment.Content, terms)
+			if !allMatched(terms, matched) {
+				continue
+			}
+			postID := comment.PostID
+			results = append(results, SearchResult{
+				Type:      SearchTypeComment,
+				ID:        comment.ID,
+				Title:     s.posts[postID].Title,
+				Content:   highlight(excerpt(content)),
+				Author:    s.users[comment.UserID].Username,
+				CreatedAt: comment.CreatedAt,
+				Relevance: float64(hits),
+				URL:       searchResultURL(postID),
+				PostID:    &postID,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Relevance != results[j].Relevance {
+			return results[i].Relevance > results[j].Relevance
+		}
+		if !results[i].CreatedAt.Equal(results[j].CreatedAt) {
+			return results[i].CreatedAt.After(results[j].CreatedAt)
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	total := len(results)
+	if q.Limit > 0 {
+		results = paginate(results, q.Offset, q.Limit)
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	return results, total, nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the slice window [offset, offset+limit)
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// PASSWORD RESETS
+
+// CreatePasswordReset stores a reset token and sets its ID, voiding the
+// user's earlier unused tokens
+func (s *MemoryStore) CreatePasswordReset(reset *PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, existing := range s.resets {
+		if existing.UserID == reset.UserID && existing.UsedAt == nil {
+			delete(s.resets, hash)
+		}
+	}
+
+	reset.ID = s.newID("password_resets")
+	reset.CreatedAt = time.Now().UTC()
+	s.resets[reset.TokenHash] = *reset
+	return nil
+}
+
+// ResetPassword redeems an unused, unexpired token and sets its user's
+// password hash in one step, returning the user's ID.
+// Returns ErrNotFound if the token is unknown, used or expired.
+func (s *MemoryStore) ResetPassword(tokenHash, passwordHash string, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.resets[tokenHash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	user, ok := s.users[reset.UserID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	usedAt := now
+	reset.UsedAt = &usedAt
+	s.resets[tokenHash] = reset
+
+	user.PasswordHash = passwordHash
+	user.UpdatedAt = now
+	s.users[user.ID] = user
+	return user.ID, nil
+}
+
+// EMAIL VERIFICATION
+
+// CreateEmailVerification stores a verification token and sets its ID,
+// voiding the user's earlier tokens
+func (s *MemoryStore) CreateEmailVerification(verification *EmailVerification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, existing := range s.verifications {
+		if existing.UserID == verification.UserID {
+			delete(s.verifications, hash)
+		}
+	}
+
+	verification.ID = s.newID("email_verifications")
+	verification.CreatedAt = time.Now().UTC()
+	s.verifications[verification.TokenHash] = *verification
+	return nil
+}
+
+// VerifyEmail redeems an unexpired token, marks its user's email as
+// verified and returns the user's ID.
+// Returns ErrNotFound if the token is unknown, used or expired.
+func (s *MemoryStore) VerifyEmail(tokenHash string, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification, ok := s.verifications[tokenHash]
+	if !ok || !verification.ExpiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	delete(s.verifications, tokenHash)
+
+	user, ok := s.users[verification.UserID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		verifiedAt := now
+		user.EmailVerifiedAt = &verifiedAt
+		user.UpdatedAt = now
+		s.users[user.ID] = user
+	}
+	return user.ID, nil
+}
@@ -5,9 +5,55 @@ import (
 	"log"
 )
 
+// migrations is the ordered list of schema changes.
+// Never edit or renumber a migration once it has shipped; add a new one instead.
+var migrations = []Migration{
+	{Version: 1, Name: "initial_schema", Up: createInitialSchema, Down: dropInitialSchema},
+	{Version: 2, Name: "realtime_features", Up: AddRealtimeFeatures, Down: dropRealtimeFeatures},
+	{Version: 3, Name: "message_receipts", Up: AddMessageReceipts, Down: dropMessageReceipts},
+	{Version: 4, Name: "group_conversations", Up: AddGroupConversations, Down: dropGroupConversations},
+	{Version: 5, Name: "vote_uniqueness", Up: AddVoteUniqueness, Down: dropVoteUniqueness},
+	{Version: 6, Name: "full_text_search", Up: AddFullTextSearch, Down: dropFullTextSearch},
+	{Version: 7, Name: "post_scores", Up: AddPostScores, Down: dropPostScores},
+	{Version: 8, Name: "comment_threads", Up: AddCommentThreads, Down: dropCommentThreads},
+	{Version: 9, Name: "edit_history", Up: AddEditHistory, Down: dropEditHistory},
+	{Version: 10, Name: "session_devices", Up: AddSessionDevices, Down: dropSessionDevices},
+	{Version: 11, Name: "session_expiry", Up: AddSessionExpiry, Down: dropSessionExpiry},
+	{Version: 12, Name: "password_resets", Up: AddPasswordResets, Down: dropPasswordResets},
+	{Version: 13, Name: "email_verification", Up: AddEmailVerification, Down: dropEmailVerification},
+}
+
+// execAll runs each statement in order, stopping at the first error
+func execAll(tx *Tx, queries []string) error {
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createInitialSchema creates the tables the forum started out with
+func createInitialSchema(tx *Tx) error {
+	return createTables(tx)
+}
+
+func dropInitialSchema(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP TABLE IF EXISTS messages",
+		"DROP TABLE IF EXISTS votes",
+		"DROP TABLE IF EXISTS comments",
+		"DROP TABLE IF EXISTS post_categories",
+		"DROP TABLE IF EXISTS posts",
+		"DROP TABLE IF EXISTS categories",
+		"DROP TABLE IF EXISTS sessions",
+		"DROP TABLE IF EXISTS users",
+	})
+}
+
 // AddRealtimeFeatures creates tables for real-time functionality
 // This adds support for private messaging and online/offline status tracking
-func AddRealtimeFeatures(db *sql.DB) error {
+func AddRealtimeFeatures(tx *Tx) error {
 	log.Println("🔄 Adding real-time features to database...")
 
 	// Table for private messages between users
@@ -23,7 +69,7 @@ func AddRealtimeFeatures(db *sql.DB) error {
 		FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
 	)`
 
-	_, err := db.Exec(messagesTable)
+	_, err := tx.Exec(messagesTable)
 	if err != nil {
 		return err
 	}
@@ -39,7 +85,7 @@ func AddRealtimeFeatures(db *sql.DB) error {
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 	)`
 
-	_, err = db.Exec(userStatusTable)
+	_, err = tx.Exec(userStatusTable)
 	if err != nil {
 		return err
 	}
@@ -60,12 +106,571 @@ func AddRealtimeFeatures(db *sql.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_user_status_websocket ON user_status(websocket_id)",
 	}
 
+	// A failed statement aborts the whole transaction on PostgreSQL, so index
+	// errors can't be skipped over
+	if err := execAll(tx, indexes); err != nil {
+		return err
+	}
+
+	log.Println("🎉 Real-time tables ready!")
+	return nil
+}
+
+// dropRealtimeFeatures drops everything AddRealtimeFeatures adds. The messages
+// table and its sender/receiver indexes stay since the initial schema owns them.
+func dropRealtimeFeatures(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP INDEX IF EXISTS idx_messages_created",
+		"DROP INDEX IF EXISTS idx_messages_unread",
+		"DROP INDEX IF EXISTS idx_user_status_online",
+		"DROP INDEX IF EXISTS idx_user_status_websocket",
+		"DROP TABLE IF EXISTS user_status",
+	})
+}
+
+// AddMessageReceipts adds per-message delivery and read timestamps
+// Existing rows already flagged is_read get read_at backfilled from created_at
+func AddMessageReceipts(tx *Tx) error {
+	columns := map[string]string{
+		"delivered_at": "DATETIME",
+		"read_at":      "DATETIME",
+	}
+
+	for column, definition := range columns {
+		exists, err := columnExists(tx, "messages", column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := tx.Exec("ALTER TABLE messages ADD COLUMN " + column + " " + definition); err != nil {
+			return err
+		}
+		log.Printf("✅ Added messages.%s column", column)
+	}
+
+	_, err := tx.Exec(`
+		UPDATE messages
+		SET read_at = created_at, delivered_at = COALESCE(delivered_at, created_at)
+		WHERE is_read AND read_at IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(sender_id, receiver_id, id)",
+		"CREATE INDEX IF NOT EXISTS idx_messages_inbox ON messages(receiver_id, sender_id, id)",
+	}
 	for _, indexSQL := range indexes {
-		if _, err := db.Exec(indexSQL); err != nil {
-			log.Printf("⚠️  Warning: Failed to create index: %v", err)
+		if _, err := tx.Exec(indexSQL); err != nil {
+			return err
 		}
 	}
 
-	log.Println("🎉 Real-time tables ready!")
 	return nil
 }
+
+func dropMessageReceipts(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP INDEX IF EXISTS idx_messages_conversation",
+		"DROP INDEX IF EXISTS idx_messages_inbox",
+		"ALTER TABLE messages DROP COLUMN read_at",
+		"ALTER TABLE messages DROP COLUMN delivered_at",
+	})
+}
+
+// columnExists reports whether a table already has the given column
+func columnExists(tx *Tx, table, column string) (bool, error) {
+	if tx.Dialect == Postgres {
+		var count int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?
+		`, table, column).Scan(&count)
+		return count > 0, err
+	}
+
+	rows, err := tx.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// AddGroupConversations creates tables for multi-member chat rooms
+// Room messages live in their own table since private messages require a receiver_id
+func AddGroupConversations(tx *Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS conversations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS conversation_members (
+			conversation_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL DEFAULT 'member',
+			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (conversation_id, user_id),
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS conversation_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			sender_id INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+			FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		"CREATE INDEX IF NOT EXISTS idx_conversation_members_user ON conversation_members(user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation ON conversation_messages(conversation_id, id)",
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	log.Println("✅ Group conversation tables created/verified")
+	return nil
+}
+
+func dropGroupConversations(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP TABLE IF EXISTS conversation_messages",
+		"DROP TABLE IF EXISTS conversation_members",
+		"DROP TABLE IF EXISTS conversations",
+	})
+}
+
+// AddVoteUniqueness enforces one vote per user per post or comment
+// UNIQUE(user_id, post_id, comment_id) never matches because one of the
+// target columns is always NULL, so duplicates are removed and partial
+// unique indexes are created per target type
+func AddVoteUniqueness(tx *Tx) error {
+	queries := []string{
+		`DELETE FROM votes WHERE id NOT IN (
+			SELECT MAX(id) FROM votes GROUP BY user_id, post_id, comment_id
+		)`,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_votes_user_post ON votes(user_id, post_id) WHERE post_id IS NOT NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_votes_user_comment ON votes(user_id, comment_id) WHERE comment_id IS NOT NULL",
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dropVoteUniqueness(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP INDEX IF EXISTS idx_votes_user_post",
+		"DROP INDEX IF EXISTS idx_votes_user_comment",
+	})
+}
+
+// AddFullTextSearch indexes post and comment text for /api/search.
+// SQLite uses external-content FTS5 tables kept in sync by triggers;
+// PostgreSQL uses GIN indexes over the same tsvector expressions the search queries use.
+// SQLite builds without FTS5 get no index, and search falls back to LIKE matching.
+func AddFullTextSearch(tx *Tx) error {
+	if tx.Dialect == Postgres {
+		return execAll(tx, []string{
+			"CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (to_tsvector('english', title || ' ' || content))",
+			"CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN (to_tsvector('english', content))",
+		})
+	}
+
+	var fts5 bool
+	if err := tx.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return err
+	}
+	if !fts5 {
+		log.Println("⚠️ SQLite was built without FTS5 (-tags sqlite_fts5); search will use slower LIKE matching")
+		return nil
+	}
+
+	err := execAll(tx, []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+			title, content,
+			content='posts', content_rowid='id', tokenize='porter unicode61'
+		)`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+			content,
+			content='comments', content_rowid='id', tokenize='porter unicode61'
+		)`,
+
+		// External-content tables only see changes the triggers feed them
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+			INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+			INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+			INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+			INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+			INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+			INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+			INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+		END`,
+
+		// Index everything written before this migration
+		"INSERT INTO posts_fts (posts_fts) VALUES ('rebuild')",
+		"INSERT INTO comments_fts (comments_fts) VALUES ('rebuild')",
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Println("✅ Full-text search indexes created")
+	return nil
+}
+
+func dropFullTextSearch(tx *Tx) error {
+	if tx.Dialect == Postgres {
+		return execAll(tx, []string{
+			"DROP INDEX IF EXISTS idx_posts_search",
+			"DROP INDEX IF EXISTS idx_comments_search",
+		})
+	}
+
+	return execAll(tx, []string{
+		"DROP TRIGGER IF EXISTS posts_fts_insert",
+		"DROP TRIGGER IF EXISTS posts_fts_delete",
+		"DROP TRIGGER IF EXISTS posts_fts_update",
+		"DROP TRIGGER IF EXISTS comments_fts_insert",
+		"DROP TRIGGER IF EXISTS comments_fts_delete",
+		"DROP TRIGGER IF EXISTS comments_fts_update",
+		"DROP TABLE IF EXISTS posts_fts",
+		"DROP TABLE IF EXISTS comments_fts",
+	})
+}
+
+// AddPostScores caches each post's vote counts and ranking scores so the feed
+// can be ranked hot, top or controversial without scanning the votes table.
+// Rows are kept current by the vote transaction; existing posts are backfilled here.
+func AddPostScores(tx *Tx) error {
+	err := execAll(tx, []string{
+		`CREATE TABLE IF NOT EXISTS post_scores (
+			post_id INTEGER PRIMARY KEY,
+			likes INTEGER NOT NULL DEFAULT 0,
+			dislikes INTEGER NOT NULL DEFAULT 0,
+			score INTEGER NOT NULL DEFAULT 0,
+			hot DOUBLE PRECISION NOT NULL DEFAULT 0,
+			controversy DOUBLE PRECISION NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_post_scores_hot ON post_scores(hot)",
+		"CREATE INDEX IF NOT EXISTS idx_post_scores_score ON post_scores(score)",
+		"CREATE INDEX IF NOT EXISTS idx_post_scores_controversy ON post_scores(controversy)",
+	})
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT p.id,
+			COUNT(CASE WHEN v.vote_type = 1 THEN 1 END),
+			COUNT(CASE WHEN v.vote_type = -1 THEN 1 END)
+		FROM posts p
+		LEFT JOIN votes v ON v.post_id = p.id
+		GROUP BY p.id
+	`)
+	if err != nil {
+		return err
+	}
+
+	type postVotes struct{ id, likes, dislikes int }
+	var posts []postVotes
+	for rows.Next() {
+		var post postVotes
+		if err := rows.Scan(&post.id, &post.likes, &post.dislikes); err != nil {
+			rows.Close()
+			return err
+		}
+		posts = append(posts, post)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		if err := refreshPostScore(tx, post.id, post.likes, post.dislikes); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Post scores cached for %d post(s)", len(posts))
+	return nil
+}
+
+func dropPostScores(tx *Tx) error {
+	return execAll(tx, []string{"DROP TABLE IF EXISTS post_scores"})
+}
+
+// AddCommentThreads lets comments reply to other comments on the same post.
+// parent_id carries no foreign key because SQLite cannot drop a column that has one;
+// CreateComment checks the parent instead.
+func AddCommentThreads(tx *Tx) error {
+	exists, err := columnExists(tx, "comments", "parent_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := tx.Exec("ALTER TABLE comments ADD COLUMN parent_id INTEGER"); err != nil {
+			return err
+		}
+		log.Println("✅ Added comments.parent_id column")
+	}
+
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_comments_thread ON comments(post_id, parent_id, id)")
+	return err
+}
+
+func dropCommentThreads(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP INDEX IF EXISTS idx_comments_thread",
+		"ALTER TABLE comments DROP COLUMN parent_id",
+	})
+}
+
+// AddEditHistory adds what editing and deleting posts and comments needs:
+// user roles for moderators, soft-delete timestamps and an append-only
+// revisions table guarded by triggers against updates and deletes
+func AddEditHistory(tx *Tx) error {
+	columns := []struct{ table, column, definition string }{
+		{"users", "role", "TEXT NOT NULL DEFAULT 'member'"},
+		{"posts", "deleted_at", "DATETIME"},
+		{"comments", "deleted_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		exists, err := columnExists(tx, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := tx.Exec("ALTER TABLE " + c.table + " ADD COLUMN " + c.column + " " + c.definition); err != nil {
+			return err
+		}
+		log.Printf("✅ Added %s.%s column", c.table, c.column)
+	}
+
+	err := execAll(tx, []string{
+		`CREATE TABLE IF NOT EXISTS revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target_type TEXT NOT NULL,
+			target_id INTEGER NOT NULL,
+			editor_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			diff TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_revisions_target ON revisions(target_type, target_id, id)",
+	})
+	if err != nil {
+		return err
+	}
+
+	if tx.Dialect == Postgres {
+		return execAll(tx, []string{
+			`CREATE OR REPLACE FUNCTION revisions_immutable() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'revisions are immutable';
+			END
+			$$ LANGUAGE plpgsql`,
+			`CREATE TRIGGER revisions_immutable BEFORE UPDATE OR DELETE ON revisions
+			FOR EACH ROW EXECUTE FUNCTION revisions_immutable()`,
+		})
+	}
+	return execAll(tx, []string{
+		`CREATE TRIGGER IF NOT EXISTS revisions_no_update BEFORE UPDATE ON revisions BEGIN
+			SELECT RAISE(ABORT, 'revisions are immutable');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS revisions_no_delete BEFORE DELETE ON revisions BEGIN
+			SELECT RAISE(ABORT, 'revisions are immutable');
+		END`,
+	})
+}
+
+func dropEditHistory(tx *Tx) error {
+	queries := []string{
+		"DROP TABLE IF EXISTS revisions",
+		"ALTER TABLE comments DROP COLUMN deleted_at",
+		"ALTER TABLE posts DROP COLUMN deleted_at",
+		"ALTER TABLE users DROP COLUMN role",
+	}
+	if tx.Dialect == Postgres {
+		queries = append(queries, "DROP FUNCTION IF EXISTS revisions_immutable()")
+	}
+	return execAll(tx, queries)
+}
+
+// AddSessionDevices records which device a session belongs to and when it was
+// last used, so users can review their logins and revoke the ones they don't recognise
+func AddSessionDevices(tx *Tx) error {
+	columns := []struct{ column, definition string }{
+		{"user_agent", "TEXT NOT NULL DEFAULT ''"},
+		{"ip_address", "TEXT NOT NULL DEFAULT ''"},
+		{"last_used_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		exists, err := columnExists(tx, "sessions", c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := tx.Exec("ALTER TABLE sessions ADD COLUMN " + c.column + " " + c.definition); err != nil {
+			return err
+		}
+		log.Printf("✅ Added sessions.%s column", c.column)
+	}
+
+	_, err := tx.Exec("UPDATE sessions SET last_used_at = created_at WHERE last_used_at IS NULL")
+	return err
+}
+
+func dropSessionDevices(tx *Tx) error {
+	return execAll(tx, []string{
+		"ALTER TABLE sessions DROP COLUMN last_used_at",
+		"ALTER TABLE sessions DROP COLUMN ip_address",
+		"ALTER TABLE sessions DROP COLUMN user_agent",
+	})
+}
+
+// AddSessionExpiry lets sessions slide: expires_at becomes the idle deadline that
+// activity pushes forward, capped by a fixed absolute_expires_at. Existing sessions
+// keep the expiry they were issued with.
+func AddSessionExpiry(tx *Tx) error {
+	columns := []struct{ column, definition string }{
+		{"remember_me", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"absolute_expires_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		exists, err := columnExists(tx, "sessions", c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := tx.Exec("ALTER TABLE sessions ADD COLUMN " + c.column + " " + c.definition); err != nil {
+			return err
+		}
+		log.Printf("✅ Added sessions.%s column", c.column)
+	}
+
+	_, err := tx.Exec("UPDATE sessions SET absolute_expires_at = expires_at WHERE absolute_expires_at IS NULL")
+	return err
+}
+
+func dropSessionExpiry(tx *Tx) error {
+	return execAll(tx, []string{
+		"ALTER TABLE sessions DROP COLUMN absolute_expires_at",
+		"ALTER TABLE sessions DROP COLUMN remember_me",
+	})
+}
+
+// AddPasswordResets creates the table of single-use password reset tokens
+func AddPasswordResets(tx *Tx) error {
+	return execAll(tx, []string{
+		`CREATE TABLE IF NOT EXISTS password_resets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets(user_id)",
+	})
+}
+
+func dropPasswordResets(tx *Tx) error {
+	return execAll(tx, []string{"DROP TABLE IF EXISTS password_resets"})
+}
+
+// AddEmailVerification tracks whether users confirmed their email address.
+// Accounts that exist already are treated as verified so they keep working.
+func AddEmailVerification(tx *Tx) error {
+	exists, err := columnExists(tx, "users", "email_verified_at")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := tx.Exec("ALTER TABLE users ADD COLUMN email_verified_at DATETIME"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE users SET email_verified_at = created_at"); err != nil {
+			return err
+		}
+		log.Println("✅ Added users.email_verified_at column")
+	}
+
+	return execAll(tx, []string{
+		`CREATE TABLE IF NOT EXISTS email_verifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_email_verifications_user ON email_verifications(user_id)",
+	})
+}
+
+func dropEmailVerification(tx *Tx) error {
+	return execAll(tx, []string{
+		"DROP TABLE IF EXISTS email_verifications",
+		"ALTER TABLE users DROP COLUMN email_verified_at",
+	})
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"strings"
 	"time"
 )
 
@@ -15,8 +16,27 @@ type User struct {
 	Gender       string    `json:"gender" db:"gender"`         // User's gender
 	FirstName    string    `json:"first_name" db:"first_name"` // User's first name
 	LastName     string    `json:"last_name" db:"last_name"`   // User's last name
+	Role         string    `json:"role,omitempty" db:"role"`   // RoleMember or RoleModerator
 	CreatedAt    time.Time `json:"created_at" db:"created_at"` // When the user account was created
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"` // When the user account was last updated
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"` // When the email address was confirmed, nil until then
+}
+
+// User roles stored in users.role
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+)
+
+// IsModerator reports whether the user may edit and delete other users' posts and comments
+func (u *User) IsModerator() bool {
+	return u.Role == RoleModerator
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // Session represents a user login session
@@ -24,12 +44,20 @@ type User struct {
 type Session struct {
 	ID        int       `json:"id" db:"id"`                 // Primary key - unique session identifier
 	UserID    int       `json:"user_id" db:"user_id"`       // Foreign key to users table
-	Token     string    `json:"token" db:"token"`           // Unique session token (stored in cookie)
-	ExpiresAt time.Time `json:"expires_at" db:"expires_at"` // When this session expires
+	Token     string    `json:"-" db:"token"`               // Unique session token (stored in cookie, never sent back)
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"` // Idle deadline, pushed forward while the session is used
 	CreatedAt time.Time `json:"created_at" db:"created_at"` // When this session was created
 
+	UserAgent  string    `json:"user_agent" db:"user_agent"`     // Browser that logged in
+	IPAddress  string    `json:"ip_address" db:"ip_address"`     // Address the login came from
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"` // Last authenticated request, updated at most once a minute
+
+	RememberMe        bool      `json:"remember_me" db:"remember_me"`                 // Longer timeouts and a persistent cookie
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at" db:"absolute_expires_at"` // Hard limit no renewal can move past
+
 	// Related data - not stored in database but populated when needed
-	User *User `json:"user,omitempty" db:"-"` // User associated with this session
+	User    *User `json:"user,omitempty" db:"-"` // User associated with this session
+	Current bool  `json:"current" db:"-"`        // Whether this is the session making the request
 }
 
 // Category represents a post category/topic
@@ -47,12 +75,13 @@ type Category struct {
 // Post represents a forum post
 // This struct maps to the 'posts' table in the database
 type Post struct {
-	ID        int       `json:"id" db:"id"`                 // Primary key - unique post identifier
-	UserID    int       `json:"user_id" db:"user_id"`       // Foreign key to users table (post author)
-	Title     string    `json:"title" db:"title"`           // Post title/subject
-	Content   string    `json:"content" db:"content"`       // Post content/body
-	CreatedAt time.Time `json:"created_at" db:"created_at"` // When this post was created
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // When this post was last updated
+	ID        int        `json:"id" db:"id"`                           // Primary key - unique post identifier
+	UserID    int        `json:"user_id" db:"user_id"`                 // Foreign key to users table (post author)
+	Title     string     `json:"title" db:"title"`                     // Post title/subject
+	Content   string     `json:"content" db:"content"`                 // Post content/body
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`           // When this post was created
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`           // When this post was last updated
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // When this post was soft-deleted
 
 	// Related data - not stored in database but populated when needed
 	Author       *User      `json:"author,omitempty" db:"-"`        // User who created this post
@@ -80,12 +109,14 @@ type PostCategory struct {
 // Comment represents a comment on a forum post
 // This struct maps to the 'comments' table in the database
 type Comment struct {
-	ID        int       `json:"id" db:"id"`                 // Primary key - unique comment identifier
-	PostID    int       `json:"post_id" db:"post_id"`       // Foreign key to posts table
-	UserID    int       `json:"user_id" db:"user_id"`       // Foreign key to users table (comment author)
-	Content   string    `json:"content" db:"content"`       // Comment content/text
-	CreatedAt time.Time `json:"created_at" db:"created_at"` // When this comment was created
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // When this comment was last updated
+	ID        int        `json:"id" db:"id"`                           // Primary key - unique comment identifier
+	PostID    int        `json:"post_id" db:"post_id"`                 // Foreign key to posts table
+	ParentID  *int       `json:"parent_id" db:"parent_id"`             // Comment this one replies to (nil for top-level comments)
+	UserID    int        `json:"user_id" db:"user_id"`                 // Foreign key to users table (comment author)
+	Content   string     `json:"content" db:"content"`                 // Comment content/text
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`           // When this comment was created
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`           // When this comment was last updated
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // When this comment was soft-deleted
 
 	// Related data - not stored in database but populated when needed
 	Author       *User `json:"author,omitempty" db:"-"`        // User who created this comment
@@ -94,8 +125,93 @@ type Comment struct {
 	DislikeCount int   `json:"dislike_count,omitempty" db:"-"` // Number of dislikes this comment has
 	UserVote     *bool `json:"user_vote,omitempty" db:"-"`     // Current user's vote on this comment
 	NetScore     int   `json:"net_score,omitempty" db:"-"`     // Likes minus dislikes
+
+	// Thread data - populated when comments are loaded as a tree
+	ReplyCount int       `json:"reply_count" db:"-"`           // Number of direct replies
+	Replies    []Comment `json:"replies,omitempty" db:"-"`     // Loaded replies, oldest first
+	NextCursor *int      `json:"next_cursor,omitempty" db:"-"` // Pass as after to load the replies not included in Replies
+}
+
+// DeletedPlaceholder replaces the text of soft-deleted posts and comments
+const DeletedPlaceholder = "[deleted]"
+
+// redact hides the title, content and author of a soft-deleted post
+func (p *Post) redact() {
+	if p.DeletedAt == nil {
+		return
+	}
+	p.Title = DeletedPlaceholder
+	p.Content = DeletedPlaceholder
+	p.UserID = 0
+	p.Author = nil
 }
 
+// setVoteStats copies vote counts and the viewer's vote onto the post
+func (p *Post) setVoteStats(stats *VoteStats) {
+	p.LikeCount = stats.LikeCount
+	p.DislikeCount = stats.DislikeCount
+	p.NetScore = stats.NetScore
+	p.UserVote = stats.UserVote
+}
+
+// redact hides the content and author of a soft-deleted comment while
+// keeping its place in the thread
+func (c *Comment) redact() {
+	if c.DeletedAt == nil {
+		return
+	}
+	c.Content = DeletedPlaceholder
+	c.UserID = 0
+	c.Author = nil
+}
+
+// PasswordReset is a single-use token for choosing a new password.
+// Only a hash of the token is stored; the token itself is only ever emailed.
+// This struct maps to the 'password_resets' table in the database
+type PasswordReset struct {
+	ID        int        `json:"id" db:"id"`                 // Primary key - unique reset identifier
+	UserID    int        `json:"user_id" db:"user_id"`       // Account whose password may be reset
+	TokenHash string     `json:"-" db:"token_hash"`          // SHA-256 of the emailed token, hex encoded
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"` // When the token stops working
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`       // When the token was redeemed, nil if unused
+	CreatedAt time.Time  `json:"created_at" db:"created_at"` // When the reset was requested
+}
+
+// EmailVerification is a token proving the owner of an email address opened
+// the link sent to it. Like PasswordReset, only a hash of the token is stored.
+// This struct maps to the 'email_verifications' table in the database
+type EmailVerification struct {
+	ID        int       `json:"id" db:"id"`                 // Primary key - unique verification identifier
+	UserID    int       `json:"user_id" db:"user_id"`       // Account whose address is being verified
+	TokenHash string    `json:"-" db:"token_hash"`          // SHA-256 of the emailed token, hex encoded
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"` // When the token stops working
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // When the link was sent
+}
+
+// Revision is one immutable version of a post or comment
+// This struct maps to the 'revisions' table in the database
+type Revision struct {
+	ID         int       `json:"id" db:"id"`                   // Primary key - unique revision identifier
+	TargetType string    `json:"target_type" db:"target_type"` // VoteTargetPost or VoteTargetComment
+	TargetID   int       `json:"target_id" db:"target_id"`     // Post or comment this is a version of
+	EditorID   int       `json:"editor_id" db:"editor_id"`     // User who made this version
+	Action     string    `json:"action" db:"action"`           // RevisionCreate, RevisionEdit or RevisionDelete
+	Title      string    `json:"title,omitempty" db:"title"`   // Post title at this version (posts only)
+	Content    string    `json:"content" db:"content"`         // Content at this version
+	Diff       string    `json:"diff" db:"diff"`               // Line diff from the previous version
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`   // When this version was made
+
+	// Related data - not stored in database but populated when needed
+	Editor *User `json:"editor,omitempty" db:"-"` // User who made this version
+}
+
+// Revision actions
+const (
+	RevisionCreate = "create" // The original version, recorded on the first edit
+	RevisionEdit   = "edit"
+	RevisionDelete = "delete"
+)
+
 // Like represents a like or dislike vote on a post or comment
 // This struct maps to the 'likes' table in the database
 type Like struct {
@@ -122,6 +238,107 @@ type ContactMessage struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"` // When this message was sent
 }
 
+// Message represents a private message between two users
+// This struct maps to the 'messages' table in the database
+type Message struct {
+	ID          int        `json:"id" db:"id"`                     // Primary key - unique message identifier
+	SenderID    int        `json:"sender_id" db:"sender_id"`       // Foreign key to users table (who sent it)
+	ReceiverID  int        `json:"receiver_id" db:"receiver_id"`   // Foreign key to users table (who receives it)
+	Content     string     `json:"content" db:"content"`           // Message text
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`     // When the message was sent
+	IsRead      bool       `json:"is_read" db:"is_read"`           // Whether the receiver has read it
+	DeliveredAt *time.Time `json:"delivered_at" db:"delivered_at"` // When it first reached one of the receiver's devices
+	ReadAt      *time.Time `json:"read_at" db:"read_at"`           // When the receiver read it
+
+	// Derived data - not stored in database
+	Status string `json:"status" db:"-"` // "sent", "delivered" or "read"
+}
+
+// Message delivery states
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
+// SetStatus derives the delivery state from the receipt timestamps
+func (m *Message) SetStatus() {
+	switch {
+	case m.ReadAt != nil:
+		m.Status = MessageStatusRead
+	case m.DeliveredAt != nil:
+		m.Status = MessageStatusDelivered
+	default:
+		m.Status = MessageStatusSent
+	}
+}
+
+// MessagePage selects one page of a conversation or room using message ID cursors.
+// With AfterID set the page holds the oldest messages newer than it; otherwise it
+// holds the newest messages older than BeforeID (or the newest overall).
+// Either way the page is returned oldest first.
+type MessagePage struct {
+	BeforeID int // Return messages with a smaller ID (0 = no cursor)
+	AfterID  int // Return messages with a larger ID (0 = no cursor)
+	Limit    int // Maximum number of messages to return
+}
+
+// Conversation summarizes a private conversation from one user's point of view
+type Conversation struct {
+	OtherUserID   int     // The other participant
+	OtherUsername string  // The other participant's username
+	LastMessage   Message // Most recent message in either direction
+	UnreadCount   int     // Messages to the viewer that are not read yet
+}
+
+// Room is a multi-member chat room
+// This struct maps to the 'conversations' table in the database
+type Room struct {
+	ID        int       `json:"id" db:"id"`                 // Primary key - unique room identifier
+	Name      string    `json:"name" db:"name"`             // Display name chosen by the creator
+	CreatedBy int       `json:"created_by" db:"created_by"` // Foreign key to users table (who created it)
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // When the room was created
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // When the last message was posted
+
+	// Joined data - not stored in the conversations table
+	Members []RoomMember `json:"members" db:"-"` // Everyone in the room, earliest joined first
+}
+
+// RoomMember is a user belonging to a room
+// This struct maps to the 'conversation_members' table in the database
+type RoomMember struct {
+	UserID   int       `json:"user_id" db:"user_id"`     // Foreign key to users table
+	Username string    `json:"username" db:"-"`          // Joined from the users table
+	Role     string    `json:"role" db:"role"`           // "owner" or "member"
+	JoinedAt time.Time `json:"joined_at" db:"joined_at"` // When the user joined
+}
+
+// Room member roles
+const (
+	RoomRoleOwner  = "owner"
+	RoomRoleMember = "member"
+)
+
+// RoomMessage is a message posted to a room
+// This struct maps to the 'conversation_messages' table in the database
+type RoomMessage struct {
+	ID             int       `json:"id" db:"id"`                   // Primary key - unique message identifier
+	RoomID         int       `json:"room_id" db:"conversation_id"` // Foreign key to conversations table
+	SenderID       int       `json:"sender_id" db:"sender_id"`     // Foreign key to users table (who sent it)
+	SenderUsername string    `json:"sender_username" db:"-"`       // Joined from the users table
+	Content        string    `json:"content" db:"content"`         // Message text
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`   // When the message was sent
+}
+
+// UserPresence is a user's last known presence
+// This struct maps to the 'user_status' table in the database
+type UserPresence struct {
+	ID       int        `json:"id" db:"user_id"`          // Foreign key to users table
+	Username string     `json:"username" db:"-"`          // Joined from the users table
+	Online   bool       `json:"online" db:"is_online"`    // Whether the user has an open connection
+	LastSeen *time.Time `json:"last_seen" db:"last_seen"` // When the user last connected or disconnected (nil = never)
+}
+
 // PostFilter represents filters for querying posts
 // This struct is used for filtering posts by various criteria
 type PostFilter struct {
@@ -136,6 +353,63 @@ type PostFilter struct {
 	SortOrder  string `json:"sort_order,omitempty"`  // Sort direction (ASC, DESC)
 	MinLikes   *int   `json:"min_likes,omitempty"`   // Minimum number of likes
 	MaxAge     *int   `json:"max_age,omitempty"`     // Maximum age in days
+	Rank       string `json:"rank,omitempty"`        // Ranking mode (hot, top, controversial, new); overrides SortBy
+	Window     string `json:"window,omitempty"`      // Time window for top (day, week, month, all)
+	ViewerID   int    `json:"-"`                     // User whose own votes fill Post.UserVote (0 = anonymous)
+}
+
+// Sort fields accepted in PostFilter.SortBy
+const (
+	PostSortCreatedAt = "created_at"
+	PostSortTitle     = "title"
+	PostSortLikes     = "likes"
+)
+
+// Sort directions accepted in PostFilter.SortOrder
+const (
+	SortAsc  = "ASC"
+	SortDesc = "DESC"
+)
+
+// Validate checks the sort options against the whitelist and the paging bounds.
+// Empty SortBy and SortOrder mean newest first.
+func (f PostFilter) Validate() error {
+	switch f.Rank {
+	case "", RankHot, RankTop, RankControversial, RankNew:
+	default:
+		return ValidationError{Field: "rank", Message: "rank must be one of hot, top, controversial, new", Code: "invalid_sort"}
+	}
+	switch f.Window {
+	case "", WindowDay, WindowWeek, WindowMonth, WindowAll:
+	default:
+		return ValidationError{Field: "window", Message: "window must be one of day, week, month, all", Code: "invalid_sort"}
+	}
+	if f.Rank != "" && f.SortBy != "" {
+		return ValidationError{Field: "sort_by", Message: "use either rank or sort_by, not both", Code: "invalid_sort"}
+	}
+
+	switch f.SortBy {
+	case "", PostSortCreatedAt, PostSortTitle, PostSortLikes:
+	default:
+		return ValidationError{Field: "sort_by", Message: "sort_by must be one of created_at, title, likes", Code: "invalid_sort"}
+	}
+
+	switch strings.ToUpper(f.SortOrder) {
+	case "", SortAsc, SortDesc:
+	default:
+		return ValidationError{Field: "sort_order", Message: "sort_order must be asc or desc", Code: "invalid_sort"}
+	}
+
+	if f.Limit < 0 || f.Offset < 0 {
+		return ValidationError{Field: "limit", Message: "limit and offset cannot be negative", Code: "invalid_range"}
+	}
+	if f.MinLikes != nil && *f.MinLikes < 0 {
+		return ValidationError{Field: "min_likes", Message: "min_likes cannot be negative", Code: "invalid_range"}
+	}
+	if f.MaxAge != nil && *f.MaxAge <= 0 {
+		return ValidationError{Field: "max_age", Message: "max_age must be a positive number of days", Code: "invalid_range"}
+	}
+	return nil
 }
 
 // VoteStats represents aggregated voting statistics
@@ -217,13 +491,36 @@ type PaginationInfo struct {
 	EndItem      int  `json:"end_item"`       // Index of last item on current page
 }
 
+// NewPaginationInfo describes the page starting at offset for a list of total items
+func NewPaginationInfo(total, limit, offset int) PaginationInfo {
+	if limit <= 0 {
+		limit = total
+	}
+
+	info := PaginationInfo{TotalItems: total, ItemsPerPage: limit, CurrentPage: 1}
+	if limit > 0 {
+		info.CurrentPage = offset/limit + 1
+		info.TotalPages = (total + limit - 1) / limit
+	}
+	info.HasPrev = offset > 0
+	info.HasNext = offset+limit < total
+	if offset < total {
+		info.StartItem = offset + 1
+		info.EndItem = offset + limit
+		if info.EndItem > total {
+			info.EndItem = total
+		}
+	}
+	return info
+}
+
 // SearchResult represents a search result item
 // This struct is used for search functionality across posts and comments
 type SearchResult struct {
 	Type      string    `json:"type"`              // "post" or "comment"
 	ID        int       `json:"id"`                // ID of the post or comment
-	Title     string    `json:"title"`             // Title (for posts) or excerpt (for comments)
-	Content   string    `json:"content"`           // Content excerpt with highlighted terms
+	Title     string    `json:"title"`             // Post title (the parent post's title for comments)
+	Content   string    `json:"content"`           // HTML-escaped excerpt with matches wrapped in <mark>
 	Author    string    `json:"author"`            // Author username
 	CreatedAt time.Time `json:"created_at"`        // When it was created
 	Relevance float64   `json:"relevance"`         // Search relevance score
@@ -231,6 +528,24 @@ type SearchResult struct {
 	PostID    *int      `json:"post_id,omitempty"` // Parent post ID (for comments)
 }
 
+// Search result types
+const (
+	SearchTypePost    = "post"
+	SearchTypeComment = "comment"
+)
+
+// SearchQuery describes a full-text search over posts and comments
+type SearchQuery struct {
+	Query      string     // Words to search for; every word must match
+	Type       string     // SearchTypePost, SearchTypeComment or "" for both
+	CategoryID *int       // Only posts in this category, and comments on them
+	Author     string     // Only content written by this username
+	Since      *time.Time // Only content created at or after this time
+	Until      *time.Time // Only content created before this time
+	Limit      int        // Maximum number of results to return
+	Offset     int        // Number of results to skip (for pagination)
+}
+
 // ActivityLog represents user activity logging
 // This struct is used for tracking user actions for security and analytics
 type ActivityLog struct {
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"math"
+	"time"
+)
+
+// Ranking modes accepted in PostFilter.Rank
+const (
+	RankHot           = "hot"           // Net score decayed by age
+	RankTop           = "top"           // Highest net score within PostFilter.Window
+	RankControversial = "controversial" // Many votes, evenly split between likes and dislikes
+	RankNew           = "new"           // Newest first
+)
+
+// Time windows accepted in PostFilter.Window for RankTop
+const (
+	WindowDay   = "day"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+	WindowAll   = "all"
+)
+
+// hotEpoch anchors hot scores; only differences between scores matter
+var hotEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// hotDecay is how many seconds newer a post must be to outrank one with ten times its score
+const hotDecay = 45000.0 // 12.5 hours
+
+// HotScore ranks a post by the order of magnitude of its net score plus its age.
+// A post's hot score only changes when it is voted on, which is what lets it be
+// cached in post_scores: newer posts simply start higher.
+func HotScore(likes, dislikes int, createdAt time.Time) float64 {
+	score := float64(likes - dislikes)
+	order := math.Log10(math.Max(math.Abs(score), 1))
+
+	sign := 0.0
+	if score > 0 {
+		sign = 1
+	} else if score < 0 {
+		sign = -1
+	}
+
+	return sign*order + createdAt.Sub(hotEpoch).Seconds()/hotDecay
+}
+
+// ControversyScore is high when a post has many votes split evenly between likes
+// and dislikes, and zero when either side has no votes
+func ControversyScore(likes, dislikes int) float64 {
+	if likes <= 0 || dislikes <= 0 {
+		return 0
+	}
+
+	magnitude := float64(likes + dislikes)
+	balance := float64(dislikes) / float64(likes)
+	if likes < dislikes {
+		balance = float64(likes) / float64(dislikes)
+	}
+	return math.Pow(magnitude, balance)
+}
+
+// WindowStart returns the earliest creation time included in a top window,
+// or nil for WindowAll and the empty window
+func WindowStart(window string, now time.Time) *time.Time {
+	var start time.Time
+	switch window {
+	case WindowDay:
+		start = now.AddDate(0, 0, -1)
+	case WindowWeek:
+		start = now.AddDate(0, 0, -7)
+	case WindowMonth:
+		start = now.AddDate(0, -1, 0)
+	default:
+		return nil
+	}
+	return &start
+}
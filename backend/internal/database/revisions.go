@@ -0,0 +1,121 @@
+package database
+
+import "strings"
+
+// maxDiffLines caps the lines compared by LineDiff; longer texts are diffed
+// as a whole replacement to bound the comparison time
+const maxDiffLines = 2000
+
+// LineDiff compares two texts line by line and returns every line prefixed
+// with "  " when unchanged, "- " when removed or "+ " when added.
+// Returns "" when the texts are equal.
+func LineDiff(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	var out strings.Builder
+	write := func(prefix string, lines []string) {
+		for _, line := range lines {
+			out.WriteString(prefix + line + "\n")
+		}
+	}
+
+	// Edits usually touch a few lines, so only the changed middle is compared
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	write("  ", a[:prefix])
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(middleA) > maxDiffLines || len(middleB) > maxDiffLines {
+		write("- ", middleA)
+		write("+ ", middleB)
+	} else {
+		diffLines(middleA, middleB, write)
+	}
+	write("  ", a[len(a)-suffix:])
+	return out.String()
+}
+
+// diffLines writes a shortest line diff of a and b using Hirschberg's
+// algorithm, which needs memory linear in the length of b
+func diffLines(a, b []string, write func(prefix string, lines []string)) {
+	switch {
+	case len(a) == 0:
+		write("+ ", b)
+		return
+	case len(b) == 0:
+		write("- ", a)
+		return
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				write("+ ", b[:j])
+				write("  ", a)
+				write("+ ", b[j+1:])
+				return
+			}
+		}
+		write("- ", a)
+		write("+ ", b)
+		return
+	}
+
+	// Split b where the best common subsequences of the two halves of a meet
+	mid := len(a) / 2
+	forward := commonLengths(a[:mid], b)
+	backward := commonLengths(reversed(a[mid:]), reversed(b))
+	split, best := 0, -1
+	for j := 0; j <= len(b); j++ {
+		if length := forward[j] + backward[len(b)-j]; length > best {
+			split, best = j, length
+		}
+	}
+
+	diffLines(a[:mid], b[:split], write)
+	diffLines(a[mid:], b[split:], write)
+}
+
+// commonLengths returns, for every j, the length of the longest common
+// subsequence of a and b[:j], keeping only two rows of the table
+func commonLengths(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// reversed returns a reversed copy of lines
+func reversed(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[len(lines)-1-i] = line
+	}
+	return out
+}
+
+// revisionText is the text diffed between revisions: a post's title line,
+// a blank line and its content, or a comment's content
+func revisionText(rev Revision) string {
+	if rev.TargetType == VoteTargetPost {
+		return rev.Title + "\n\n" + rev.Content
+	}
+	return rev.Content
+}
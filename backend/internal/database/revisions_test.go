@@ -0,0 +1,90 @@
+package database
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{"equal", "a\nb", "a\nb", ""},
+		{"added line", "a\nc", "a\nb\nc", "  a\n+ b\n  c\n"},
+		{"removed line", "a\nb\nc", "a\nc", "  a\n- b\n  c\n"},
+		{"changed line", "a\nb\nc", "a\nx\nc", "  a\n- b\n+ x\n  c\n"},
+		{"from empty", "", "a", "- \n+ a\n"},
+		{"moved line", "a\nb\nc\nd", "b\nc\nd\na", "- a\n  b\n  c\n  d\n+ a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LineDiff(tt.before, tt.after); got != tt.want {
+				t.Errorf("LineDiff(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLineDiffRandom checks that diffs of random texts rebuild both sides and
+// keep as many lines as the longest common subsequence allows
+func TestLineDiffRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for i := 0; i < 500; i++ {
+		before, after := randomText(), randomText()
+		diff := LineDiff(before, after)
+		if before == after {
+			if diff != "" {
+				t.Fatalf("LineDiff of equal texts %q = %q", before, diff)
+			}
+			continue
+		}
+
+		var gotBefore, gotAfter []string
+		kept := 0
+		for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+			prefix, text := line[:2], line[2:]
+			if prefix != "+ " {
+				gotBefore = append(gotBefore, text)
+			}
+			if prefix != "- " {
+				gotAfter = append(gotAfter, text)
+			}
+			if prefix == "  " {
+				kept++
+			}
+		}
+		if strings.Join(gotBefore, "\n") != before || strings.Join(gotAfter, "\n") != after {
+			t.Fatalf("LineDiff(%q, %q) = %q does not rebuild both texts", before, after, diff)
+		}
+
+		a, b := strings.Split(before, "\n"), strings.Split(after, "\n")
+		if want := commonLengths(a, b)[len(b)]; kept != want {
+			t.Fatalf("LineDiff(%q, %q) kept %d lines, want %d", before, after, kept, want)
+		}
+	}
+}
+
+func BenchmarkLineDiff(b *testing.B) {
+	lines := make([]string, maxDiffLines)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", i%50)
+	}
+	before := strings.Join(lines, "\n")
+	after := strings.Join(reversed(lines), "\n")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		LineDiff(before, after)
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms caps how many words of a query are matched
+const maxSearchTerms = 10
+
+// Sentinels the database wraps around matched words in excerpts.
+// They cannot appear in user text, so the excerpt can be HTML-escaped
+// before they are swapped for <mark> tags.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// searchTerms splits a query into lowercase words, dropping punctuation and duplicates.
+// Only letters and digits survive, so the terms are safe to embed in FTS query syntax.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), isNotWordRune) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// excerptWords is how many words an excerpt keeps, as in the full-text snippets
+const excerptWords = 32
+
+// excerpt cuts text marked by markTerms down to excerptWords words around its
+// first match, or to its first words if nothing in it matched, adding "…" where it was cut
+func excerpt(marked string) string {
+	words := strings.Fields(marked)
+	if len(words) <= excerptWords {
+		return marked
+	}
+
+	// Keep a little context before the match, like snippet() does
+	start := 0
+	for i, word := range words {
+		if strings.Contains(word, matchStart) {
+			start = i - excerptWords/4
+			break
+		}
+	}
+	start = max(0, min(start, len(words)-excerptWords))
+	end := start + excerptWords
+
+	cut := strings.Join(words[start:end], " ")
+	if start > 0 {
+		cut = "…" + cut
+	}
+	if end < len(words) {
+		cut += "…"
+	}
+	return cut
+}
+
+// highlight escapes an excerpt for HTML and turns the match sentinels into <mark> tags
+func highlight(text string) string {
+	escaped := html.EscapeString(text)
+	return strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>").Replace(escaped)
+}
+
+// markTerms wraps every word of text that starts with one of the terms in match
+// sentinels. Returns the marked text, the number of marked words and which terms matched.
+func markTerms(text string, terms []string) (string, int, map[string]bool) {
+	var b strings.Builder
+	hits := 0
+	matched := make(map[string]bool)
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if isNotWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(runes) && !isNotWordRune(runes[end]) {
+			end++
+		}
+		word := string(runes[i:end])
+		lower := strings.ToLower(word)
+
+		hit := false
+		for _, term := range terms {
+			if strings.HasPrefix(lower, term) {
+				matched[term] = true
+				hit = true
+			}
+		}
+		if hit {
+			hits++
+			b.WriteString(matchStart + word + matchEnd)
+		} else {
+			b.WriteString(word)
+		}
+		i = end
+	}
+
+	return b.String(), hits, matched
+}
+
+// allMatched reports whether every term appears in at least one of the match sets
+func allMatched(terms []string, sets ...map[string]bool) bool {
+	for _, term := range terms {
+		found := false
+		for _, set := range sets {
+			if set[term] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// searchResultURL returns the frontend route showing a post or comment
+func searchResultURL(postID int) string {
+	return "#/post/" + strconv.Itoa(postID)
+}
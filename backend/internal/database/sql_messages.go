@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// messageColumns is the column list expected by scanMessage
+const messageColumns = "id, sender_id, receiver_id, content, created_at, is_read, delivered_at, read_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMessage reads a message selected with messageColumns, followed by any extra columns
+func scanMessage(row rowScanner, extra ...interface{}) (Message, error) {
+	var msg Message
+	var deliveredAt, readAt sql.NullTime
+
+	dest := append(extra, &msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt,
+		&msg.IsRead, &deliveredAt, &readAt)
+	if err := row.Scan(dest...); err != nil {
+		return msg, err
+	}
+
+	if deliveredAt.Valid {
+		msg.DeliveredAt = &deliveredAt.Time
+	}
+	if readAt.Valid {
+		msg.ReadAt = &readAt.Time
+	}
+	msg.SetStatus()
+
+	return msg, nil
+}
+
+// CreateMessage inserts a message and sets its ID
+func (s *SQLStore) CreateMessage(msg *Message) error {
+	id, err := s.db.Insert(`
+		INSERT INTO messages (sender_id, receiver_id, content, created_at, is_read)
+		VALUES (?, ?, ?, ?, ?)
+	`, msg.SenderID, msg.ReceiverID, msg.Content, msg.CreatedAt, msg.IsRead)
+	if err != nil {
+		return err
+	}
+	msg.ID = id
+	msg.SetStatus()
+	return nil
+}
+
+// MarkDelivered records when a message reached the receiver
+func (s *SQLStore) MarkDelivered(messageID int, at time.Time) error {
+	_, err := s.db.Exec("UPDATE messages SET delivered_at = ? WHERE id = ?", at, messageID)
+	return err
+}
+
+// MarkConversationDelivered marks every undelivered message from senderID to receiverID as delivered
+func (s *SQLStore) MarkConversationDelivered(senderID, receiverID int, at time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE messages
+		SET delivered_at = ?
+		WHERE sender_id = ? AND receiver_id = ? AND delivered_at IS NULL
+	`, at, senderID, receiverID)
+	return err
+}
+
+// MarkRead marks unread messages from senderID to readerID with id <= upToID as read
+func (s *SQLStore) MarkRead(readerID, senderID, upToID int, at time.Time) (int, error) {
+	result, err := s.db.Exec(`
+		UPDATE messages
+		SET is_read = ?, read_at = ?, delivered_at = COALESCE(delivered_at, ?)
+		WHERE sender_id = ? AND receiver_id = ? AND id <= ? AND read_at IS NULL
+	`, true, at, at, senderID, readerID, upToID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// ListMessages returns one page of the conversation between two users
+func (s *SQLStore) ListMessages(userID, otherID int, page MessagePage) ([]Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))
+	`
+	args := []interface{}{userID, otherID, otherID, userID}
+	switch {
+	case page.AfterID > 0:
+		query += " AND id > ? ORDER BY id ASC LIMIT ?"
+		args = append(args, page.AfterID)
+	case page.BeforeID > 0:
+		query += " AND id < ? ORDER BY id DESC LIMIT ?"
+		args = append(args, page.BeforeID)
+	default:
+		query += " ORDER BY id DESC LIMIT ?"
+	}
+	args = append(args, page.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Backward pages are scanned newest first
+	if page.AfterID == 0 {
+		reverseMessages(messages)
+	}
+	return messages, nil
+}
+
+// reverseMessages reverses messages in place
+func reverseMessages(messages []Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// ListConversations returns one entry per counterpart, most recent first.
+// One pass over the user's messages groups them by counterpart; the last message
+// is the highest ID in each group since IDs increase with send time.
+func (s *SQLStore) ListConversations(userID int) ([]Conversation, error) {
+	rows, err := s.db.Query(`
+		WITH threads AS (
+			SELECT
+				CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS other_id,
+				MAX(id) AS last_id,
+				SUM(CASE WHEN receiver_id = ? AND read_at IS NULL THEN 1 ELSE 0 END) AS unread
+			FROM messages
+			WHERE sender_id = ? OR receiver_id = ?
+			GROUP BY other_id
+		)
+		SELECT t.other_id, u.username, t.unread,
+			m.id, m.sender_id, m.receiver_id, m.content, m.created_at, m.is_read, m.delivered_at, m.read_at
+		FROM threads t
+		JOIN messages m ON m.id = t.last_id
+		JOIN users u ON u.id = t.other_id
+		ORDER BY m.created_at DESC, m.id DESC
+	`, userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var conv Conversation
+		conv.LastMessage, err = scanMessage(rows, &conv.OtherUserID, &conv.OtherUsername, &conv.UnreadCount)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conv)
+	}
+	return conversations, rows.Err()
+}
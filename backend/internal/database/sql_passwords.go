@@ -0,0 +1,65 @@
+package database
+
+import "time"
+
+// CreatePasswordReset stores a reset token and sets its ID, voiding the
+// user's earlier unused tokens
+func (s *SQLStore) CreatePasswordReset(reset *PasswordReset) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL", reset.UserID); err != nil {
+		return err
+	}
+
+	id, err := tx.Insert("INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+		reset.UserID, reset.TokenHash, reset.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	reset.ID = id
+	return nil
+}
+
+// ResetPassword redeems an unused, unexpired token and sets its user's
+// password hash in one step, returning the user's ID.
+// Returns ErrNotFound if the token is unknown, used or expired.
+func (s *SQLStore) ResetPassword(tokenHash, passwordHash string, now time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Claiming the token first makes it single-use even under concurrent requests
+	result, err := tx.Exec(`
+		UPDATE password_resets SET used_at = ?
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+	`, now, tokenHash, now)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, ErrNotFound
+	}
+
+	var userID int
+	if err := tx.QueryRow("SELECT user_id FROM password_resets WHERE token_hash = ?", tokenHash).Scan(&userID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?", passwordHash, now, userID); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
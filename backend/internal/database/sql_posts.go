@@ -0,0 +1,435 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// POSTS
+
+// CreatePost inserts a post with its categories in one transaction and sets its ID
+func (s *SQLStore) CreatePost(post *Post, categoryIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	postID, err := tx.Insert(`
+		INSERT INTO posts (user_id, title, content)
+		VALUES (?, ?, ?)
+	`, post.UserID, post.Title, post.Content)
+	if err != nil {
+		return err
+	}
+
+	for _, categoryID := range categoryIDs {
+		_, err = tx.Exec(`
+			INSERT INTO post_categories (post_id, category_id)
+			VALUES (?, ?)
+		`, postID, categoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Give the post its starting hot score so it ranks before anyone votes
+	if err := refreshPostScore(tx, postID, 0, 0); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	post.ID = postID
+	return nil
+}
+
+// GetPost returns a post with its author and categories.
+// Soft-deleted posts are returned redacted.
+func (s *SQLStore) GetPost(id int) (*Post, error) {
+	var post Post
+	var deletedAt sql.NullTime
+	post.Author = &User{}
+
+	err := s.db.QueryRow(`
+		SELECT p.id, p.user_id, u.username, u.email, p.title, p.content, p.created_at, p.updated_at,
+			p.deleted_at, `+postCommentCount+`
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id = ?
+	`, id).Scan(&post.ID, &post.UserID, &post.Author.Username, &post.Author.Email,
+		&post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &deletedAt, &post.CommentCount)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	post.Author.ID = post.UserID
+	if deletedAt.Valid {
+		post.DeletedAt = &deletedAt.Time
+		post.redact()
+	}
+
+	posts := []Post{post}
+	if err := loadPostCategories(s.db, posts); err != nil {
+		return nil, err
+	}
+
+	return &posts[0], nil
+}
+
+// postScores joins each post's cached vote counts and ranking scores as ps
+const postScores = " LEFT JOIN post_scores ps ON ps.post_id = p.id"
+
+// postCommentCount selects the number of comments on post p
+const postCommentCount = "(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id)"
+
+// postSortColumns maps PostFilter.SortBy to the expression to order by
+var postSortColumns = map[string]string{
+	PostSortCreatedAt: "p.created_at",
+	PostSortTitle:     "LOWER(p.title)",
+	PostSortLikes:     "COALESCE(ps.likes, 0)",
+}
+
+// ListPosts returns posts matching the filter with authors, categories, comment
+// counts and vote counts, ordered by filter.Rank or filter.SortBy (newest first
+// by default). The page is loaded with two queries however many posts it holds.
+func (s *SQLStore) ListPosts(filter PostFilter) ([]Post, error) {
+	return s.listPosts(s.db, filter)
+}
+
+// listPosts runs ListPosts through db
+func (s *SQLStore) listPosts(db dbtx, filter PostFilter) ([]Post, error) {
+	// Vote counts come from the same post_scores row the ranking uses;
+	// the viewer's own vote is a lookup on the unique (user, post) vote
+	userVote := "NULL"
+	var args []interface{}
+	if filter.ViewerID != 0 {
+		userVote = "(SELECT v.vote_type FROM votes v WHERE v.post_id = p.id AND v.user_id = ?)"
+		args = append(args, filter.ViewerID)
+	}
+
+	where, whereArgs := s.postFilterWhere(filter)
+	args = append(args, whereArgs...)
+	query := `
+		SELECT p.id, p.user_id, u.username, p.title, p.content, p.created_at, p.updated_at, ` + postCommentCount + `,
+			COALESCE(ps.likes, 0), COALESCE(ps.dislikes, 0), ` + userVote + `
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+	` + postScores + where
+
+	if rankOrder, ok := postRankOrders[filter.Rank]; ok {
+		query += " ORDER BY " + rankOrder
+	} else {
+		column, ok := postSortColumns[filter.SortBy]
+		if !ok {
+			column = postSortColumns[PostSortCreatedAt]
+		}
+		order := SortDesc
+		if strings.ToUpper(filter.SortOrder) == SortAsc {
+			order = SortAsc
+		}
+		query += " ORDER BY " + column + " " + order + ", p.id " + order
+	}
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		var stats VoteStats
+		var userVote sql.NullInt64
+		post.Author = &User{}
+
+		err := rows.Scan(&post.ID, &post.UserID, &post.Author.Username, &post.Title, &post.Content,
+			&post.CreatedAt, &post.UpdatedAt, &post.CommentCount, &stats.LikeCount, &stats.DislikeCount, &userVote)
+		if err != nil {
+			return nil, err
+		}
+		post.Author.ID = post.UserID
+		stats.finish(filter.ViewerID, userVote)
+		post.setVoteStats(&stats)
+
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Categories are loaded after the rows are closed so the connection is free
+	rows.Close()
+	if err := loadPostCategories(db, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// CountPosts returns how many posts match the filter, ignoring Limit and Offset
+func (s *SQLStore) CountPosts(filter PostFilter) (int, error) {
+	where, args := s.postFilterWhere(filter)
+
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM posts p"+postScores+where, args...).Scan(&count)
+	return count, err
+}
+
+// postFilterWhere builds the WHERE clause for a filter over posts p joined with postScores.
+// Soft-deleted posts never match.
+func (s *SQLStore) postFilterWhere(filter PostFilter) (string, []interface{}) {
+	conditions := []string{"p.deleted_at IS NULL"}
+	var args []interface{}
+
+	if filter.CategoryID != nil {
+		conditions = append(conditions, "p.id IN (SELECT post_id FROM post_categories WHERE category_id = ?)")
+		args = append(args, *filter.CategoryID)
+	}
+	if filter.UserID != nil {
+		conditions = append(conditions, "p.user_id = ?")
+		args = append(args, *filter.UserID)
+	}
+	if filter.LikedBy != nil {
+		conditions = append(conditions, "p.id IN ("+PostsVotedBySubquery+")")
+		args = append(args, *filter.LikedBy, VoteLike)
+	}
+	if filter.DislikedBy != nil {
+		conditions = append(conditions, "p.id IN ("+PostsVotedBySubquery+")")
+		args = append(args, *filter.DislikedBy, VoteDislike)
+	}
+	if filter.MinLikes != nil {
+		conditions = append(conditions, "COALESCE(ps.likes, 0) >= ?")
+		args = append(args, *filter.MinLikes)
+	}
+	if filter.MaxAge != nil {
+		conditions = append(conditions, "p.created_at >= ?")
+		args = append(args, time.Now().UTC().AddDate(0, 0, -*filter.MaxAge))
+	}
+	if filter.Rank == RankTop {
+		if start := WindowStart(filter.Window, time.Now().UTC()); start != nil {
+			conditions = append(conditions, "p.created_at >= ?")
+			args = append(args, *start)
+		}
+	}
+	if terms := searchTerms(filter.Search); len(terms) > 0 {
+		if s.fullTextIndexed() {
+			conditions = append(conditions, s.postMatchCondition())
+			args = append(args, s.matchExpression(terms))
+		} else {
+			likes, likeArgs := likeConditions(terms, "p.title", "p.content")
+			conditions = append(conditions, likes...)
+			args = append(args, likeArgs...)
+		}
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// PostExists reports whether a post with the given ID exists and is not deleted
+func (s *SQLStore) PostExists(id int) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM posts WHERE id = ? AND deleted_at IS NULL", id).Scan(&count)
+	return count > 0, err
+}
+
+// ListCategories returns every category ordered by name
+func (s *SQLStore) ListCategories() ([]Category, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, created_at
+		FROM categories
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.CreatedAt); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// loadPostCategories fills in the categories of every post with a single query
+func loadPostCategories(db dbtx, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	args := make([]interface{}, len(posts))
+	for i, post := range posts {
+		index[post.ID] = i
+		args[i] = post.ID
+	}
+
+	rows, err := db.Query(`
+		SELECT pc.post_id, c.id, c.name, c.created_at
+		FROM post_categories pc
+		JOIN categories c ON c.id = pc.category_id
+		WHERE pc.post_id IN (`+placeholders(len(posts))+`)
+		ORDER BY c.name
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var category Category
+		if err := rows.Scan(&postID, &category.ID, &category.Name, &category.CreatedAt); err != nil {
+			return err
+		}
+		i := index[postID]
+		posts[i].Categories = append(posts[i].Categories, category)
+	}
+	return rows.Err()
+}
+
+// COMMENTS
+
+// CreateComment inserts a comment and sets its ID and timestamps
+func (s *SQLStore) CreateComment(comment *Comment) error {
+	if comment.ParentID != nil {
+		parent, err := s.GetComment(*comment.ParentID)
+		if err == ErrNotFound || (err == nil && (parent.PostID != comment.PostID || parent.DeletedAt != nil)) {
+			return ErrInvalidParent
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	id, err := s.db.Insert(`
+		INSERT INTO comments (post_id, parent_id, user_id, content)
+		VALUES (?, ?, ?, ?)
+	`, comment.PostID, comment.ParentID, comment.UserID, comment.Content)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	comment.ID = id
+	comment.CreatedAt = now
+	comment.UpdatedAt = now
+	return nil
+}
+
+// commentColumns selects a comment c and its author u in the order scanComment reads
+const commentColumns = "c.id, c.post_id, c.parent_id, c.user_id, u.username, c.content, c.created_at, c.updated_at, c.deleted_at"
+
+// scanComment reads any extra columns followed by a comment selected with commentColumns.
+// Soft-deleted comments are redacted.
+func scanComment(row rowScanner, extra ...interface{}) (Comment, error) {
+	var comment Comment
+	var parentID sql.NullInt64
+	var deletedAt sql.NullTime
+	comment.Author = &User{}
+
+	dest := append(extra, &comment.ID, &comment.PostID, &parentID, &comment.UserID, &comment.Author.Username,
+		&comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &deletedAt)
+	if err := row.Scan(dest...); err != nil {
+		return comment, err
+	}
+
+	comment.Author.ID = comment.UserID
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		comment.ParentID = &id
+	}
+	if deletedAt.Valid {
+		comment.DeletedAt = &deletedAt.Time
+		comment.redact()
+	}
+
+	return comment, nil
+}
+
+// GetComment returns a comment with its author, redacted if soft-deleted
+func (s *SQLStore) GetComment(id int) (*Comment, error) {
+	row := s.db.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.id = ?
+	`, id)
+
+	comment, err := scanComment(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &comment, nil
+}
+
+// ListReplies returns up to limit replies to each of parentIDs with an ID
+// greater than afterID, with their authors and reply counts, grouped by parent
+// and oldest first. A nil parentIDs lists the post's top-level comments.
+// Soft-deleted comments keep their place in the thread, redacted.
+func (s *SQLStore) ListReplies(postID int, parentIDs []int, afterID, limit int) ([]Comment, error) {
+	parentCondition := "c.parent_id IS NULL"
+	args := []interface{}{postID, afterID}
+	if parentIDs != nil {
+		if len(parentIDs) == 0 {
+			return nil, nil
+		}
+		parentCondition = "c.parent_id IN (" + placeholders(len(parentIDs)) + ")"
+		for _, id := range parentIDs {
+			args = append(args, id)
+		}
+	}
+	args = append(args, limit)
+
+	// Number each parent's replies so the limit applies per parent
+	rows, err := s.db.Query(`
+		SELECT c.*
+		FROM (
+			SELECT (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count,
+				ROW_NUMBER() OVER (PARTITION BY c.parent_id ORDER BY c.id) AS position,
+				`+commentColumns+`
+			FROM comments c
+			JOIN users u ON c.user_id = u.id
+			WHERE c.post_id = ? AND c.id > ? AND `+parentCondition+`
+		) c
+		WHERE c.position <= ?
+		ORDER BY c.parent_id, c.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	for rows.Next() {
+		var replyCount, position int
+		comment, err := scanComment(rows, &replyCount, &position)
+		if err != nil {
+			return nil, err
+		}
+		comment.ReplyCount = replyCount
+
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// GetCommentPostID returns the post a comment belongs to
+func (s *SQLStore) GetCommentPostID(commentID int) (int, error) {
+	var postID int
+	err := s.db.QueryRow("SELECT post_id FROM comments WHERE id = ?", commentID).Scan(&postID)
+	return postID, notFound(err)
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// countingDB counts the statements run through it
+type countingDB struct {
+	dbtx
+	queries int
+}
+
+func (c *countingDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	c.queries++
+	return c.dbtx.Exec(query, args...)
+}
+
+func (c *countingDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	c.queries++
+	return c.dbtx.Query(query, args...)
+}
+
+func (c *countingDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	c.queries++
+	return c.dbtx.QueryRow(query, args...)
+}
+
+// seedPosts creates users users and posts posts, each post voted on by every user
+// (liked by even user indexes, disliked by odd ones), and returns the user IDs
+func seedPosts(t testing.TB, s *SQLStore, users, posts int) []int {
+	t.Helper()
+
+	var userIDs []int
+	for i := 0; i < users; i++ {
+		user := &User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i),
+			PasswordHash: "x", Age: 30, FirstName: "Test", LastName: "User"}
+		if err := s.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	for i := 0; i < posts; i++ {
+		post := &Post{UserID: userIDs[i%users], Title: fmt.Sprintf("Post %d", i), Content: "Some post content"}
+		if err := s.CreatePost(post, []int{1, 2}); err != nil {
+			t.Fatal(err)
+		}
+		for j, userID := range userIDs {
+			vote := VoteLike
+			if j%2 == 1 {
+				vote = VoteDislike
+			}
+			if _, err := s.ToggleVote(userID, VoteTargetPost, post.ID, vote); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return userIDs
+}
+
+func TestListPostsVoteCounts(t *testing.T) {
+	s := newTestSQLStore(t)
+	userIDs := seedPosts(t, s, 3, 2)
+
+	for _, viewer := range []struct {
+		id   int
+		want *bool
+	}{
+		{0, nil},
+		{userIDs[0], boolPtr(true)},
+		{userIDs[1], boolPtr(false)},
+	} {
+		posts, err := s.ListPosts(PostFilter{Limit: 10, ViewerID: viewer.id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 2 {
+			t.Fatalf("got %d posts, want 2", len(posts))
+		}
+		for _, post := range posts {
+			if post.LikeCount != 2 || post.DislikeCount != 1 || post.NetScore != 1 {
+				t.Errorf("post %d counts = %d/%d (net %d), want 2/1 (net 1)", post.ID, post.LikeCount, post.DislikeCount, post.NetScore)
+			}
+			if (post.UserVote == nil) != (viewer.want == nil) || (post.UserVote != nil && *post.UserVote != *viewer.want) {
+				t.Errorf("viewer %d: post %d user vote = %v, want %v", viewer.id, post.ID, post.UserVote, viewer.want)
+			}
+			if len(post.Categories) != 2 {
+				t.Errorf("post %d has %d categories, want 2", post.ID, len(post.Categories))
+			}
+		}
+	}
+}
+
+// BenchmarkListPosts reports the queries each page of the listing takes,
+// which stays the same whatever the page size
+func BenchmarkListPosts(b *testing.B) {
+	s := newTestSQLStore(b)
+	userIDs := seedPosts(b, s, 4, 100)
+
+	for _, size := range []int{10, 50, 100} {
+		b.Run(fmt.Sprintf("page=%d", size), func(b *testing.B) {
+			db := &countingDB{dbtx: s.db}
+			filter := PostFilter{Limit: size, ViewerID: userIDs[0]}
+			for i := 0; i < b.N; i++ {
+				posts, err := s.listPosts(db, filter)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(posts) != size {
+					b.Fatalf("got %d posts, want %d", len(posts), size)
+				}
+			}
+			b.ReportMetric(float64(db.queries)/float64(b.N), "queries/page")
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
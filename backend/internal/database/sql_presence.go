@@ -0,0 +1,52 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SavePresence records a user's online state and last-seen time
+func (s *SQLStore) SavePresence(userID int, online bool, lastSeen time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_status (user_id, is_online, last_seen)
+		VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			is_online = excluded.is_online,
+			last_seen = excluded.last_seen
+	`, userID, online, lastSeen)
+	return err
+}
+
+// ResetPresence marks every user offline
+func (s *SQLStore) ResetPresence() error {
+	_, err := s.db.Exec("UPDATE user_status SET is_online = ? WHERE is_online = ?", false, true)
+	return err
+}
+
+// ListPresence returns every user with their last recorded presence, ordered by username
+func (s *SQLStore) ListPresence() ([]UserPresence, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username, COALESCE(s.is_online, ?), s.last_seen
+		FROM users u
+		LEFT JOIN user_status s ON s.user_id = u.id
+		ORDER BY u.username
+	`, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserPresence{}
+	for rows.Next() {
+		var user UserPresence
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&user.ID, &user.Username, &user.Online, &lastSeen); err != nil {
+			return nil, err
+		}
+		if lastSeen.Valid {
+			user.LastSeen = &lastSeen.Time
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,34 @@
+package database
+
+import "time"
+
+// refreshPostScore recomputes a post's cached ranking scores from its vote counts.
+// It runs in the same transaction as the vote so the cache never lags behind.
+func refreshPostScore(q dbtx, postID, likes, dislikes int) error {
+	var createdAt time.Time
+	if err := q.QueryRow("SELECT created_at FROM posts WHERE id = ?", postID).Scan(&createdAt); err != nil {
+		return err
+	}
+
+	_, err := q.Exec(`
+		INSERT INTO post_scores (post_id, likes, dislikes, score, hot, controversy, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (post_id) DO UPDATE SET
+			likes = excluded.likes,
+			dislikes = excluded.dislikes,
+			score = excluded.score,
+			hot = excluded.hot,
+			controversy = excluded.controversy,
+			updated_at = excluded.updated_at
+	`, postID, likes, dislikes, likes-dislikes, HotScore(likes, dislikes, createdAt),
+		ControversyScore(likes, dislikes), time.Now().UTC())
+	return err
+}
+
+// postRankOrders maps PostFilter.Rank to its ORDER BY clause over post_scores ps
+var postRankOrders = map[string]string{
+	RankHot:           "COALESCE(ps.hot, 0) DESC, p.id DESC",
+	RankTop:           "COALESCE(ps.score, 0) DESC, p.created_at DESC, p.id DESC",
+	RankControversial: "COALESCE(ps.controversy, 0) DESC, p.created_at DESC, p.id DESC",
+	RankNew:           "p.created_at DESC, p.id DESC",
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// revisionSources select the author and live version of a post or comment
+var revisionSources = map[string]string{
+	VoteTargetPost:    "SELECT user_id, title, content, created_at, deleted_at FROM posts WHERE id = ?",
+	VoteTargetComment: "SELECT user_id, '', content, created_at, deleted_at FROM comments WHERE id = ?",
+}
+
+// revise applies update to the post or comment next is a version of and records
+// next in its history, in one transaction. A RevisionDelete keeps the current
+// title and content. Returns ErrNotFound for missing or soft-deleted targets.
+func (s *SQLStore) revise(next Revision, update string, args ...interface{}) error {
+	source, ok := revisionSources[next.TargetType]
+	if !ok {
+		return ErrNotFound
+	}
+	if s.db.Dialect == Postgres {
+		// Serialize edits so the original version is recorded once
+		source += " FOR UPDATE"
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current := Revision{TargetType: next.TargetType, TargetID: next.TargetID}
+	var deletedAt sql.NullTime
+	err = tx.QueryRow(source, next.TargetID).Scan(&current.EditorID, &current.Title, &current.Content,
+		&current.CreatedAt, &deletedAt)
+	if err != nil {
+		return notFound(err)
+	}
+	if deletedAt.Valid {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(update, args...); err != nil {
+		return err
+	}
+
+	if next.Action == RevisionDelete {
+		next.Title, next.Content = current.Title, current.Content
+	}
+	if err := appendRevision(tx, current, next); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appendRevision records next as the newest version of a post or comment,
+// diffed against current, the version it replaces. A target changed for the
+// first time has no history yet, so current is recorded first as the original.
+func appendRevision(tx *Tx, current, next Revision) error {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM revisions WHERE target_type = ? AND target_id = ?",
+		current.TargetType, current.TargetID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		current.Action = RevisionCreate
+		if err := insertRevision(tx, current); err != nil {
+			return err
+		}
+	}
+
+	next.Diff = LineDiff(revisionText(current), revisionText(next))
+	return insertRevision(tx, next)
+}
+
+func insertRevision(tx *Tx, rev Revision) error {
+	_, err := tx.Exec(`
+		INSERT INTO revisions (target_type, target_id, editor_id, action, title, content, diff, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rev.TargetType, rev.TargetID, rev.EditorID, rev.Action, rev.Title, rev.Content, rev.Diff, rev.CreatedAt.UTC())
+	return err
+}
+
+// ListRevisions returns the history of a post or comment with editors, oldest first.
+// Empty for targets that were never edited or deleted.
+func (s *SQLStore) ListRevisions(targetType string, targetID int) ([]Revision, error) {
+	rows, err := s.db.Query(`
+		SELECT r.id, r.target_type, r.target_id, r.editor_id, u.username, r.action,
+			r.title, r.content, r.diff, r.created_at
+		FROM revisions r
+		JOIN users u ON u.id = r.editor_id
+		WHERE r.target_type = ? AND r.target_id = ?
+		ORDER BY r.id
+	`, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var rev Revision
+		rev.Editor = &User{}
+
+		err := rows.Scan(&rev.ID, &rev.TargetType, &rev.TargetID, &rev.EditorID, &rev.Editor.Username,
+			&rev.Action, &rev.Title, &rev.Content, &rev.Diff, &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rev.Editor.ID = rev.EditorID
+
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// UpdatePost changes a post's title and content and records the new revision
+func (s *SQLStore) UpdatePost(postID, editorID int, title, content string) error {
+	now := time.Now().UTC()
+	return s.revise(Revision{
+		TargetType: VoteTargetPost, TargetID: postID, EditorID: editorID,
+		Action: RevisionEdit, Title: title, Content: content, CreatedAt: now,
+	}, "UPDATE posts SET title = ?, content = ?, updated_at = ? WHERE id = ?", title, content, now, postID)
+}
+
+// DeletePost soft-deletes a post and records the deletion in its history
+func (s *SQLStore) DeletePost(postID, editorID int) error {
+	now := time.Now().UTC()
+	return s.revise(Revision{
+		TargetType: VoteTargetPost, TargetID: postID, EditorID: editorID,
+		Action: RevisionDelete, CreatedAt: now,
+	}, "UPDATE posts SET deleted_at = ? WHERE id = ?", now, postID)
+}
+
+// UpdateComment changes a comment's content and records the new revision
+func (s *SQLStore) UpdateComment(commentID, editorID int, content string) error {
+	now := time.Now().UTC()
+	return s.revise(Revision{
+		TargetType: VoteTargetComment, TargetID: commentID, EditorID: editorID,
+		Action: RevisionEdit, Content: content, CreatedAt: now,
+	}, "UPDATE comments SET content = ?, updated_at = ? WHERE id = ?", content, now, commentID)
+}
+
+// DeleteComment soft-deletes a comment and records the deletion in its history
+func (s *SQLStore) DeleteComment(commentID, editorID int) error {
+	now := time.Now().UTC()
+	return s.revise(Revision{
+		TargetType: VoteTargetComment, TargetID: commentID, EditorID: editorID,
+		Action: RevisionDelete, CreatedAt: now,
+	}, "UPDATE comments SET deleted_at = ? WHERE id = ?", now, commentID)
+}
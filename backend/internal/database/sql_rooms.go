@@ -0,0 +1,342 @@
+package database
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// CreateRoom inserts a room owned by room.CreatedBy with the given members
+// in a single transaction, and sets its ID and timestamps
+func (s *SQLStore) CreateRoom(room *Room, memberIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	roomID, err := tx.Insert(`
+		INSERT INTO conversations (name, created_by, created_at, updated_at) VALUES (?, ?, ?, ?)
+	`, room.Name, room.CreatedBy, now, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO conversation_members (conversation_id, user_id, role) VALUES (?, ?, ?)
+	`, roomID, room.CreatedBy, RoomRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	for _, memberID := range memberIDs {
+		_, err = tx.Exec(`
+			INSERT INTO conversation_members (conversation_id, user_id, role) VALUES (?, ?, ?)
+		`, roomID, memberID, RoomRoleMember)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	room.ID = roomID
+	room.CreatedAt = now
+	room.UpdatedAt = now
+	return nil
+}
+
+// GetRoom returns a room with its members
+func (s *SQLStore) GetRoom(roomID int) (*Room, error) {
+	room := &Room{Members: []RoomMember{}}
+	err := s.db.QueryRow(`
+		SELECT id, name, created_by, created_at, updated_at
+		FROM conversations WHERE id = ?
+	`, roomID).Scan(&room.ID, &room.Name, &room.CreatedBy, &room.CreatedAt, &room.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT m.user_id, u.username, m.role, m.joined_at
+		FROM conversation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id = ?
+		ORDER BY m.joined_at, m.user_id
+	`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member RoomMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		room.Members = append(room.Members, member)
+	}
+	return room, rows.Err()
+}
+
+// ListUserRooms returns every room a user belongs to with their members,
+// most recently active first. Members of all rooms are loaded in a single query.
+func (s *SQLStore) ListUserRooms(userID int) ([]Room, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, c.created_by, c.created_at, c.updated_at
+		FROM conversations c
+		JOIN conversation_members m ON m.conversation_id = c.id
+		WHERE m.user_id = ?
+		ORDER BY c.updated_at DESC, c.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []Room{}
+	index := make(map[int]int) // room ID -> position in rooms
+	for rows.Next() {
+		room := Room{Members: []RoomMember{}}
+		if err := rows.Scan(&room.ID, &room.Name, &room.CreatedBy, &room.CreatedAt, &room.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[room.ID] = len(rooms)
+		rooms = append(rooms, room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	memberRows, err := s.db.Query(`
+		SELECT m.conversation_id, m.user_id, u.username, m.role, m.joined_at
+		FROM conversation_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id IN (
+			SELECT conversation_id FROM conversation_members WHERE user_id = ?
+		)
+		ORDER BY m.joined_at, m.user_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer memberRows.Close()
+
+	for memberRows.Next() {
+		var roomID int
+		var member RoomMember
+		if err := memberRows.Scan(&roomID, &member.UserID, &member.Username, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[roomID]; ok {
+			rooms[i].Members = append(rooms[i].Members, member)
+		}
+	}
+	return rooms, memberRows.Err()
+}
+
+// GetMemberRole returns a user's role in a room, or ErrNotFound if they aren't a member
+func (s *SQLStore) GetMemberRole(roomID, userID int) (string, error) {
+	var role string
+	err := s.db.QueryRow(`
+		SELECT role FROM conversation_members WHERE conversation_id = ? AND user_id = ?
+	`, roomID, userID).Scan(&role)
+	if err != nil {
+		return "", notFound(err)
+	}
+	return role, nil
+}
+
+// ListMemberIDs returns the user IDs of every member of a room
+func (s *SQLStore) ListMemberIDs(roomID int) ([]int, error) {
+	rows, err := s.db.Query("SELECT user_id FROM conversation_members WHERE conversation_id = ?", roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AddRoomMember adds a user to a room with the given role in a single
+// transaction, or returns ErrRoomFull if the room already has maxMembers members
+func (s *SQLStore) AddRoomMember(roomID, userID int, role string, maxMembers int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Writing to the room first locks it, so concurrent adds count one at a time
+	result, err := tx.Exec("UPDATE conversations SET updated_at = updated_at WHERE id = ?", roomID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return ErrNotFound
+	}
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM conversation_members WHERE conversation_id = ?", roomID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count >= maxMembers {
+		return ErrRoomFull
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO conversation_members (conversation_id, user_id, role) VALUES (?, ?, ?)
+	`, roomID, userID, role)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveRoomMember removes a user from a room in a single transaction. When the
+// owner leaves, ownership passes to the member who joined earliest; when the
+// last member leaves, the room is deleted. Returns ErrNotFound if the user isn't
+// a member, and reports whether the room was deleted.
+func (s *SQLStore) RemoveRoomMember(roomID, userID int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.QueryRow(`
+		SELECT role FROM conversation_members WHERE conversation_id = ? AND user_id = ?
+	`, roomID, userID).Scan(&role)
+	if err != nil {
+		return false, notFound(err)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM conversation_members WHERE conversation_id = ? AND user_id = ?
+	`, roomID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	roomDeleted := false
+	if role == RoomRoleOwner {
+		var successorID int
+		err = tx.QueryRow(`
+			SELECT user_id FROM conversation_members
+			WHERE conversation_id = ?
+			ORDER BY joined_at, user_id LIMIT 1
+		`, roomID).Scan(&successorID)
+		switch {
+		case err == sql.ErrNoRows:
+			// Nobody left: drop the room and its history
+			if _, err := tx.Exec("DELETE FROM conversation_messages WHERE conversation_id = ?", roomID); err != nil {
+				return false, err
+			}
+			if _, err := tx.Exec("DELETE FROM conversations WHERE id = ?", roomID); err != nil {
+				return false, err
+			}
+			roomDeleted = true
+		case err != nil:
+			return false, err
+		default:
+			_, err = tx.Exec(`
+				UPDATE conversation_members SET role = ? WHERE conversation_id = ? AND user_id = ?
+			`, RoomRoleOwner, roomID, successorID)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return roomDeleted, tx.Commit()
+}
+
+// CreateRoomMessage inserts a message, sets its ID and bumps the room's updated_at
+func (s *SQLStore) CreateRoomMessage(msg *RoomMessage) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := tx.Insert(`
+		INSERT INTO conversation_messages (conversation_id, sender_id, content, created_at)
+		VALUES (?, ?, ?, ?)
+	`, msg.RoomID, msg.SenderID, msg.Content, msg.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE conversations SET updated_at = ? WHERE id = ?", msg.CreatedAt, msg.RoomID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	msg.ID = id
+	return nil
+}
+
+// ListRoomMessages returns one page of a room's messages with their senders'
+// usernames, oldest first
+func (s *SQLStore) ListRoomMessages(roomID int, page MessagePage) ([]RoomMessage, error) {
+	query := `
+		SELECT m.id, m.conversation_id, m.sender_id, u.username, m.content, m.created_at
+		FROM conversation_messages m
+		JOIN users u ON u.id = m.sender_id
+		WHERE m.conversation_id = ?
+	`
+	args := []interface{}{roomID}
+	switch {
+	case page.AfterID > 0:
+		query += " AND m.id > ? ORDER BY m.id ASC LIMIT ?"
+		args = append(args, page.AfterID)
+	case page.BeforeID > 0:
+		query += " AND m.id < ? ORDER BY m.id DESC LIMIT ?"
+		args = append(args, page.BeforeID)
+	default:
+		query += " ORDER BY m.id DESC LIMIT ?"
+	}
+	args = append(args, page.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []RoomMessage{}
+	for rows.Next() {
+		var msg RoomMessage
+		err := rows.Scan(&msg.ID, &msg.RoomID, &msg.SenderID, &msg.SenderUsername, &msg.Content, &msg.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Backward pages are scanned newest first
+	if page.AfterID == 0 {
+		slices.Reverse(messages)
+	}
+	return messages, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// roomStores returns a fresh SQLite store and memory store, which must behave the same
+func roomStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	return map[string]Store{
+		"sqlite": newTestSQLStore(t),
+		"memory": NewMemoryStore(),
+	}
+}
+
+// seedRoom creates users user0, user1, ... and a room owned by the first of them
+// with the second as a member
+func seedRoom(t *testing.T, s Store, users int) (*Room, []int) {
+	t.Helper()
+
+	var ids []int
+	for i := 0; i < users; i++ {
+		name := "user" + strconv.Itoa(i)
+		user := &User{Username: name, Email: name + "@example.com", PasswordHash: "x", Age: 30, FirstName: "A", LastName: "B"}
+		if err := s.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	room := &Room{Name: "Garden club", CreatedBy: ids[0]}
+	if err := s.CreateRoom(room, ids[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	return room, ids
+}
+
+func TestListRoomMessagesPages(t *testing.T) {
+	for name, s := range roomStores(t) {
+		t.Run(name, func(t *testing.T) {
+			room, users := seedRoom(t, s, 2)
+
+			var ids []int
+			for i := 0; i < 5; i++ {
+				msg := &RoomMessage{RoomID: room.ID, SenderID: users[i%2], Content: fmt.Sprintf("message %d", i), CreatedAt: time.Now().UTC()}
+				if err := s.CreateRoomMessage(msg); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, msg.ID)
+			}
+
+			// Every page lists its messages oldest first, whichever way it steps
+			tests := []struct {
+				name string
+				page MessagePage
+				want []int
+			}{
+				{"newest", MessagePage{Limit: 2}, ids[3:5]},
+				{"older", MessagePage{BeforeID: ids[3], Limit: 2}, ids[1:3]},
+				{"oldest", MessagePage{BeforeID: ids[1], Limit: 2}, ids[0:1]},
+				{"newer", MessagePage{AfterID: ids[0], Limit: 2}, ids[1:3]},
+				{"newest forward", MessagePage{AfterID: ids[2], Limit: 5}, ids[3:5]},
+			}
+			for _, tt := range tests {
+				messages, err := s.ListRoomMessages(room.ID, tt.page)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []int
+				for _, msg := range messages {
+					got = append(got, msg.ID)
+					if msg.SenderUsername == "" {
+						t.Errorf("%s: message %d has no sender username", tt.name, msg.ID)
+					}
+				}
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("%s: messages = %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddRoomMemberCap(t *testing.T) {
+	for name, s := range roomStores(t) {
+		t.Run(name, func(t *testing.T) {
+			room, users := seedRoom(t, s, 4)
+
+			// The owner and one member are already in
+			if err := s.AddRoomMember(room.ID, users[2], RoomRoleMember, 3); err != nil {
+				t.Fatalf("adding the third member: %v", err)
+			}
+			if err := s.AddRoomMember(room.ID, users[3], RoomRoleMember, 3); err != ErrRoomFull {
+				t.Errorf("adding a fourth member to a room of 3: err = %v, want ErrRoomFull", err)
+			}
+			if err := s.AddRoomMember(room.ID+100, users[3], RoomRoleMember, 3); err != ErrNotFound {
+				t.Errorf("adding to a missing room: err = %v, want ErrNotFound", err)
+			}
+
+			memberIDs, err := s.ListMemberIDs(room.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(memberIDs) != 3 {
+				t.Errorf("room has %d members, want 3", len(memberIDs))
+			}
+		})
+	}
+}
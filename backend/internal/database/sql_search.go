@@ -0,0 +1,284 @@
+package database
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// headlineOptions makes PostgreSQL's ts_headline use the same sentinels as SQLite's snippet
+const headlineOptions = "StartSel=" + matchStart + ", StopSel=" + matchEnd + ", MaxWords=32, MinWords=12"
+
+// Search runs a ranked full-text search over posts and comments.
+// Returns one page of results, best match first, and the total number of matches.
+// Soft-deleted posts and comments, and comments on deleted posts, never match.
+// Without a full-text index it matches substrings and ranks by how often the
+// terms occur, title hits counting double.
+func (s *SQLStore) Search(q SearchQuery) ([]SearchResult, int, error) {
+	results := []SearchResult{}
+	terms := searchTerms(q.Query)
+	if len(terms) == 0 {
+		return results, 0, nil
+	}
+
+	indexed := s.fullTextIndexed()
+
+	var parts []string
+	var args []interface{}
+	if q.Type != SearchTypeComment {
+		query, queryArgs := s.postSearchQuery(q, terms, indexed)
+		parts = append(parts, query)
+		args = append(args, queryArgs...)
+	}
+	if q.Type != SearchTypePost {
+		query, queryArgs := s.commentSearchQuery(q, terms, indexed)
+		parts = append(parts, query)
+		args = append(args, queryArgs...)
+	}
+	union := strings.Join(parts, " UNION ALL ")
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM ("+union+") matches", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || q.Offset >= total {
+		return results, total, nil
+	}
+
+	query := union + " ORDER BY relevance DESC, created_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result SearchResult
+		var postID int
+		err := rows.Scan(&result.Type, &result.ID, &postID, &result.Title, &result.Content,
+			&result.Author, &result.CreatedAt, &result.Relevance)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if !indexed {
+			// The excerpt column holds the whole text, with no matches marked yet
+			marked, _, _ := markTerms(result.Content, terms)
+			result.Content = excerpt(marked)
+		}
+		result.Content = highlight(result.Content)
+		result.URL = searchResultURL(postID)
+		if result.Type == SearchTypeComment {
+			result.PostID = &postID
+		}
+		results = append(results, result)
+	}
+	return results, total, rows.Err()
+}
+
+// fullTextIndexed reports whether searches can use a full-text index. PostgreSQL
+// always can; SQLite can when the FTS5 tables exist, which they don't if the
+// full-text search migration ran on a build without FTS5.
+func (s *SQLStore) fullTextIndexed() bool {
+	if s.db.Dialect == Postgres {
+		return true
+	}
+
+	s.ftsOnce.Do(func() {
+		var count int
+		err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = ? AND name = ?", "table", "posts_fts").Scan(&count)
+		if err != nil {
+			log.Printf("Error checking for the full-text search index: %v", err)
+		}
+		s.ftsIndexed = count > 0
+	})
+	return s.ftsIndexed
+}
+
+// likeConditions requires every term to appear in one of columns, as a
+// fallback for SQLite without FTS5. Terms hold only letters and digits, so
+// they never contain LIKE wildcards.
+func likeConditions(terms []string, columns ...string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range terms {
+		var matches []string
+		for _, column := range columns {
+			matches = append(matches, "LOWER("+column+") LIKE ?")
+			args = append(args, "%"+term+"%")
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	return conditions, args
+}
+
+// likeOccurrences counts how often the terms occur in column, for ranking
+// fallback matches. Terms are lowercase and LENGTH counts characters.
+func likeOccurrences(column string, terms []string) (string, []interface{}) {
+	var counts []string
+	var args []interface{}
+	for _, term := range terms {
+		counts = append(counts, "(LENGTH(LOWER("+column+")) - LENGTH(REPLACE(LOWER("+column+"), ?, ''))) / ?")
+		args = append(args, term, utf8.RuneCountInString(term))
+	}
+	return "(" + strings.Join(counts, " + ") + ")", args
+}
+
+// matchExpression turns search terms into the dialect's full-text query,
+// requiring every term to match as a word prefix
+func (s *SQLStore) matchExpression(terms []string) string {
+	if s.db.Dialect == Postgres {
+		// foo:* & bar:*
+		return strings.Join(terms, ":* & ") + ":*"
+	}
+	// FTS5 implicit AND of quoted prefix queries: "foo"* "bar"*
+	return `"` + strings.Join(terms, `"* "`) + `"*`
+}
+
+// postMatchCondition restricts posts p to those matching a matchExpression argument
+func (s *SQLStore) postMatchCondition() string {
+	if s.db.Dialect == Postgres {
+		return "to_tsvector('english', p.title || ' ' || p.content) @@ to_tsquery('english', ?)"
+	}
+	return "p.id IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH ?)"
+}
+
+// postSearchQuery selects matching posts in the column order Search scans
+func (s *SQLStore) postSearchQuery(q SearchQuery, terms []string, indexed bool) (string, []interface{}) {
+	var query string
+	var args []interface{}
+	switch {
+	case !indexed:
+		// Title hits weigh double, as with bm25
+		titleHits, titleArgs := likeOccurrences("p.title", terms)
+		contentHits, contentArgs := likeOccurrences("p.content", terms)
+		query = `
+			SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS title,
+				p.content AS excerpt, u.username AS author, p.created_at AS created_at,
+				1.0 * (2 * ` + titleHits + ` + ` + contentHits + `) AS relevance
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.deleted_at IS NULL
+		`
+		conditions, likeArgs := likeConditions(terms, "p.title", "p.content")
+		query += " AND " + strings.Join(conditions, " AND ")
+		args = append(append(titleArgs, contentArgs...), likeArgs...)
+	case s.db.Dialect == Postgres:
+		query = `
+			SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS title,
+				ts_headline('english', p.content, tsq, '` + headlineOptions + `') AS excerpt,
+				u.username AS author, p.created_at AS created_at,
+				ts_rank(to_tsvector('english', p.title || ' ' || p.content), tsq) AS relevance
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			CROSS JOIN to_tsquery('english', ?) tsq
+			WHERE to_tsvector('english', p.title || ' ' || p.content) @@ tsq AND p.deleted_at IS NULL
+		`
+		args = []interface{}{s.matchExpression(terms)}
+	default:
+		// bm25 is lower for better matches; title hits weigh double
+		query = `
+			SELECT 'post' AS type, p.id AS id, p.id AS post_id, p.title AS title,
+				snippet(posts_fts, 1, char(2), char(3), '…', 32) AS excerpt,
+				u.username AS author, p.created_at AS created_at,
+				-bm25(posts_fts, 2.0, 1.0) AS relevance
+			FROM posts_fts
+			JOIN posts p ON p.id = posts_fts.rowid
+			JOIN users u ON u.id = p.user_id
+			WHERE posts_fts MATCH ? AND p.deleted_at IS NULL
+		`
+		args = []interface{}{s.matchExpression(terms)}
+	}
+
+	conditions, filterArgs := searchFilters(q, "p.created_at", "p.id")
+	for _, condition := range conditions {
+		query += " AND " + condition
+	}
+	return query, append(args, filterArgs...)
+}
+
+// commentSearchQuery selects matching comments in the column order Search scans
+func (s *SQLStore) commentSearchQuery(q SearchQuery, terms []string, indexed bool) (string, []interface{}) {
+	var query string
+	var args []interface{}
+	switch {
+	case !indexed:
+		hits, hitArgs := likeOccurrences("c.content", terms)
+		query = `
+			SELECT 'comment' AS type, c.id AS id, c.post_id AS post_id, p.title AS title,
+				c.content AS excerpt, u.username AS author, c.created_at AS created_at,
+				1.0 * ` + hits + ` AS relevance
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = c.user_id
+			WHERE c.deleted_at IS NULL AND p.deleted_at IS NULL
+		`
+		conditions, likeArgs := likeConditions(terms, "c.content")
+		query += " AND " + strings.Join(conditions, " AND ")
+		args = append(hitArgs, likeArgs...)
+	case s.db.Dialect == Postgres:
+		query = `
+			SELECT 'comment' AS type, c.id AS id, c.post_id AS post_id, p.title AS title,
+				ts_headline('english', c.content, tsq, '` + headlineOptions + `') AS excerpt,
+				u.username AS author, c.created_at AS created_at,
+				ts_rank(to_tsvector('english', c.content), tsq) AS relevance
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = c.user_id
+			CROSS JOIN to_tsquery('english', ?) tsq
+			WHERE to_tsvector('english', c.content) @@ tsq
+				AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+		`
+		args = []interface{}{s.matchExpression(terms)}
+	default:
+		query = `
+			SELECT 'comment' AS type, c.id AS id, c.post_id AS post_id, p.title AS title,
+				snippet(comments_fts, 0, char(2), char(3), '…', 32) AS excerpt,
+				u.username AS author, c.created_at AS created_at,
+				-bm25(comments_fts) AS relevance
+			FROM comments_fts
+			JOIN comments c ON c.id = comments_fts.rowid
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = c.user_id
+			WHERE comments_fts MATCH ? AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+		`
+		args = []interface{}{s.matchExpression(terms)}
+	}
+
+	conditions, filterArgs := searchFilters(q, "c.created_at", "c.post_id")
+	for _, condition := range conditions {
+		query += " AND " + condition
+	}
+	return query, append(args, filterArgs...)
+}
+
+// searchFilters builds the category, author and date conditions shared by both
+// result types. The author's users row is always joined as u.
+func searchFilters(q SearchQuery, createdColumn, postColumn string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if q.CategoryID != nil {
+		conditions = append(conditions, postColumn+" IN (SELECT post_id FROM post_categories WHERE category_id = ?)")
+		args = append(args, *q.CategoryID)
+	}
+	if q.Author != "" {
+		conditions = append(conditions, "LOWER(u.username) = LOWER(?)")
+		args = append(args, q.Author)
+	}
+	if q.Since != nil {
+		conditions = append(conditions, createdColumn+" >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if q.Until != nil {
+		conditions = append(conditions, createdColumn+" < ?")
+		args = append(args, q.Until.UTC())
+	}
+
+	return conditions, args
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestSQLStore migrates a fresh SQLite database in a temporary directory
+func newTestSQLStore(t testing.TB) *SQLStore {
+	t.Helper()
+
+	sqlDB, err := sql.Open(string(SQLite), filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db := &Conn{DB: sqlDB, Dialect: SQLite}
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLStore(db)
+}
+
+// TestSearch runs with FTS5 under -tags sqlite_fts5 and with the LIKE fallback without it
+func TestSearch(t *testing.T) {
+	s := newTestSQLStore(t)
+
+	user := &User{Username: "alice", Email: "alice@example.com", PasswordHash: "x", Age: 30, FirstName: "A", LastName: "B"}
+	if err := s.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	gardening := &Post{UserID: user.ID, Title: "Gardening tips", Content: "Water tomatoes early in the morning"}
+	cooking := &Post{UserID: user.ID, Title: "Cooking", Content: "Roast tomatoes with garlic"}
+	for _, post := range []*Post{gardening, cooking} {
+		if err := s.CreatePost(post, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	comment := &Comment{PostID: gardening.ID, UserID: user.ID, Content: "Mulch keeps the garden moist"}
+	if err := s.CreateComment(comment); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string // type:id of every match, in any order
+	}{
+		{"tomatoes", []string{"post:" + strconv.Itoa(gardening.ID), "post:" + strconv.Itoa(cooking.ID)}},
+		{"tomato garlic", []string{"post:" + strconv.Itoa(cooking.ID)}},
+		{"GARDEN", []string{"post:" + strconv.Itoa(gardening.ID), "comment:" + strconv.Itoa(comment.ID)}},
+		{"mulch", []string{"comment:" + strconv.Itoa(comment.ID)}},
+		{"cabbage", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, total, err := s.Search(SearchQuery{Query: tt.query, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]bool)
+			for _, result := range results {
+				got[result.Type+":"+strconv.Itoa(result.ID)] = true
+				// Post titles aren't part of the excerpt, but comment matches always are
+				if result.Type == SearchTypeComment && !strings.Contains(result.Content, "<mark>") {
+					t.Errorf("excerpt %q has no highlighted match", result.Content)
+				}
+			}
+			if total != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("got %d matches (%v), want %v", total, got, tt.want)
+			}
+			for _, want := range tt.want {
+				if !got[want] {
+					t.Errorf("missing %s in %v", want, got)
+				}
+			}
+		})
+	}
+
+	posts, err := s.ListPosts(PostFilter{Search: "garlic", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].ID != cooking.ID {
+		t.Errorf("listing posts matching garlic = %+v, want only post %d", posts, cooking.ID)
+	}
+}
+
+// TestSearchRanking checks better matches come first and long texts are cut
+// down to an excerpt around the match, with or without FTS5
+func TestSearchRanking(t *testing.T) {
+	s := newTestSQLStore(t)
+
+	user := &User{Username: "alice", Email: "alice@example.com", PasswordHash: "x", Age: 30, FirstName: "A", LastName: "B"}
+	if err := s.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	filler := strings.Repeat("soil needs compost and patience every single season ", 10)
+	buried := &Post{UserID: user.ID, Title: "Soup", Content: filler + "then add tomatoes " + filler}
+	titled := &Post{UserID: user.ID, Title: "Tomatoes", Content: "Grown in the greenhouse this year"}
+	for _, post := range []*Post{titled, buried} {
+		if err := s.CreatePost(post, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, total, err := s.Search(SearchQuery{Query: "tomatoes", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(results) != 2 {
+		t.Fatalf("got %d matches, want 2", total)
+	}
+	if results[0].ID != titled.ID {
+		t.Errorf("first result = post %d, want post %d matching in its title", results[0].ID, titled.ID)
+	}
+	if results[0].Relevance <= results[1].Relevance {
+		t.Errorf("relevance %v then %v, want a higher score for the title match", results[0].Relevance, results[1].Relevance)
+	}
+
+	excerpt := results[1].Content
+	if !strings.Contains(excerpt, "<mark>tomatoes</mark>") {
+		t.Errorf("excerpt %q does not highlight the match", excerpt)
+	}
+	if words := len(strings.Fields(excerpt)); words > excerptWords+2 {
+		t.Errorf("excerpt has %d words, want about %d", words, excerptWords)
+	}
+	if !strings.HasPrefix(excerpt, "…") || !strings.HasSuffix(excerpt, "…") {
+		t.Errorf("excerpt %q is not marked as cut on both ends", excerpt)
+	}
+}
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLStore implements Store on top of the forum's SQLite or PostgreSQL database
+type SQLStore struct {
+	db *Conn
+
+	ftsOnce    sync.Once
+	ftsIndexed bool // SQLite only: whether the FTS5 search tables exist
+}
+
+// NewSQLStore creates a store backed by db
+func NewSQLStore(db *Conn) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// placeholders returns "?, ?, ..." with n placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// USERS
+
+// CreateUser inserts a user and sets its ID
+func (s *SQLStore) CreateUser(user *User) error {
+	id, err := s.db.Insert(`
+		INSERT INTO users (username, email, password_hash, age, gender, first_name, last_name)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, user.Username, user.Email, user.PasswordHash, user.Age, user.Gender, user.FirstName, user.LastName)
+	if err != nil {
+		return err
+	}
+	user.ID = id
+	return nil
+}
+
+// GetUserByID returns a user's public profile
+func (s *SQLStore) GetUserByID(id int) (*User, error) {
+	var user User
+	var verifiedAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT id, username, email, role, created_at, updated_at, email_verified_at
+		FROM users WHERE id = ?
+	`, id).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt, &verifiedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return &user, nil
+}
+
+// GetUserByLogin finds a user by username or email, including the password hash
+func (s *SQLStore) GetUserByLogin(login string) (*User, error) {
+	var user User
+	var verifiedAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT id, username, email, password_hash, age, gender, first_name, last_name, role, created_at, email_verified_at
+		FROM users
+		WHERE username = ? OR email = ?
+	`, login, login).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Age,
+		&user.Gender, &user.FirstName, &user.LastName, &user.Role, &user.CreatedAt, &verifiedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return &user, nil
+}
+
+// UserExists reports whether the username or email is already taken
+func (s *SQLStore) UserExists(username, email string) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ? OR email = ?", username, email).Scan(&count)
+	return count > 0, err
+}
+
+// GetUsersByIDs returns the users with the given IDs, in no particular order
+func (s *SQLStore) GetUsersByIDs(ids []int) ([]User, error) {
+	users := []User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, username, email, created_at
+		FROM users
+		WHERE id IN (`+placeholders(len(ids))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// SESSIONS
+
+// CreateSession inserts a session and sets its ID
+func (s *SQLStore) CreateSession(session *Session) error {
+	if session.LastUsedAt.IsZero() {
+		session.LastUsedAt = time.Now().UTC()
+	}
+	if session.AbsoluteExpiresAt.IsZero() {
+		session.AbsoluteExpiresAt = session.ExpiresAt
+	}
+	id, err := s.db.Insert(`
+		INSERT INTO sessions (user_id, token, expires_at, user_agent, ip_address, last_used_at, remember_me, absolute_expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.UserID, session.Token, session.ExpiresAt, session.UserAgent, session.IPAddress, session.LastUsedAt,
+		session.RememberMe, session.AbsoluteExpiresAt)
+	if err != nil {
+		return err
+	}
+	session.ID = id
+	return nil
+}
+
+// sessionColumns are the session fields every lookup returns, in scanSession order
+const sessionColumns = "id, user_id, token, expires_at, created_at, user_agent, ip_address, last_used_at, remember_me, absolute_expires_at"
+
+// scanSession reads a row selected with sessionColumns
+func scanSession(row rowScanner) (Session, error) {
+	var session Session
+	err := row.Scan(&session.ID, &session.UserID, &session.Token, &session.ExpiresAt, &session.CreatedAt,
+		&session.UserAgent, &session.IPAddress, &session.LastUsedAt, &session.RememberMe, &session.AbsoluteExpiresAt)
+	return session, err
+}
+
+// GetSession looks up a session by its token
+func (s *SQLStore) GetSession(token string) (*Session, error) {
+	session, err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE token = ?", token))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &session, nil
+}
+
+// ListUserSessions returns a user's unexpired sessions, most recently used first
+func (s *SQLStore) ListUserSessions(userID int, now time.Time) ([]Session, error) {
+	rows, err := s.db.Query(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_used_at DESC, id DESC
+	`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that a session was just used
+func (s *SQLStore) TouchSession(token string, at time.Time) error {
+	_, err := s.db.Exec("UPDATE sessions SET last_used_at = ? WHERE token = ?", at, token)
+	return err
+}
+
+// DeleteSession removes a single session
+func (s *SQLStore) DeleteSession(token string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE token = ?", token)
+	return err
+}
+
+// DeleteUserSessions removes every session of a user
+func (s *SQLStore) DeleteUserSessions(userID int) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// DeleteUserSession removes one of a user's sessions.
+// Returns ErrNotFound if the session does not exist or belongs to someone else.
+func (s *SQLStore) DeleteUserSession(userID, sessionID int) error {
+	result, err := s.db.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", sessionID, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteOtherSessions removes every session of a user except keepID and
+// returns the IDs of the sessions it removed
+func (s *SQLStore) DeleteOtherSessions(userID, keepID int) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM sessions WHERE user_id = ? AND id <> ?", userID, keepID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND id <> ?", userID, keepID); err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+// DeleteExpiredSessions removes sessions that expired at or before now
+func (s *SQLStore) DeleteExpiredSessions(now time.Time) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now)
+	return err
+}
+
+// ExtendSession moves a session's expiry
+func (s *SQLStore) ExtendSession(token string, expiresAt time.Time) error {
+	_, err := s.db.Exec("UPDATE sessions SET expires_at = ? WHERE token = ?", expiresAt, token)
+	return err
+}
+
+// CountSessions returns the total, unexpired and distinct-user session counts
+func (s *SQLStore) CountSessions(now time.Time) (total, active, users int, err error) {
+	err = s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COUNT(CASE WHEN expires_at > ? THEN 1 END),
+			COUNT(DISTINCT CASE WHEN expires_at > ? THEN user_id END)
+		FROM sessions
+	`, now, now).Scan(&total, &active, &users)
+	return total, active, users, err
+}
@@ -0,0 +1,70 @@
+package database
+
+import "time"
+
+// CreateEmailVerification stores a verification token and sets its ID,
+// voiding the user's earlier tokens
+func (s *SQLStore) CreateEmailVerification(verification *EmailVerification) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_verifications WHERE user_id = ?", verification.UserID); err != nil {
+		return err
+	}
+
+	id, err := tx.Insert("INSERT INTO email_verifications (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+		verification.UserID, verification.TokenHash, verification.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	verification.ID = id
+	return nil
+}
+
+// VerifyEmail redeems an unexpired token, marks its user's email as
+// verified and returns the user's ID.
+// Returns ErrNotFound if the token is unknown, used or expired.
+func (s *SQLStore) VerifyEmail(tokenHash string, now time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var expiresAt time.Time
+	err = tx.QueryRow("SELECT user_id, expires_at FROM email_verifications WHERE token_hash = ?", tokenHash).
+		Scan(&userID, &expiresAt)
+	if err != nil {
+		return 0, notFound(err)
+	}
+
+	// Deleting the token is what makes it single-use; a concurrent request
+	// that loses the race deletes nothing
+	result, err := tx.Exec("DELETE FROM email_verifications WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 || !expiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET email_verified_at = ?, updated_at = ?
+		WHERE id = ? AND email_verified_at IS NULL
+	`, now, now, userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
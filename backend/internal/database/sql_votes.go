@@ -0,0 +1,189 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Vote values stored in votes.vote_type
+const (
+	VoteLike    = 1
+	VoteDislike = -1
+)
+
+// Vote target types
+const (
+	VoteTargetPost    = "post"
+	VoteTargetComment = "comment"
+)
+
+var (
+	// ErrInvalidVote is returned for an unknown target type or vote value
+	ErrInvalidVote = errors.New("invalid vote")
+
+	// ErrVoteTargetNotFound is returned when the voted post or comment does not exist
+	ErrVoteTargetNotFound = errors.New("vote target not found")
+)
+
+// voteTarget returns the votes column and owning table for a target type
+func voteTarget(targetType string) (column, table string, err error) {
+	switch targetType {
+	case VoteTargetPost:
+		return "post_id", "posts", nil
+	case VoteTargetComment:
+		return "comment_id", "comments", nil
+	}
+	return "", "", ErrInvalidVote
+}
+
+// ToggleVote applies a vote from userID on a post or comment and returns the new stats.
+// Voting the same way twice removes the vote, voting the other way flips it.
+// The read-modify-write runs in a single transaction.
+func (s *SQLStore) ToggleVote(userID int, targetType string, targetID int, voteType int) (*VoteStats, error) {
+	if voteType != VoteLike && voteType != VoteDislike {
+		return nil, ErrInvalidVote
+	}
+	column, table, err := voteTarget(targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM "+table+" WHERE id = ? AND deleted_at IS NULL", targetID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrVoteTargetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error checking vote target: %w", err)
+	}
+
+	var existing int
+	err = tx.QueryRow("SELECT vote_type FROM votes WHERE user_id = ? AND "+column+" = ?", userID, targetID).Scan(&existing)
+
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec("INSERT INTO votes (user_id, "+column+", vote_type) VALUES (?, ?, ?)", userID, targetID, voteType)
+	case err != nil:
+		return nil, fmt.Errorf("error checking existing vote: %w", err)
+	case existing == voteType:
+		_, err = tx.Exec("DELETE FROM votes WHERE user_id = ? AND "+column+" = ?", userID, targetID)
+	default:
+		_, err = tx.Exec("UPDATE votes SET vote_type = ? WHERE user_id = ? AND "+column+" = ?", voteType, userID, targetID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error saving vote: %w", err)
+	}
+
+	stats, err := voteStats(tx, column, targetID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetType == VoteTargetPost {
+		if err := refreshPostScore(tx, targetID, stats.LikeCount, stats.DislikeCount); err != nil {
+			return nil, fmt.Errorf("error updating post score: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetVoteStats returns the vote counts of a post or comment.
+// UserVote is filled in for userID; pass 0 for anonymous viewers.
+func (s *SQLStore) GetVoteStats(targetType string, targetID int, userID int) (*VoteStats, error) {
+	column, _, err := voteTarget(targetType)
+	if err != nil {
+		return nil, err
+	}
+	return voteStats(s.db, column, targetID, userID)
+}
+
+func voteStats(q dbtx, column string, targetID int, userID int) (*VoteStats, error) {
+	var stats VoteStats
+	var userVote sql.NullInt64
+
+	err := q.QueryRow(`
+		SELECT
+			COUNT(CASE WHEN vote_type = 1 THEN 1 END),
+			COUNT(CASE WHEN vote_type = -1 THEN 1 END),
+			MAX(CASE WHEN user_id = ? THEN vote_type END)
+		FROM votes
+		WHERE `+column+` = ?
+	`, userID, targetID).Scan(&stats.LikeCount, &stats.DislikeCount, &userVote)
+	if err != nil {
+		return nil, fmt.Errorf("error counting votes: %w", err)
+	}
+
+	stats.finish(userID, userVote)
+	return &stats, nil
+}
+
+// GetVoteStatsBatch returns the vote counts of many posts or comments in one query,
+// keyed by target ID. Targets without votes get zero stats.
+func (s *SQLStore) GetVoteStatsBatch(targetType string, targetIDs []int, userID int) (map[int]*VoteStats, error) {
+	column, _, err := voteTarget(targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[int]*VoteStats, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return stats, nil
+	}
+
+	args := []interface{}{userID}
+	for _, id := range targetIDs {
+		stats[id] = &VoteStats{}
+		args = append(args, id)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			`+column+`,
+			COUNT(CASE WHEN vote_type = 1 THEN 1 END),
+			COUNT(CASE WHEN vote_type = -1 THEN 1 END),
+			MAX(CASE WHEN user_id = ? THEN vote_type END)
+		FROM votes
+		WHERE `+column+` IN (`+placeholders(len(targetIDs))+`)
+		GROUP BY `+column, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting votes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID int
+		var target VoteStats
+		var userVote sql.NullInt64
+		if err := rows.Scan(&targetID, &target.LikeCount, &target.DislikeCount, &userVote); err != nil {
+			return nil, err
+		}
+		target.finish(userID, userVote)
+		stats[targetID] = &target
+	}
+	return stats, rows.Err()
+}
+
+// finish sets UserVote from the viewer's raw vote_type and derives the totals
+func (stats *VoteStats) finish(userID int, userVote sql.NullInt64) {
+	if userID != 0 && userVote.Valid {
+		isLike := userVote.Int64 == VoteLike
+		stats.UserVote = &isLike
+	}
+	stats.NetScore = stats.LikeCount - stats.DislikeCount
+	stats.TotalVotes = stats.LikeCount + stats.DislikeCount
+}
+
+// PostsVotedBySubquery selects the IDs of posts a user voted on a given way.
+// Its placeholders are the user ID and the vote value.
+const PostsVotedBySubquery = "SELECT post_id FROM votes WHERE user_id = ? AND vote_type = ? AND post_id IS NOT NULL"
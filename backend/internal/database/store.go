@@ -0,0 +1,214 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by stores when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidParent is returned when a reply's parent comment is missing or on another post
+var ErrInvalidParent = errors.New("parent comment not found on this post")
+
+// ErrRoomFull is returned when adding a member to a room that has no space left
+var ErrRoomFull = errors.New("room is full")
+
+// UserStore manages user accounts
+type UserStore interface {
+	// CreateUser inserts a user and sets its ID
+	CreateUser(user *User) error
+	GetUserByID(id int) (*User, error)
+	// GetUserByLogin finds a user by username or email, including the password hash
+	GetUserByLogin(login string) (*User, error)
+	UserExists(username, email string) (bool, error)
+	GetUsersByIDs(ids []int) ([]User, error)
+}
+
+// SessionStore manages login sessions
+type SessionStore interface {
+	CreateSession(session *Session) error
+	GetSession(token string) (*Session, error)
+	// ListUserSessions returns a user's unexpired sessions, most recently used first
+	ListUserSessions(userID int, now time.Time) ([]Session, error)
+	TouchSession(token string, at time.Time) error
+	DeleteSession(token string) error
+	// DeleteUserSession returns ErrNotFound unless the session belongs to the user
+	DeleteUserSession(userID, sessionID int) error
+	// DeleteOtherSessions returns the IDs of the sessions it removed
+	DeleteOtherSessions(userID, keepID int) ([]int, error)
+	DeleteUserSessions(userID int) error
+	DeleteExpiredSessions(now time.Time) error
+	// ExtendSession moves a session's idle deadline; callers keep it within the absolute one
+	ExtendSession(token string, expiresAt time.Time) error
+	// CountSessions returns the total, unexpired and distinct-user session counts
+	CountSessions(now time.Time) (total, active, users int, err error)
+}
+
+// PostStore manages posts and their categories
+type PostStore interface {
+	// CreatePost inserts a post with its categories and sets its ID
+	CreatePost(post *Post, categoryIDs []int) error
+	// GetPost returns a post with its author, categories and comment count.
+	// Soft-deleted posts are returned redacted.
+	GetPost(id int) (*Post, error)
+	// ListPosts returns posts matching the filter with authors, categories, comment counts
+	// and vote counts (with filter.ViewerID's own vote), ordered by filter.SortBy and
+	// filter.SortOrder (newest first by default).
+	// Soft-deleted posts are left out.
+	ListPosts(filter PostFilter) ([]Post, error)
+	// CountPosts returns how many posts match the filter, ignoring Limit and Offset
+	CountPosts(filter PostFilter) (int, error)
+	// PostExists reports whether a post exists and is not deleted
+	PostExists(id int) (bool, error)
+	// UpdatePost changes a post's title and content and records the revision.
+	// Returns ErrNotFound for missing or deleted posts.
+	UpdatePost(postID, editorID int, title, content string) error
+	// DeletePost soft-deletes a post and records the revision
+	DeletePost(postID, editorID int) error
+	ListCategories() ([]Category, error)
+}
+
+// CommentStore manages comments on posts
+type CommentStore interface {
+	// CreateComment inserts a comment and sets its ID and timestamps.
+	// Returns ErrInvalidParent if ParentID is set to a comment on another post.
+	CreateComment(comment *Comment) error
+	// ListReplies returns up to limit replies to each of parentIDs with an ID
+	// greater than afterID, with their authors and reply counts, grouped by parent
+	// and oldest first. A nil parentIDs lists the post's top-level comments.
+	ListReplies(postID int, parentIDs []int, afterID, limit int) ([]Comment, error)
+	// GetComment returns a comment with its author, redacted if soft-deleted
+	GetComment(id int) (*Comment, error)
+	// UpdateComment changes a comment's content and records the revision.
+	// Returns ErrNotFound for missing or deleted comments.
+	UpdateComment(commentID, editorID int, content string) error
+	// DeleteComment soft-deletes a comment and records the revision
+	DeleteComment(commentID, editorID int) error
+	// GetCommentPostID returns the post a comment belongs to
+	GetCommentPostID(commentID int) (int, error)
+}
+
+// VoteStore manages likes and dislikes on posts and comments
+type VoteStore interface {
+	// ToggleVote applies a vote atomically: voting the same way twice removes
+	// the vote, voting the other way flips it. Returns the new stats, or
+	// ErrVoteTargetNotFound for missing and soft-deleted targets.
+	ToggleVote(userID int, targetType string, targetID int, voteType int) (*VoteStats, error)
+	// GetVoteStats returns counts for a target; UserVote is set for userID (0 = anonymous)
+	GetVoteStats(targetType string, targetID int, userID int) (*VoteStats, error)
+	// GetVoteStatsBatch returns stats for many targets of one type, keyed by target ID
+	GetVoteStatsBatch(targetType string, targetIDs []int, userID int) (map[int]*VoteStats, error)
+}
+
+// MessageStore manages private messages between two users
+type MessageStore interface {
+	// CreateMessage inserts a message and sets its ID
+	CreateMessage(msg *Message) error
+	MarkDelivered(messageID int, at time.Time) error
+	// MarkConversationDelivered marks every undelivered message from senderID to receiverID as delivered
+	MarkConversationDelivered(senderID, receiverID int, at time.Time) error
+	// MarkRead marks unread messages from senderID to readerID with id <= upToID
+	// as read and returns how many changed state
+	MarkRead(readerID, senderID, upToID int, at time.Time) (int, error)
+	// ListMessages returns one page of the conversation between two users
+	ListMessages(userID, otherID int, page MessagePage) ([]Message, error)
+	// ListConversations returns one entry per counterpart, most recent first
+	ListConversations(userID int) ([]Conversation, error)
+}
+
+// RoomStore manages multi-member chat rooms and their messages
+type RoomStore interface {
+	// CreateRoom inserts a room owned by room.CreatedBy with the given members
+	// in a single transaction, and sets its ID and timestamps
+	CreateRoom(room *Room, memberIDs []int) error
+	// GetRoom returns a room with its members
+	GetRoom(roomID int) (*Room, error)
+	// ListUserRooms returns every room a user belongs to with their members,
+	// most recently active first
+	ListUserRooms(userID int) ([]Room, error)
+	// GetMemberRole returns a user's role in a room, or ErrNotFound if they aren't a member
+	GetMemberRole(roomID, userID int) (string, error)
+	// ListMemberIDs returns the user IDs of every member of a room
+	ListMemberIDs(roomID int) ([]int, error)
+	// AddRoomMember adds a user to a room with the given role, or returns
+	// ErrRoomFull if the room already has maxMembers members
+	AddRoomMember(roomID, userID int, role string, maxMembers int) error
+	// RemoveRoomMember removes a user from a room. When the owner leaves, ownership
+	// passes to the member who joined earliest; when the last member leaves, the
+	// room is deleted. Returns ErrNotFound if the user isn't a member, and reports
+	// whether the room was deleted.
+	RemoveRoomMember(roomID, userID int) (bool, error)
+	// CreateRoomMessage inserts a message, sets its ID and bumps the room's updated_at
+	CreateRoomMessage(msg *RoomMessage) error
+	// ListRoomMessages returns one page of a room's messages with their
+	// senders' usernames, oldest first
+	ListRoomMessages(roomID int, page MessagePage) ([]RoomMessage, error)
+}
+
+// PresenceStore persists users' online state
+type PresenceStore interface {
+	// SavePresence records a user's online state and last-seen time
+	SavePresence(userID int, online bool, lastSeen time.Time) error
+	// ResetPresence marks every user offline
+	ResetPresence() error
+	// ListPresence returns every user with their last recorded presence, ordered by username
+	ListPresence() ([]UserPresence, error)
+}
+
+// SearchStore runs full-text searches over posts and comments
+type SearchStore interface {
+	// Search returns one page of ranked matches and the total number of matches
+	Search(query SearchQuery) ([]SearchResult, int, error)
+}
+
+// RevisionStore reads the edit history of posts and comments
+type RevisionStore interface {
+	// ListRevisions returns every version of a post or comment, oldest first.
+	// Empty for targets that were never edited or deleted.
+	ListRevisions(targetType string, targetID int) ([]Revision, error)
+}
+
+// PasswordResetStore manages password reset tokens
+type PasswordResetStore interface {
+	// CreatePasswordReset stores a reset token and sets its ID, voiding the
+	// user's earlier unused tokens
+	CreatePasswordReset(reset *PasswordReset) error
+	// ResetPassword redeems an unused, unexpired token and sets its user's
+	// password hash in one step, returning the user's ID.
+	// Returns ErrNotFound if the token is unknown, used or expired.
+	ResetPassword(tokenHash, passwordHash string, now time.Time) (int, error)
+}
+
+// EmailVerificationStore manages email verification tokens
+type EmailVerificationStore interface {
+	// CreateEmailVerification stores a verification token and sets its ID,
+	// voiding the user's earlier tokens
+	CreateEmailVerification(verification *EmailVerification) error
+	// VerifyEmail redeems an unexpired token, marks its user's email as
+	// verified and returns the user's ID.
+	// Returns ErrNotFound if the token is unknown, used or expired.
+	VerifyEmail(tokenHash string, now time.Time) (int, error)
+}
+
+// Store combines every storage interface used by the handlers
+type Store interface {
+	UserStore
+	SessionStore
+	PostStore
+	CommentStore
+	VoteStore
+	MessageStore
+	RoomStore
+	PresenceStore
+	SearchStore
+	RevisionStore
+	PasswordResetStore
+	EmailVerificationStore
+}
+
+// Compile-time checks that both implementations satisfy Store
+var (
+	_ Store = (*SQLStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -2,28 +2,37 @@ package handlers
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	netmail "net/mail"
 	"strings"
 	"time"
 
 	"real-time-forum/internal/database"
+	"real-time-forum/internal/middleware"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthHandler handles all authentication-related HTTP requests
 type AuthHandler struct {
-	db *sql.DB
+	users          database.UserStore
+	sessions       database.SessionStore
+	authMiddleware *middleware.AuthMiddleware
+	verification   *VerificationHandler // Emails new users a link to verify their address
 }
 
-// NewAuthHandler creates a new authentication handler with database connection
-func NewAuthHandler(db *sql.DB) *AuthHandler {
+// NewAuthHandler creates a new authentication handler backed by the given stores
+func NewAuthHandler(users database.UserStore, sessions database.SessionStore, authMiddleware *middleware.AuthMiddleware, verification *VerificationHandler) *AuthHandler {
 	return &AuthHandler{
-		db: db,
+		users:          users,
+		sessions:       sessions,
+		authMiddleware: authMiddleware,
+		verification:   verification,
 	}
 }
 
@@ -40,8 +49,9 @@ type RegisterRequest struct {
 
 // LoginRequest represents the JSON payload for login
 type LoginRequest struct {
-	Login    string `json:"login"` // Username or Email
-	Password string `json:"password"`
+	Login      string `json:"login"` // Username or Email
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"` // Keep the session across browser restarts, with longer timeouts
 }
 
 // RegisterHandler handles user registration via JSON API
@@ -83,8 +93,14 @@ func (h *AuthHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account exists either way; if the email fails the user can ask for it again
+	user := &database.User{ID: userID, Username: req.Username, Email: req.Email}
+	if err := h.verification.SendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", userID, err)
+	}
+
 	h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
-		"message": "User registered successfully",
+		"message": "User registered successfully. Check your email to verify your address.",
 		"user_id": userID,
 	})
 }
@@ -115,7 +131,7 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create session
-	err = h.createSession(w, user)
+	err = h.createSession(w, r, user, req.RememberMe)
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Error creating session")
 		return
@@ -139,11 +155,11 @@ func (h *AuthHandler) validateRegistrationInput(req *RegisterRequest) error {
 	if len(req.Username) < 3 || len(req.Username) > 50 {
 		return fmt.Errorf("username must be between 3 and 50 characters")
 	}
-	if !strings.Contains(req.Email, "@") || !strings.Contains(req.Email, ".") {
+	if !validEmail(req.Email) {
 		return fmt.Errorf("invalid email address")
 	}
-	if len(req.Password) < 6 {
-		return fmt.Errorf("password must be at least 6 characters")
+	if len(req.Password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
 	}
 	if req.Age <= 0 {
 		return fmt.Errorf("invalid age")
@@ -155,34 +171,31 @@ func (h *AuthHandler) validateRegistrationInput(req *RegisterRequest) error {
 }
 
 func (h *AuthHandler) userExists(username, email string) bool {
-	var count int
-	err := h.db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ? OR email = ?", username, email).Scan(&count)
+	exists, err := h.users.UserExists(username, email)
 	if err != nil {
 		return true // Fail safe
 	}
-	return count > 0
+	return exists
 }
 
-func (h *AuthHandler) createUser(req *RegisterRequest, hashedPassword string) (int64, error) {
-	result, err := h.db.Exec(`
-		INSERT INTO users (username, email, password_hash, age, gender, first_name, last_name) 
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, req.Username, req.Email, hashedPassword, req.Age, req.Gender, req.FirstName, req.LastName)
-
-	if err != nil {
+func (h *AuthHandler) createUser(req *RegisterRequest, hashedPassword string) (int, error) {
+	user := &database.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		Age:          req.Age,
+		Gender:       req.Gender,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+	}
+	if err := h.users.CreateUser(user); err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	return user.ID, nil
 }
 
 func (h *AuthHandler) authenticateUser(login, password string) (*database.User, error) {
-	var user database.User
-	err := h.db.QueryRow(`
-		SELECT id, username, email, password_hash, age, gender, first_name, last_name, created_at 
-		FROM users 
-		WHERE username = ? OR email = ?
-	`, login, login).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.CreatedAt)
-
+	user, err := h.users.GetUserByLogin(login)
 	if err != nil {
 		return nil, err
 	}
@@ -191,36 +204,76 @@ func (h *AuthHandler) authenticateUser(login, password string) (*database.User,
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (h *AuthHandler) createSession(w http.ResponseWriter, user *database.User) error {
-	token, err := h.generateSessionToken()
+// validEmail reports whether email is a bare address (no display name) whose domain has a dot
+func validEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return false
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// minPasswordLength applies to new accounts and password resets
+const minPasswordLength = 6
+
+// maxUserAgentLength caps the user agent stored with a session
+const maxUserAgentLength = 255
+
+// createSession logs the user in on this device. A remembered session gets a
+// persistent cookie that lasts until its absolute deadline; otherwise the cookie
+// is dropped when the browser closes. Either way the server enforces the idle timeout.
+func (h *AuthHandler) createSession(w http.ResponseWriter, r *http.Request, user *database.User, rememberMe bool) error {
+	token, err := generateToken()
 	if err != nil {
 		return err
 	}
 
-	expiresAt := time.Now().UTC().Add(24 * time.Hour)
-	_, err = h.db.Exec("INSERT INTO sessions (user_id, token, expires_at) VALUES (?, ?, ?)", user.ID, token, expiresAt)
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now().UTC()
+	policy := middleware.SessionPolicyFor(rememberMe)
+	absoluteExpiresAt := now.Add(policy.AbsoluteTimeout)
+	err = h.sessions.CreateSession(&database.Session{
+		UserID:            user.ID,
+		Token:             token,
+		ExpiresAt:         policy.Expiry(now, absoluteExpiresAt),
+		UserAgent:         userAgent,
+		IPAddress:         clientIP(r),
+		RememberMe:        rememberMe,
+		AbsoluteExpiresAt: absoluteExpiresAt,
+	})
 	if err != nil {
 		return err
 	}
 
-	http.SetCookie(w, &http.Cookie{
+	cookie := &http.Cookie{
 		Name:     "session_token",
 		Value:    token,
-		Expires:  expiresAt,
 		HttpOnly: true,
 		Path:     "/",
 		SameSite: http.SameSiteLaxMode,
-	})
+	}
+	if rememberMe {
+		cookie.Expires = absoluteExpiresAt
+	}
+	http.SetCookie(w, cookie)
 	return nil
 }
 
 func (h *AuthHandler) clearSession(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("session_token")
 	if err == nil {
-		h.db.Exec("DELETE FROM sessions WHERE token = ?", cookie.Value)
+		h.authMiddleware.EndSession(cookie.Value)
 	}
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
@@ -231,7 +284,18 @@ func (h *AuthHandler) clearSession(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *AuthHandler) generateSessionToken() (string, error) {
+// clientIP returns the address of the peer that sent the request. Forwarding
+// headers are ignored since nothing in front of the server vouches for them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// generateToken returns 32 random bytes, hex encoded, for session cookies and emailed links
+func generateToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
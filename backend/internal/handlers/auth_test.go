@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterValidation(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "alice")
+
+	valid := RegisterRequest{
+		Username:  "bob",
+		Email:     "bob@example.com",
+		Password:  testPassword,
+		Age:       30,
+		FirstName: "Bob",
+		LastName:  "User",
+	}
+	tests := []struct {
+		name   string
+		modify func(req *RegisterRequest)
+		want   int
+	}{
+		{"short username", func(req *RegisterRequest) { req.Username = "bo" }, http.StatusBadRequest},
+		{"email without domain dot", func(req *RegisterRequest) { req.Email = "bob@localhost" }, http.StatusBadRequest},
+		{"email with display name", func(req *RegisterRequest) { req.Email = "Bob <bob@example.com>" }, http.StatusBadRequest},
+		{"email with spaces", func(req *RegisterRequest) { req.Email = "bob smith@example.com" }, http.StatusBadRequest},
+		{"short password", func(req *RegisterRequest) { req.Password = "12345" }, http.StatusBadRequest},
+		{"missing age", func(req *RegisterRequest) { req.Age = 0 }, http.StatusBadRequest},
+		{"missing name", func(req *RegisterRequest) { req.LastName = "" }, http.StatusBadRequest},
+		{"taken username", func(req *RegisterRequest) { req.Username = "alice" }, http.StatusConflict},
+		{"taken email", func(req *RegisterRequest) { req.Email = "alice@example.com" }, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+			rec := e.do(t, e.authHandler.RegisterHandler, http.MethodPost, "/register", req, nil)
+			expectStatus(t, rec, tt.want)
+		})
+	}
+}
+
+func TestRegisterSendsVerificationEmail(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "alice")
+
+	if token := e.mailedToken(t, "alice@example.com", "/#/verify-email"); token == "" {
+		t.Fatal("verification link has an empty token")
+	}
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "alice")
+
+	for _, login := range []string{"alice", "alice@example.com"} {
+		e.login(t, login, testPassword)
+	}
+
+	rec := e.do(t, e.authHandler.LoginHandler, http.MethodPost, "/login", LoginRequest{Login: "alice", Password: "wrong-password"}, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+	rec = e.do(t, e.authHandler.LoginHandler, http.MethodPost, "/login", LoginRequest{Login: "nobody", Password: testPassword}, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	session := e.login(t, "alice", testPassword)
+	whoAmI := e.auth.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	expectStatus(t, e.do(t, whoAmI, http.MethodGet, "/", nil, session), http.StatusNoContent)
+
+	expectStatus(t, e.do(t, e.authHandler.LogoutHandler, http.MethodGet, "/logout", nil, session), http.StatusOK)
+	expectStatus(t, e.do(t, whoAmI, http.MethodGet, "/", nil, session), http.StatusUnauthorized)
+}
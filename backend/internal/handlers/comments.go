@@ -1,31 +1,38 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 
+	"real-time-forum/internal/database"
 	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
 )
 
 // CommentsHandler handles all comment-related HTTP requests
 type CommentsHandler struct {
-	db             *sql.DB
+	posts          database.PostStore
+	comments       database.CommentStore
+	hub            *websocket.Hub
 	authMiddleware *middleware.AuthMiddleware
 }
 
 // NewCommentsHandler creates a new comments handler
-func NewCommentsHandler(db *sql.DB, authMiddleware *middleware.AuthMiddleware) *CommentsHandler {
+func NewCommentsHandler(store database.Store, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *CommentsHandler {
 	return &CommentsHandler{
-		db:             db,
+		posts:          store,
+		comments:       store,
+		hub:            hub,
 		authMiddleware: authMiddleware,
 	}
 }
 
 // CreateCommentRequest represents the JSON payload for creating a comment
 type CreateCommentRequest struct {
-	PostID  int    `json:"post_id"`
-	Content string `json:"content"`
+	PostID   int    `json:"post_id"`
+	ParentID *int   `json:"parent_id"` // Comment being replied to, on the same post
+	Content  string `json:"content"`
 }
 
 // CreateCommentHandler handles comment creation via JSON
@@ -53,8 +60,8 @@ func (h *CommentsHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if len(req.Content) < 1 {
-		h.respondWithError(w, http.StatusBadRequest, "Comment content cannot be empty")
+	if message := validateComment(req.Content); message != "" {
+		h.respondWithError(w, http.StatusBadRequest, message)
 		return
 	}
 
@@ -65,40 +72,138 @@ func (h *CommentsHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	// Create comment
-	commentID, err := h.createComment(req.PostID, currentUser.ID, req.Content)
-	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Error creating comment")
+	comment := &database.Comment{
+		PostID:   req.PostID,
+		ParentID: req.ParentID,
+		UserID:   currentUser.ID,
+		Content:  req.Content,
+	}
+	if err := h.comments.CreateComment(comment); err != nil {
+		if err == database.ErrInvalidParent {
+			h.respondWithError(w, http.StatusBadRequest, "Parent comment not found on this post")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Error creating comment")
+		}
 		return
 	}
 
+	comment.Author = &database.User{ID: currentUser.ID, Username: currentUser.Username}
+	h.publishCommentCreated(comment)
+
 	h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
 		"message":    "Comment created successfully",
-		"comment_id": commentID,
+		"comment_id": comment.ID,
 	})
 }
 
-// createComment creates a new comment in the database
-func (h *CommentsHandler) createComment(postID, userID int, content string) (int64, error) {
-	result, err := h.db.Exec(`
-		INSERT INTO comments (post_id, user_id, content) 
-		VALUES (?, ?, ?)
-	`, postID, userID, content)
+// UpdateCommentRequest represents the JSON payload for editing a comment
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// EditCommentHandler edits (PUT) or soft-deletes (DELETE) the comment /api/comments?id=N
+// via JSON. Only the author or a moderator may change a comment; every change is
+// kept in its revision history. Deleted comments stay in their thread as "[deleted]".
+func (h *CommentsHandler) EditCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	commentID, err := optionalIDParam(r, "id")
+	if err != nil || commentID == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	comment, err := h.comments.GetComment(commentID)
+	if err == database.ErrNotFound || (err == nil && comment.DeletedAt != nil) {
+		h.respondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading comment %d: %v", commentID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error loading comment")
+		return
+	}
+	if !canModify(currentUser, comment.UserID) {
+		h.respondWithError(w, http.StatusForbidden, "You can only change your own comments")
+		return
+	}
+
+	message := "Comment deleted successfully"
+	if r.Method == http.MethodDelete {
+		err = h.comments.DeleteComment(commentID, currentUser.ID)
+	} else {
+		var req UpdateCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		if message := validateComment(req.Content); message != "" {
+			h.respondWithError(w, http.StatusBadRequest, message)
+			return
+		}
+
+		message = "Comment updated successfully"
+		err = h.comments.UpdateComment(commentID, currentUser.ID, req.Content)
+	}
+	if err == database.ErrNotFound {
+		h.respondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error changing comment %d: %v", commentID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error changing comment")
+		return
+	}
+
+	updated, err := h.comments.GetComment(commentID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Error loading comment")
+		return
+	}
+	h.publishCommentUpdated(updated)
 
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"comment": updated,
+	})
+}
+
+// publishCommentUpdated pushes an edited or deleted comment to subscribers of its post thread
+func (h *CommentsHandler) publishCommentUpdated(comment *database.Comment) {
+	_, err := h.hub.Publish([]string{websocket.PostTopic(comment.PostID)}, websocket.NewEvent(websocket.TypeCommentUpdated, map[string]interface{}{
+		"comment": comment,
+	}))
 	if err != nil {
-		return 0, err
+		log.Printf("Error publishing comment %d: %v", comment.ID, err)
 	}
+}
 
-	return result.LastInsertId()
+// publishCommentCreated pushes a new comment to subscribers of its post thread
+func (h *CommentsHandler) publishCommentCreated(comment *database.Comment) {
+	_, err := h.hub.Publish([]string{websocket.PostTopic(comment.PostID)}, websocket.NewEvent(websocket.TypeCommentCreated, map[string]interface{}{
+		"comment": comment,
+	}))
+	if err != nil {
+		log.Printf("Error publishing comment %d: %v", comment.ID, err)
+	}
 }
 
 // postExists checks if a post with the given ID exists
 func (h *CommentsHandler) postExists(postID int) bool {
-	var count int
-	err := h.db.QueryRow("SELECT COUNT(*) FROM posts WHERE id = ?", postID).Scan(&count)
+	exists, err := h.posts.PostExists(postID)
 	if err != nil {
 		return false
 	}
-	return count > 0
+	return exists
 }
 
 func (h *CommentsHandler) respondWithError(w http.ResponseWriter, code int, message string) {
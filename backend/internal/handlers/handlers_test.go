@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
+)
+
+const (
+	// testPassword is the password every test account registers with
+	testPassword = "password123"
+
+	// testBaseURL is the site address used in emailed links
+	testBaseURL = "http://forum.test"
+)
+
+// testEnv wires the handlers to a MemoryStore and a MemoryMailer the way main
+// wires them to the database and the configured mailer
+type testEnv struct {
+	store  *database.MemoryStore
+	mailer *mail.MemoryMailer
+	hub    *websocket.Hub
+	auth   *middleware.AuthMiddleware
+
+	authHandler  *AuthHandler
+	verification *VerificationHandler
+	passwords    *PasswordHandler
+	posts        *PostsHandler
+	votes        *VotesHandler
+	messages     *MessagesHandler
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	e := &testEnv{
+		store:  database.NewMemoryStore(),
+		mailer: &mail.MemoryMailer{},
+		hub:    websocket.NewHub(),
+	}
+	go e.hub.Run()
+
+	e.auth = middleware.NewAuthMiddleware(e.store, e.store)
+	e.auth.SetRequireVerifiedEmail(true)
+	e.verification = NewVerificationHandler(e.store, e.mailer, e.auth, testBaseURL)
+	e.authHandler = NewAuthHandler(e.store, e.store, e.auth, e.verification)
+	e.passwords = NewPasswordHandler(e.store, e.mailer, e.hub, e.auth, testBaseURL)
+	e.posts = NewPostsHandler(e.store, e.hub, e.auth)
+	e.votes = NewVotesHandler(e.store, e.hub, e.auth)
+	e.messages = NewMessagesHandler(e.store, e.hub, e.auth)
+	return e
+}
+
+// do sends a request with an optional JSON body and session cookie to handler
+func (e *testEnv) do(t *testing.T, handler http.HandlerFunc, method, target string, body interface{}, session *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if session != nil {
+		req.AddCookie(session)
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// register creates an account through RegisterHandler and returns its ID
+func (e *testEnv) register(t *testing.T, username string) int {
+	t.Helper()
+
+	rec := e.do(t, e.authHandler.RegisterHandler, http.MethodPost, "/register", RegisterRequest{
+		Username:  username,
+		Email:     username + "@example.com",
+		Password:  testPassword,
+		Age:       30,
+		Gender:    "other",
+		FirstName: "Test",
+		LastName:  "User",
+	}, nil)
+	expectStatus(t, rec, http.StatusCreated)
+
+	var resp struct {
+		UserID int `json:"user_id"`
+	}
+	decode(t, rec, &resp)
+	return resp.UserID
+}
+
+// login signs in and returns the session cookie
+func (e *testEnv) login(t *testing.T, login, password string) *http.Cookie {
+	t.Helper()
+
+	rec := e.do(t, e.authHandler.LoginHandler, http.MethodPost, "/login", LoginRequest{Login: login, Password: password}, nil)
+	expectStatus(t, rec, http.StatusOK)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "session_token" {
+			return cookie
+		}
+	}
+	t.Fatal("login did not set a session cookie")
+	return nil
+}
+
+// signUp registers, verifies and logs in a user, returning its ID and session cookie
+func (e *testEnv) signUp(t *testing.T, username string) (int, *http.Cookie) {
+	t.Helper()
+
+	userID := e.register(t, username)
+	token := e.mailedToken(t, username+"@example.com", "/#/verify-email")
+	rec := e.do(t, e.verification.VerifyEmail, http.MethodPost, "/api/email/verify", VerifyEmailRequest{Token: token}, nil)
+	expectStatus(t, rec, http.StatusOK)
+	return userID, e.login(t, username, testPassword)
+}
+
+// mailedToken returns the token of the latest link to route (such as
+// "/#/verify-email") emailed to address
+func (e *testEnv) mailedToken(t *testing.T, address, route string) string {
+	t.Helper()
+
+	// Some links are sent in the background, after the handler replied
+	prefix := testBaseURL + route + "?token="
+	deadline := time.Now().Add(time.Second)
+	for {
+		messages := e.mailer.Messages()
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].To != address {
+				continue
+			}
+			for _, field := range strings.Fields(messages[i].Body) {
+				if strings.HasPrefix(field, prefix) {
+					token, err := url.QueryUnescape(strings.TrimPrefix(field, prefix))
+					if err != nil {
+						t.Fatalf("unescaping token in %q: %v", field, err)
+					}
+					return token
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s link emailed to %s", route, address)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+	}
+}
@@ -1,30 +1,29 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
+	"real-time-forum/internal/database"
 	"real-time-forum/internal/middleware"
 	"real-time-forum/internal/websocket"
 )
 
 type MessagesHandler struct {
-	db             *sql.DB
+	messages       database.MessageStore
+	users          database.UserStore
 	hub            *websocket.Hub
 	authMiddleware *middleware.AuthMiddleware
-}
 
-type Message struct {
-	ID         int       `json:"id"`
-	SenderID   int       `json:"sender_id"`
-	ReceiverID int       `json:"receiver_id"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
-	IsRead     bool      `json:"is_read"`
+	// IDs of users known to exist. Accounts are never deleted, so entries
+	// never go stale; this keeps typing indicators off the database.
+	knownUsersMu sync.Mutex
+	knownUsers   map[int]bool
 }
 
 type SendMessageRequest struct {
@@ -32,11 +31,46 @@ type SendMessageRequest struct {
 	Content    string `json:"content"`
 }
 
-func NewMessagesHandler(db *sql.DB, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *MessagesHandler {
+// MarkReadRequest marks all messages from SenderID up to and including MessageID as read
+type MarkReadRequest struct {
+	SenderID  int `json:"sender_id"`
+	MessageID int `json:"message_id"`
+}
+
+// ConversationSummary describes a private conversation with another user
+type ConversationSummary struct {
+	UserID      int              `json:"user_id"`
+	Username    string           `json:"username"`
+	LastMessage database.Message `json:"last_message"`
+	UnreadCount int              `json:"unread_count"`
+	Online      bool             `json:"online"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// Message history page sizes
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 100
+)
+
+// Maximum number of characters of the last message shown in a conversation preview
+const messagePreviewChars = 100
+
+// MessageReadEvent is pushed to the sender when the receiver reads their messages
+type MessageReadEvent struct {
+	ReaderID int       `json:"reader_id"`
+	UpToID   int       `json:"up_to_id"`
+	Count    int       `json:"count"`
+	ReadAt   time.Time `json:"read_at"`
+}
+
+func NewMessagesHandler(store database.Store, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *MessagesHandler {
 	return &MessagesHandler{
-		db:             db,
+		messages:       store,
+		users:          store,
 		hub:            hub,
 		authMiddleware: authMiddleware,
+		knownUsers:     make(map[int]bool),
 	}
 }
 
@@ -61,59 +95,242 @@ func (h *MessagesHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate
-	if req.Content == "" {
-		http.Error(w, "Message content cannot be empty", http.StatusBadRequest)
+	// Validate with the same rules as the send_message command
+	if err := h.validateMessage(currentUser.ID, &websocket.SendMessagePayload{ReceiverID: req.ReceiverID, Content: req.Content}); err != nil {
+		http.Error(w, err.Message, commandErrorStatus(err))
 		return
 	}
 
-	if req.ReceiverID == currentUser.ID {
-		http.Error(w, "Cannot send message to yourself", http.StatusBadRequest)
-		return
-	}
-
-	// Save message to database
-	query := `
-		INSERT INTO messages (sender_id, receiver_id, content, created_at, is_read)
-		VALUES (?, ?, ?, ?, ?)
-	`
-	result, err := h.db.Exec(query, currentUser.ID, req.ReceiverID, req.Content, time.Now(), false)
+	message, delivered, err := h.sendMessage(currentUser.ID, req.ReceiverID, req.Content)
 	if err != nil {
 		log.Printf("Error saving message: %v", err)
 		http.Error(w, "Failed to send message", http.StatusInternalServerError)
 		return
 	}
 
-	messageID, _ := result.LastInsertId()
+	// Return success
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   message,
+		"delivered": delivered,
+	})
+}
+
+// RegisterCommands registers the WebSocket commands served by this handler
+func (h *MessagesHandler) RegisterCommands(router *websocket.Router) {
+	router.Handle(websocket.TypeSendMessage, requireVerifiedEmail(h.authMiddleware, h.handleSendMessageCommand))
+	router.Handle(websocket.TypeMarkRead, h.handleMarkReadCommand)
+}
+
+// handleSendMessageCommand handles a send_message command received over WebSocket
+func (h *MessagesHandler) handleSendMessageCommand(c *websocket.Client, payload interface{}) (interface{}, error) {
+	p := payload.(*websocket.SendMessagePayload)
+
+	if err := h.validateMessage(c.UserID, p); err != nil {
+		return nil, err
+	}
+
+	message, delivered, err := h.sendMessage(c.UserID, p.ReceiverID, p.Content)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create message object
-	message := Message{
-		ID:         int(messageID),
-		SenderID:   currentUser.ID,
-		ReceiverID: req.ReceiverID,
-		Content:    req.Content,
+	return map[string]interface{}{
+		"message":   message,
+		"delivered": delivered,
+	}, nil
+}
+
+// validateMessage checks a private message before it is stored, whether it was
+// sent over HTTP or as a send_message command
+func (h *MessagesHandler) validateMessage(senderID int, p *websocket.SendMessagePayload) *websocket.CommandError {
+	if err := p.Validate(); err != nil {
+		return websocket.NewCommandError(websocket.ErrCodeInvalidPayload, err.Error())
+	}
+	if p.ReceiverID == senderID {
+		return websocket.NewCommandError(websocket.ErrCodeInvalidPayload, "Cannot send message to yourself")
+	}
+	if !h.userExists(p.ReceiverID) {
+		return websocket.NewCommandError(websocket.ErrCodeNotFound, "Receiver not found")
+	}
+	return nil
+}
+
+// commandErrorStatus maps a command error to the status code the HTTP endpoints use for it
+func commandErrorStatus(err *websocket.CommandError) int {
+	switch err.Code {
+	case websocket.ErrCodeNotFound:
+		return http.StatusNotFound
+	case websocket.ErrCodeForbidden:
+		return http.StatusForbidden
+	case websocket.ErrCodeInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// sendMessage stores a private message and pushes it to every connection the receiver has open.
+// Returns the stored message and the number of connections it was delivered to.
+func (h *MessagesHandler) sendMessage(senderID, receiverID int, content string) (*database.Message, int, error) {
+	message := &database.Message{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Content:    content,
 		CreatedAt:  time.Now(),
 		IsRead:     false,
 	}
+	if err := h.messages.CreateMessage(message); err != nil {
+		return nil, 0, err
+	}
 
-	// Send via WebSocket if receiver is online
-	err = h.hub.SendToUser(req.ReceiverID, map[string]interface{}{
-		"type":    "new_message",
+	// Send via WebSocket to all of the receiver's open connections
+	delivered, err := h.hub.SendToUser(receiverID, websocket.NewEvent(websocket.TypeNewMessage, map[string]interface{}{
 		"message": message,
-	})
+	}))
 	if err != nil {
 		log.Printf("Error sending WebSocket message: %v", err)
 	}
 
-	// Return success
+	// Record delivery if at least one of the receiver's devices got it
+	if delivered > 0 {
+		deliveredAt := time.Now()
+		if err := h.messages.MarkDelivered(message.ID, deliveredAt); err != nil {
+			log.Printf("Error marking message %d as delivered: %v", message.ID, err)
+		} else {
+			message.DeliveredAt = &deliveredAt
+		}
+	}
+	message.SetStatus()
+
+	return message, delivered, nil
+}
+
+// MarkRead marks messages from another user as read up to a message ID
+func (h *MessagesHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.SenderID <= 0 || req.MessageID <= 0 {
+		http.Error(w, "sender_id and message_id are required", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.markRead(currentUser.ID, req.SenderID, req.MessageID)
+	if err != nil {
+		log.Printf("Error marking messages as read: %v", err)
+		http.Error(w, "Failed to mark messages as read", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": message,
+		"updated": count,
 	})
 }
 
-// GetMessageHistory retrieves message history between two users
+// handleMarkReadCommand handles a mark_read command received over WebSocket
+func (h *MessagesHandler) handleMarkReadCommand(c *websocket.Client, payload interface{}) (interface{}, error) {
+	p := payload.(*websocket.MarkReadPayload)
+
+	count, err := h.markRead(c.UserID, p.SenderID, p.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"updated": count}, nil
+}
+
+// markRead marks unread messages from senderID to readerID with id <= upToID as read
+// and notifies the sender. Returns the number of messages that changed state.
+func (h *MessagesHandler) markRead(readerID, senderID, upToID int) (int, error) {
+	readAt := time.Now()
+
+	affected, err := h.messages.MarkRead(readerID, senderID, upToID, readAt)
+	if err != nil {
+		return 0, err
+	}
+
+	if affected > 0 {
+		_, err := h.hub.SendToUser(senderID, websocket.NewEvent(websocket.TypeMessageRead, MessageReadEvent{
+			ReaderID: readerID,
+			UpToID:   upToID,
+			Count:    affected,
+			ReadAt:   readAt,
+		}))
+		if err != nil {
+			log.Printf("Error sending read receipt: %v", err)
+		}
+	}
+
+	return affected, nil
+}
+
+// optionalIDParam parses an optional positive integer query parameter, returning 0 if absent
+func optionalIDParam(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return id, nil
+}
+
+// CheckTyping only lets typing indicators through to an existing user. Anyone may
+// message anyone, so that includes users the sender is about to write to for the
+// first time. It implements websocket.TypingGuard and runs for every typing
+// frame, so it is answered from memory once the receiver has been seen.
+func (h *MessagesHandler) CheckTyping(senderID, receiverID int) error {
+	if !h.userExists(receiverID) {
+		return websocket.NewCommandError(websocket.ErrCodeNotFound, "Receiver not found")
+	}
+	return nil
+}
+
+// userExists checks if a user with the given ID exists
+func (h *MessagesHandler) userExists(userID int) bool {
+	h.knownUsersMu.Lock()
+	known := h.knownUsers[userID]
+	h.knownUsersMu.Unlock()
+	if known {
+		return true
+	}
+
+	if _, err := h.users.GetUserByID(userID); err != nil {
+		return false
+	}
+	h.knownUsersMu.Lock()
+	h.knownUsers[userID] = true
+	h.knownUsersMu.Unlock()
+	return true
+}
+
+// GetMessageHistory retrieves message history between two users.
+// Without a cursor it returns the newest page; before_id pages step back through
+// older messages and after_id pages step forward through newer ones. Every page
+// lists its messages oldest first.
 func (h *MessagesHandler) GetMessageHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -140,58 +357,126 @@ func (h *MessagesHandler) GetMessageHistory(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Get limit (default 50)
-	limit := 50
+	// Get limit (default 50, capped at maxHistoryLimit)
+	limit := defaultHistoryLimit
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
 			limit = l
 		}
 	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
 
-	// Fetch messages from database
-	query := `
-		SELECT id, sender_id, receiver_id, content, created_at, is_read
-		FROM messages
-		WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)
-		ORDER BY created_at DESC
-		LIMIT ?
-	`
+	// Cursors are message IDs, so pages stay stable while new messages arrive
+	beforeID, err := optionalIDParam(r, "before_id")
+	if err != nil {
+		http.Error(w, "Invalid before_id", http.StatusBadRequest)
+		return
+	}
+	afterID, err := optionalIDParam(r, "after_id")
+	if err != nil {
+		http.Error(w, "Invalid after_id", http.StatusBadRequest)
+		return
+	}
+	if beforeID > 0 && afterID > 0 {
+		http.Error(w, "before_id and after_id cannot be combined", http.StatusBadRequest)
+		return
+	}
 
-	rows, err := h.db.Query(query, currentUser.ID, otherUserID, otherUserID, currentUser.ID, limit)
+	// One extra row is fetched to know whether another page exists
+	messages, err := h.messages.ListMessages(currentUser.ID, otherUserID, database.MessagePage{
+		BeforeID: beforeID,
+		AfterID:  afterID,
+		Limit:    limit + 1,
+	})
 	if err != nil {
 		log.Printf("Error fetching messages: %v", err)
 		http.Error(w, "Failed to fetch messages", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	messages := []Message{}
-	for rows.Next() {
-		var msg Message
-		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt, &msg.IsRead)
-		if err != nil {
-			log.Printf("Error scanning message: %v", err)
-			continue
+	// The extra row is the one furthest from the cursor: the newest when paging
+	// forwards, the oldest otherwise. The next cursor is the ID at that end of the
+	// page, to be passed back as after_id or before_id.
+	hasMore := len(messages) > limit
+	var nextCursor *int
+	if hasMore {
+		if afterID > 0 {
+			messages = messages[:limit]
+			nextCursor = &messages[len(messages)-1].ID
+		} else {
+			messages = messages[1:]
+			nextCursor = &messages[0].ID
 		}
-		messages = append(messages, msg)
 	}
 
-	// Mark messages as read
-	updateQuery := `
-		UPDATE messages 
-		SET is_read = 1 
-		WHERE sender_id = ? AND receiver_id = ? AND is_read = 0
-	`
-	_, err = h.db.Exec(updateQuery, otherUserID, currentUser.ID)
+	// Fetching history counts as delivery; read state only changes through MarkRead
+	err = h.messages.MarkConversationDelivered(otherUserID, currentUser.ID, time.Now())
 	if err != nil {
-		log.Printf("Error marking messages as read: %v", err)
+		log.Printf("Error marking messages as delivered: %v", err)
 	}
 
 	// Return messages
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":  true,
-		"messages": messages,
+		"success":     true,
+		"messages":    messages,
+		"has_more":    hasMore,
+		"next_cursor": nextCursor,
+	})
+}
+
+// GetConversations lists every user the current user has exchanged messages with,
+// most recent conversation first, with a preview of the last message and the unread count
+func (h *MessagesHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	threads, err := h.messages.ListConversations(currentUser.ID)
+	if err != nil {
+		log.Printf("Error fetching conversations: %v", err)
+		http.Error(w, "Failed to fetch conversations", http.StatusInternalServerError)
+		return
+	}
+
+	online := make(map[int]bool)
+	for _, id := range h.hub.GetOnlineUserIDs() {
+		online[id] = true
+	}
+
+	conversations := []ConversationSummary{}
+	for _, thread := range threads {
+		conv := ConversationSummary{
+			UserID:      thread.OtherUserID,
+			Username:    thread.OtherUsername,
+			LastMessage: thread.LastMessage,
+			UnreadCount: thread.UnreadCount,
+		}
+
+		msg := &conv.LastMessage
+		if preview := []rune(msg.Content); len(preview) > messagePreviewChars {
+			msg.Content = string(preview[:messagePreviewChars]) + "…"
+		}
+
+		conv.Online = online[conv.UserID]
+		conv.UpdatedAt = msg.CreatedAt
+		conversations = append(conversations, conv)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"conversations": conversations,
 	})
 }
 
@@ -222,53 +507,25 @@ func (h *MessagesHandler) GetOnlineUsers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Build query with placeholders
-	placeholders := ""
-	args := []interface{}{}
-	for i, id := range onlineUserIDs {
-		if i > 0 {
-			placeholders += ","
-		}
-		placeholders += "?"
-		args = append(args, id)
-	}
-
-	query := `
-		SELECT id, username, email, created_at
-		FROM users
-		WHERE id IN (` + placeholders + `)
-	`
-
-	rows, err := h.db.Query(query, args...)
+	onlineUsers, err := h.users.GetUsersByIDs(onlineUserIDs)
 	if err != nil {
 		log.Printf("Error fetching online users: %v", err)
 		http.Error(w, "Failed to fetch online users", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	users := []map[string]interface{}{}
-	for rows.Next() {
-		var id int
-		var username, email string
-		var createdAt time.Time
-
-		err := rows.Scan(&id, &username, &email, &createdAt)
-		if err != nil {
-			log.Printf("Error scanning user: %v", err)
-			continue
-		}
-
+	for _, user := range onlineUsers {
 		// Don't include current user in the list
-		if id == currentUser.ID {
+		if user.ID == currentUser.ID {
 			continue
 		}
 
 		users = append(users, map[string]interface{}{
-			"id":         id,
-			"username":   username,
-			"email":      email,
-			"created_at": createdAt,
+			"id":         user.ID,
+			"username":   user.Username,
+			"email":      user.Email,
+			"created_at": user.CreatedAt,
 		})
 	}
 
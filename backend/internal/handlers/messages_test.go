@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/websocket"
+)
+
+// sendMessage sends a private message through SendMessage and returns the response
+func (e *testEnv) sendMessage(t *testing.T, session *http.Cookie, receiverID int, content string) (int, *database.Message) {
+	t.Helper()
+
+	rec := e.do(t, e.auth.RequireVerifiedEmail(e.messages.SendMessage), http.MethodPost, "/api/messages/send",
+		SendMessageRequest{ReceiverID: receiverID, Content: content}, session)
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+
+	var resp struct {
+		Message database.Message `json:"message"`
+	}
+	decode(t, rec, &resp)
+	return rec.Code, &resp.Message
+}
+
+// historyPage is the JSON body returned by GetMessageHistory
+type historyPage struct {
+	Messages   []database.Message `json:"messages"`
+	HasMore    bool               `json:"has_more"`
+	NextCursor *int               `json:"next_cursor"`
+}
+
+func (e *testEnv) history(t *testing.T, session *http.Cookie, query string) historyPage {
+	t.Helper()
+
+	rec := e.do(t, e.auth.RequireAuth(e.messages.GetMessageHistory), http.MethodGet, "/api/messages/history?"+query, nil, session)
+	expectStatus(t, rec, http.StatusOK)
+
+	var page historyPage
+	decode(t, rec, &page)
+	return page
+}
+
+func TestSendMessageValidation(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID, alice := e.signUp(t, "alice")
+	bobID, _ := e.signUp(t, "bob")
+
+	tests := []struct {
+		name       string
+		receiverID int
+		content    string
+		want       int
+	}{
+		{"to self", aliceID, "hello", http.StatusBadRequest},
+		{"unknown receiver", bobID + 100, "hello", http.StatusNotFound},
+		{"empty", bobID, "", http.StatusBadRequest},
+		{"only whitespace", bobID, "  \n ", http.StatusBadRequest},
+		{"too long", bobID, strings.Repeat("a", 2001), http.StatusBadRequest},
+		{"at the limit", bobID, strings.Repeat("é", 2000), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := e.sendMessage(t, alice, tt.receiverID, tt.content); code != tt.want {
+				t.Errorf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTyping(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID, _ := e.signUp(t, "alice")
+	bobID, _ := e.signUp(t, "bob")
+
+	// No messages yet: typing the first one still shows the indicator
+	if err := e.messages.CheckTyping(aliceID, bobID); err != nil {
+		t.Errorf("typing to a user without a conversation: %v", err)
+	}
+
+	var cmdErr *websocket.CommandError
+	err := e.messages.CheckTyping(aliceID, bobID+100)
+	if !errors.As(err, &cmdErr) || cmdErr.Code != websocket.ErrCodeNotFound {
+		t.Errorf("typing to an unknown user: err = %v, want %s", err, websocket.ErrCodeNotFound)
+	}
+}
+
+func TestMessageHistoryPages(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID, alice := e.signUp(t, "alice")
+	bobID, bob := e.signUp(t, "bob")
+
+	var ids []int
+	for i := 0; i < 5; i++ {
+		session, receiverID := alice, bobID
+		if i%2 == 1 {
+			session, receiverID = bob, aliceID
+		}
+		_, msg := e.sendMessage(t, session, receiverID, fmt.Sprintf("message %d", i))
+		if msg == nil {
+			t.Fatalf("sending message %d failed", i)
+		}
+		ids = append(ids, msg.ID)
+	}
+
+	// Every page lists its messages oldest first, whichever way it steps
+	checkPage := func(name string, page historyPage, want []int, wantCursor int) {
+		t.Helper()
+		var got []int
+		for _, msg := range page.Messages {
+			got = append(got, msg.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: messages = %v, want %v", name, got, want)
+		}
+		switch {
+		case wantCursor == 0 && page.NextCursor != nil:
+			t.Errorf("%s: next cursor = %d, want none", name, *page.NextCursor)
+		case wantCursor != 0 && (page.NextCursor == nil || *page.NextCursor != wantCursor):
+			t.Errorf("%s: next cursor = %v, want %d", name, page.NextCursor, wantCursor)
+		}
+	}
+
+	userID := fmt.Sprintf("user_id=%d&limit=2", bobID)
+	checkPage("newest", e.history(t, alice, userID), ids[3:5], ids[3])
+	checkPage("older", e.history(t, alice, fmt.Sprintf("%s&before_id=%d", userID, ids[3])), ids[1:3], ids[1])
+	checkPage("oldest", e.history(t, alice, fmt.Sprintf("%s&before_id=%d", userID, ids[1])), ids[0:1], 0)
+	checkPage("newer", e.history(t, alice, fmt.Sprintf("%s&after_id=%d", userID, ids[0])), ids[1:3], ids[2])
+	checkPage("newest forward", e.history(t, alice, fmt.Sprintf("%s&after_id=%d", userID, ids[2])), ids[3:5], 0)
+
+	// Both sides see the same conversation
+	checkPage("other side", e.history(t, bob, fmt.Sprintf("user_id=%d&limit=2", aliceID)), ids[3:5], ids[3])
+
+	rec := e.do(t, e.auth.RequireAuth(e.messages.GetMessageHistory), http.MethodGet,
+		fmt.Sprintf("/api/messages/history?%s&before_id=%d&after_id=%d", userID, ids[3], ids[1]), nil, alice)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestMarkRead(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID, alice := e.signUp(t, "alice")
+	bobID, bob := e.signUp(t, "bob")
+
+	_, first := e.sendMessage(t, alice, bobID, "first")
+	_, second := e.sendMessage(t, alice, bobID, "second")
+
+	markRead := e.auth.RequireAuth(e.messages.MarkRead)
+	rec := e.do(t, markRead, http.MethodPost, "/api/messages/read", MarkReadRequest{SenderID: aliceID, MessageID: first.ID}, bob)
+	expectStatus(t, rec, http.StatusOK)
+
+	var resp struct {
+		Updated int `json:"updated"`
+	}
+	decode(t, rec, &resp)
+	if resp.Updated != 1 {
+		t.Errorf("updated = %d, want 1", resp.Updated)
+	}
+
+	page := e.history(t, alice, fmt.Sprintf("user_id=%d", bobID))
+	if len(page.Messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(page.Messages))
+	}
+	if !page.Messages[0].IsRead || page.Messages[0].Status != "read" {
+		t.Errorf("message %d = %+v, want read", first.ID, page.Messages[0])
+	}
+	if page.Messages[1].IsRead {
+		t.Errorf("message %d = %+v, want unread", second.ID, page.Messages[1])
+	}
+
+	// Only the receiver can mark a message read
+	rec = e.do(t, markRead, http.MethodPost, "/api/messages/read", MarkReadRequest{SenderID: bobID, MessageID: second.ID}, alice)
+	expectStatus(t, rec, http.StatusOK)
+	decode(t, rec, &resp)
+	if resp.Updated != 0 {
+		t.Errorf("sender marked %d of their own messages read", resp.Updated)
+	}
+
+	rec = e.do(t, markRead, http.MethodPost, "/api/messages/read", MarkReadRequest{SenderID: aliceID}, bob)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// passwordResetTTL is how long an emailed reset link works
+	passwordResetTTL = time.Hour
+
+	// passwordResetInterval is the minimum time between reset emails to one account
+	passwordResetInterval = 2 * time.Minute
+)
+
+// PasswordHandler lets users who forgot their password choose a new one
+// through a single-use link sent to their email address
+type PasswordHandler struct {
+	users          database.UserStore
+	sessions       database.SessionStore
+	resets         database.PasswordResetStore
+	mailer         mail.Mailer
+	hub            *websocket.Hub
+	authMiddleware *middleware.AuthMiddleware
+	baseURL        string // Public address of the site, used in emailed links
+	throttle       *sendThrottle
+}
+
+// NewPasswordHandler creates a new password handler
+func NewPasswordHandler(store database.Store, mailer mail.Mailer, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware, baseURL string) *PasswordHandler {
+	return &PasswordHandler{
+		users:          store,
+		sessions:       store,
+		resets:         store,
+		mailer:         mailer,
+		hub:            hub,
+		authMiddleware: authMiddleware,
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		throttle:       newSendThrottle(passwordResetInterval),
+	}
+}
+
+// ForgotPasswordRequest is the JSON payload for POST /api/password/forgot
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the JSON payload for POST /api/password/reset
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// forgotPasswordReply is returned whether or not the address has an account,
+// so the endpoint can't be used to find out who is registered
+const forgotPasswordReply = "If an account uses that email address, a reset link is on its way"
+
+// ForgotPassword handles POST /api/password/forgot.
+// It emails a reset link valid for passwordResetTTL, replacing any earlier link.
+func (h *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	// GetUserByLogin also matches usernames; only an email address may start a reset
+	user, err := h.users.GetUserByLogin(email)
+	if err != nil || !strings.EqualFold(user.Email, email) {
+		if err != nil && err != database.ErrNotFound {
+			log.Printf("Error looking up user for password reset: %v", err)
+		}
+		h.respondWithJSON(w, http.StatusOK, map[string]string{"message": forgotPasswordReply})
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": forgotPasswordReply})
+
+	// The link is sent after replying and a failure is only logged: waiting for
+	// the mail server, or a different reply, would reveal that the account exists
+	if h.throttle.allow(user.ID, time.Now()) {
+		go func() {
+			if err := h.sendResetLink(user); err != nil {
+				log.Printf("Error sending password reset to user %d: %v", user.ID, err)
+			}
+		}()
+	}
+}
+
+// sendResetLink stores a fresh reset token for user and emails it
+func (h *PasswordHandler) sendResetLink(user *database.User) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	reset := &database.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(passwordResetTTL),
+	}
+	if err := h.resets.CreatePasswordReset(reset); err != nil {
+		return err
+	}
+
+	link := h.baseURL + "/#/reset-password?token=" + url.QueryEscape(token)
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your forum password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"To choose a new one, open this link within the next hour:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email; your password stays the same.\n",
+			user.Username, link),
+	})
+}
+
+// ResetPassword handles POST /api/password/reset.
+// It redeems a reset token, sets the new password and logs the account out
+// everywhere, closing its live connections.
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Token == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Reset token is required")
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		h.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+
+	userID, err := h.resets.ResetPassword(hashToken(req.Token), string(hashedPassword), time.Now().UTC())
+	if err != nil {
+		if err == database.ErrNotFound {
+			h.respondWithError(w, http.StatusBadRequest, "This reset link is invalid or has expired")
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+
+	h.revokeSessions(userID)
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password updated. Please log in with your new password."})
+}
+
+// revokeSessions logs a user out of every device after their password changed.
+// The password is already changed at this point, so failures are only logged.
+func (h *PasswordHandler) revokeSessions(userID int) {
+	sessions, err := h.sessions.ListUserSessions(userID, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error listing sessions of user %d: %v", userID, err)
+	}
+
+	if err := h.authMiddleware.RevokeUserSessions(userID); err != nil {
+		log.Printf("Error revoking sessions of user %d: %v", userID, err)
+		return
+	}
+
+	sessionIDs := make([]int, len(sessions))
+	for i, session := range sessions {
+		sessionIDs[i] = session.ID
+	}
+	h.hub.CloseSessions(sessionIDs)
+}
+
+// hashToken returns the hex SHA-256 of an emailed token, the form it is stored in.
+// Tokens are random, so a fast unsalted hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendThrottle limits how often an email can be sent for the same user
+type sendThrottle struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[int]time.Time // user ID -> last send
+}
+
+func newSendThrottle(interval time.Duration) *sendThrottle {
+	return &sendThrottle{interval: interval, last: make(map[int]time.Time)}
+}
+
+// allow reports whether an email may be sent for userID at now, and if so records it
+func (t *sendThrottle) allow(userID int, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.last[userID]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	t.last[userID] = now
+
+	// Forget users whose interval has passed so the map stays small
+	for id, at := range t.last {
+		if now.Sub(at) >= t.interval {
+			delete(t.last, id)
+		}
+	}
+	return true
+}
+
+func (h *PasswordHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func (h *PasswordHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/mail"
+)
+
+// forgotPassword asks for a reset link and returns the reply body
+func (e *testEnv) forgotPassword(t *testing.T, handler *PasswordHandler, email string) string {
+	t.Helper()
+
+	rec := e.do(t, handler.ForgotPassword, http.MethodPost, "/api/password/forgot", ForgotPasswordRequest{Email: email}, nil)
+	expectStatus(t, rec, http.StatusOK)
+	return rec.Body.String()
+}
+
+func (e *testEnv) resetPassword(t *testing.T, token, password string) int {
+	t.Helper()
+
+	rec := e.do(t, e.passwords.ResetPassword, http.MethodPost, "/api/password/reset", ResetPasswordRequest{Token: token, Password: password}, nil)
+	return rec.Code
+}
+
+func TestPasswordResetRoundTrip(t *testing.T) {
+	e := newTestEnv(t)
+	_, oldSession := e.signUp(t, "alice")
+	whoAmI := e.auth.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	expectStatus(t, e.do(t, whoAmI, http.MethodGet, "/", nil, oldSession), http.StatusNoContent)
+
+	e.forgotPassword(t, e.passwords, "alice@example.com")
+	token := e.mailedToken(t, "alice@example.com", "/#/reset-password")
+
+	if code := e.resetPassword(t, token, "short"); code != http.StatusBadRequest {
+		t.Fatalf("short password: status = %d, want 400", code)
+	}
+	if code := e.resetPassword(t, token, "new-password"); code != http.StatusOK {
+		t.Fatalf("reset: status = %d, want 200", code)
+	}
+
+	// The link works once
+	if code := e.resetPassword(t, token, "another-password"); code != http.StatusBadRequest {
+		t.Errorf("reusing the token: status = %d, want 400", code)
+	}
+
+	// Every session from before the reset is logged out
+	expectStatus(t, e.do(t, whoAmI, http.MethodGet, "/", nil, oldSession), http.StatusUnauthorized)
+
+	rec := e.do(t, e.authHandler.LoginHandler, http.MethodPost, "/login", LoginRequest{Login: "alice", Password: testPassword}, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+	newSession := e.login(t, "alice", "new-password")
+	expectStatus(t, e.do(t, whoAmI, http.MethodGet, "/", nil, newSession), http.StatusNoContent)
+}
+
+func TestPasswordResetExpiredToken(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID := e.register(t, "alice")
+
+	err := e.store.CreatePasswordReset(&database.PasswordReset{
+		UserID:    aliceID,
+		TokenHash: hashToken("expired-token"),
+		ExpiresAt: time.Now().UTC().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := e.resetPassword(t, "expired-token", "new-password"); code != http.StatusBadRequest {
+		t.Errorf("expired token: status = %d, want 400", code)
+	}
+	if code := e.resetPassword(t, "unknown-token", "new-password"); code != http.StatusBadRequest {
+		t.Errorf("unknown token: status = %d, want 400", code)
+	}
+	e.login(t, "alice", testPassword)
+}
+
+// failingMailer fails every send
+type failingMailer struct{}
+
+func (failingMailer) Send(mail.Message) error {
+	return errors.New("mail server unavailable")
+}
+
+// TestForgotPasswordReplyIsUniform checks the reply can't tell registered
+// addresses apart, even when sending the email fails
+func TestForgotPasswordReplyIsUniform(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "alice")
+
+	unknown := e.forgotPassword(t, e.passwords, "nobody@example.com")
+	if known := e.forgotPassword(t, e.passwords, "alice@example.com"); known != unknown {
+		t.Errorf("reply for a registered address = %s, want %s", known, unknown)
+	}
+
+	failing := NewPasswordHandler(e.store, failingMailer{}, e.hub, e.auth, testBaseURL)
+	if known := e.forgotPassword(t, failing, "alice@example.com"); known != unknown {
+		t.Errorf("reply when the email fails = %s, want %s", known, unknown)
+	}
+}
+
+// blockingMailer holds every send until release is closed
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mail.Message
+}
+
+func (m *blockingMailer) Send(msg mail.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+// TestForgotPasswordRepliesBeforeSending checks a slow mail server can't be
+// used to time which addresses have an account
+func TestForgotPasswordRepliesBeforeSending(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "alice")
+
+	mailer := &blockingMailer{release: make(chan struct{}), sent: make(chan mail.Message, 1)}
+	defer close(mailer.release)
+	slow := NewPasswordHandler(e.store, mailer, e.hub, e.auth, testBaseURL)
+
+	replied := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		replied <- e.do(t, slow.ForgotPassword, http.MethodPost, "/api/password/forgot", ForgotPasswordRequest{Email: "alice@example.com"}, nil)
+	}()
+	select {
+	case rec := <-replied:
+		expectStatus(t, rec, http.StatusOK)
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler waited for the mail server before replying")
+	}
+
+	select {
+	case msg := <-mailer.sent:
+		t.Fatalf("email to %s finished before it was released", msg.To)
+	default:
+	}
+
+	mailer.release <- struct{}{}
+	select {
+	case msg := <-mailer.sent:
+		if msg.To != "alice@example.com" {
+			t.Errorf("reset link sent to %s, want alice@example.com", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reset link was never sent")
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"real-time-forum/internal/database"
+)
+
+// createPost creates a post through the route handler and returns its ID
+func (e *testEnv) createPost(t *testing.T, session *http.Cookie, title, content string) int {
+	t.Helper()
+
+	rec := e.do(t, e.auth.RequireVerifiedEmail(e.posts.CreatePostHandler), http.MethodPost, "/posts/create",
+		CreatePostRequest{Title: title, Content: content, CategoryIDs: []string{"1"}}, session)
+	expectStatus(t, rec, http.StatusCreated)
+
+	var resp struct {
+		PostID int `json:"post_id"`
+	}
+	decode(t, rec, &resp)
+	return resp.PostID
+}
+
+// listPosts returns the first page of the post listing as seen with session (nil = anonymous)
+func (e *testEnv) listPosts(t *testing.T, session *http.Cookie, query string) PostListResponse {
+	t.Helper()
+
+	rec := e.do(t, e.auth.AddUserToContext(e.posts.ListPostsHandler), http.MethodGet, "/posts?"+query, nil, session)
+	expectStatus(t, rec, http.StatusOK)
+
+	var resp PostListResponse
+	decode(t, rec, &resp)
+	return resp
+}
+
+func TestCreatePostRequiresVerifiedEmail(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "alice")
+	session := e.login(t, "alice", testPassword)
+
+	create := e.auth.RequireVerifiedEmail(e.posts.CreatePostHandler)
+	post := CreatePostRequest{Title: "Hello", Content: "A first post body", CategoryIDs: []string{"1"}}
+
+	expectStatus(t, e.do(t, create, http.MethodPost, "/posts/create", post, nil), http.StatusUnauthorized)
+	expectStatus(t, e.do(t, create, http.MethodPost, "/posts/create", post, session), http.StatusForbidden)
+
+	token := e.mailedToken(t, "alice@example.com", "/#/verify-email")
+	rec := e.do(t, e.verification.VerifyEmail, http.MethodPost, "/api/email/verify", VerifyEmailRequest{Token: token}, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	expectStatus(t, e.do(t, create, http.MethodPost, "/posts/create", post, session), http.StatusCreated)
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	e := newTestEnv(t)
+	_, session := e.signUp(t, "alice")
+	create := e.auth.RequireVerifiedEmail(e.posts.CreatePostHandler)
+
+	tests := []struct {
+		name string
+		req  CreatePostRequest
+	}{
+		{"missing title", CreatePostRequest{Content: "A long enough body", CategoryIDs: []string{"1"}}},
+		{"short content", CreatePostRequest{Title: "Hello", Content: "short", CategoryIDs: []string{"1"}}},
+		{"no categories", CreatePostRequest{Title: "Hello", Content: "A long enough body"}},
+		{"long title", CreatePostRequest{Title: strings.Repeat("t", maxPostTitleLength+1), Content: "A long enough body", CategoryIDs: []string{"1"}}},
+		{"long content", CreatePostRequest{Title: "Hello", Content: strings.Repeat("c", maxPostContentLength+1), CategoryIDs: []string{"1"}}},
+		{"category not a number", CreatePostRequest{Title: "Hello", Content: "A long enough body", CategoryIDs: []string{"1", "sport"}}},
+		{"unknown category", CreatePostRequest{Title: "Hello", Content: "A long enough body", CategoryIDs: []string{"999"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, e.do(t, create, http.MethodPost, "/posts/create", tt.req, session), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestPostLengthLimitsCountCharacters(t *testing.T) {
+	e := newTestEnv(t)
+	_, session := e.signUp(t, "alice")
+	create := e.auth.RequireVerifiedEmail(e.posts.CreatePostHandler)
+
+	// Each "é" is two bytes, so these are within the limits only when counting characters
+	post := CreatePostRequest{
+		Title:       strings.Repeat("é", maxPostTitleLength),
+		Content:     strings.Repeat("é", maxPostContentLength),
+		CategoryIDs: []string{"1"},
+	}
+	expectStatus(t, e.do(t, create, http.MethodPost, "/posts/create", post, session), http.StatusCreated)
+
+	// Ten characters, but fewer would pass a byte count
+	post = CreatePostRequest{Title: "Hello", Content: strings.Repeat("é", 9), CategoryIDs: []string{"1"}}
+	expectStatus(t, e.do(t, create, http.MethodPost, "/posts/create", post, session), http.StatusBadRequest)
+}
+
+func TestListPosts(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID, alice := e.signUp(t, "alice")
+	_, bob := e.signUp(t, "bob")
+
+	first := e.createPost(t, alice, "First post", "Written by alice")
+	second := e.createPost(t, bob, "Second post", "Written by bob")
+
+	resp := e.listPosts(t, nil, "")
+	if len(resp.Posts) != 2 || resp.Posts[0].ID != second || resp.Posts[1].ID != first {
+		t.Fatalf("listing = %+v, want posts %d then %d", resp.Posts, second, first)
+	}
+	if resp.Pagination.TotalItems != 2 {
+		t.Errorf("total = %d, want 2", resp.Pagination.TotalItems)
+	}
+	if author := resp.Posts[1].Author; author == nil || author.ID != aliceID {
+		t.Errorf("author of post %d = %+v, want user %d", first, author, aliceID)
+	}
+
+	mine := e.listPosts(t, alice, "filter=my-posts")
+	if len(mine.Posts) != 1 || mine.Posts[0].ID != first {
+		t.Errorf("my-posts for alice = %+v, want only post %d", mine.Posts, first)
+	}
+
+	page := e.listPosts(t, nil, "limit=1&offset=1")
+	if len(page.Posts) != 1 || page.Posts[0].ID != first {
+		t.Errorf("second page = %+v, want only post %d", page.Posts, first)
+	}
+
+	rec := e.do(t, e.posts.ListPostsHandler, http.MethodGet, "/posts?limit=abc", nil, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestEditAndDeletePost(t *testing.T) {
+	e := newTestEnv(t)
+	_, alice := e.signUp(t, "alice")
+	_, bob := e.signUp(t, "bob")
+	postID := e.createPost(t, alice, "Original title", "Original content")
+
+	edit := e.auth.RequireAuth(e.posts.EditPostHandler)
+	target := "/api/posts?id=" + strconv.Itoa(postID)
+	update := UpdatePostRequest{Title: "Edited title", Content: "Edited content"}
+
+	expectStatus(t, e.do(t, edit, http.MethodPut, target, update, bob), http.StatusForbidden)
+	expectStatus(t, e.do(t, edit, http.MethodPut, target, update, alice), http.StatusOK)
+
+	post, err := e.store.GetPost(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "Edited title" {
+		t.Errorf("title = %q, want the edited title", post.Title)
+	}
+
+	expectStatus(t, e.do(t, edit, http.MethodDelete, target, nil, alice), http.StatusOK)
+	if exists, _ := e.store.PostExists(postID); exists {
+		t.Error("deleted post still exists")
+	}
+	if resp := e.listPosts(t, nil, ""); len(resp.Posts) != 0 {
+		t.Errorf("listing after delete = %+v, want none", resp.Posts)
+	}
+
+	revisions, err := e.store.ListRevisions(database.VoteTargetPost, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 {
+		t.Errorf("got %d revisions, want original, edit and delete", len(revisions))
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
+)
+
+// PresenceHandler persists user presence and serves presence information
+type PresenceHandler struct {
+	presence       database.PresenceStore
+	hub            *websocket.Hub
+	authMiddleware *middleware.AuthMiddleware
+}
+
+// NewPresenceHandler creates a new presence handler
+func NewPresenceHandler(presence database.PresenceStore, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *PresenceHandler {
+	return &PresenceHandler{
+		presence:       presence,
+		hub:            hub,
+		authMiddleware: authMiddleware,
+	}
+}
+
+// SavePresence stores a presence change.
+// It implements websocket.PresenceStore.
+func (h *PresenceHandler) SavePresence(status websocket.PresenceStatus) error {
+	return h.presence.SavePresence(status.UserID, status.Online, status.LastSeen)
+}
+
+// ResetPresence marks every user offline.
+// Called on startup since no connections survive a restart.
+func (h *PresenceHandler) ResetPresence() error {
+	return h.presence.ResetPresence()
+}
+
+// GetPresence returns the online state and last-seen time of every user
+func (h *PresenceHandler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The hub is the source of truth for who is connected right now
+	online := make(map[int]bool)
+	for _, id := range h.hub.GetOnlineUserIDs() {
+		online[id] = true
+	}
+
+	presence, err := h.presence.ListPresence()
+	if err != nil {
+		log.Printf("Error fetching presence: %v", err)
+		http.Error(w, "Failed to fetch presence", http.StatusInternalServerError)
+		return
+	}
+
+	users := []database.UserPresence{}
+	for _, user := range presence {
+		// Don't include current user in the list
+		if user.ID == currentUser.ID {
+			continue
+		}
+
+		user.Online = online[user.ID]
+		users = append(users, user)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"users":   users,
+	})
+}
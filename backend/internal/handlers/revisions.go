@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/middleware"
+)
+
+// RevisionsHandler serves the edit history of posts and comments
+type RevisionsHandler struct {
+	posts          database.PostStore
+	comments       database.CommentStore
+	revisions      database.RevisionStore
+	authMiddleware *middleware.AuthMiddleware
+}
+
+// NewRevisionsHandler creates a new revisions handler
+func NewRevisionsHandler(store database.Store, authMiddleware *middleware.AuthMiddleware) *RevisionsHandler {
+	return &RevisionsHandler{
+		posts:          store,
+		comments:       store,
+		revisions:      store,
+		authMiddleware: authMiddleware,
+	}
+}
+
+// RevisionsResponse is the JSON body returned by GET /api/revisions
+type RevisionsResponse struct {
+	TargetType string              `json:"target_type"`
+	TargetID   int                 `json:"target_id"`
+	Revisions  []database.Revision `json:"revisions"`
+}
+
+// ListRevisions handles GET /api/revisions?type=post|comment&id=N.
+// Every version is listed oldest first with a line diff from the one before.
+// The history of a deleted post or comment still holds what was deleted,
+// so it is only shown to moderators.
+func (h *RevisionsHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targetType := r.URL.Query().Get("type")
+	targetID, err := optionalIDParam(r, "id")
+	if err != nil || targetID == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	var deleted bool
+	switch targetType {
+	case database.VoteTargetPost:
+		post, err := h.posts.GetPost(targetID)
+		if err != nil {
+			h.respondWithLookupError(w, err, "Post not found")
+			return
+		}
+		deleted = post.DeletedAt != nil
+	case database.VoteTargetComment:
+		comment, err := h.comments.GetComment(targetID)
+		if err != nil {
+			h.respondWithLookupError(w, err, "Comment not found")
+			return
+		}
+		deleted = comment.DeletedAt != nil
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Type must be post or comment")
+		return
+	}
+
+	if deleted {
+		currentUser := h.authMiddleware.GetCurrentUser(r)
+		if currentUser == nil || !currentUser.IsModerator() {
+			h.respondWithError(w, http.StatusForbidden, "Only moderators can view the history of deleted content")
+			return
+		}
+	}
+
+	revisions, err := h.revisions.ListRevisions(targetType, targetID)
+	if err != nil {
+		log.Printf("Error loading revisions of %s %d: %v", targetType, targetID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error loading revisions")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, RevisionsResponse{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Revisions:  revisions,
+	})
+}
+
+// respondWithLookupError reports a failed lookup of the post or comment whose history was requested
+func (h *RevisionsHandler) respondWithLookupError(w http.ResponseWriter, err error, notFoundMessage string) {
+	if err == database.ErrNotFound {
+		h.respondWithError(w, http.StatusNotFound, notFoundMessage)
+		return
+	}
+	log.Printf("Error loading revision target: %v", err)
+	h.respondWithError(w, http.StatusInternalServerError, "Error loading revisions")
+}
+
+// canModify reports whether user may edit or delete content written by authorID
+func canModify(user *database.User, authorID int) bool {
+	return user.ID == authorID || user.IsModerator()
+}
+
+func (h *RevisionsHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func (h *RevisionsHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
+)
+
+// RoomsHandler handles multi-member chat rooms
+type RoomsHandler struct {
+	rooms          database.RoomStore
+	users          database.UserStore
+	hub            *websocket.Hub
+	authMiddleware *middleware.AuthMiddleware
+}
+
+// Room limits
+const (
+	maxRoomNameLength = 100
+	maxRoomMembers    = 50
+)
+
+type CreateRoomRequest struct {
+	Name      string `json:"name"`
+	MemberIDs []int  `json:"member_ids"`
+}
+
+type RoomMemberRequest struct {
+	RoomID int `json:"room_id"`
+	UserID int `json:"user_id"`
+}
+
+type SendRoomMessageRequest struct {
+	RoomID  int    `json:"room_id"`
+	Content string `json:"content"`
+}
+
+// NewRoomsHandler creates a new rooms handler
+func NewRoomsHandler(store database.Store, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *RoomsHandler {
+	return &RoomsHandler{
+		rooms:          store,
+		users:          store,
+		hub:            hub,
+		authMiddleware: authMiddleware,
+	}
+}
+
+// RegisterCommands registers the WebSocket commands served by this handler
+func (h *RoomsHandler) RegisterCommands(router *websocket.Router) {
+	router.Handle(websocket.TypeSendRoomMessage, requireVerifiedEmail(h.authMiddleware, h.handleSendRoomMessageCommand))
+}
+
+// CreateRoom creates a room with the current user as owner and the given members
+func (h *RoomsHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request
+	var req CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > maxRoomNameLength {
+		http.Error(w, "Room name must be between 1 and 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	// Deduplicate members and drop the creator, who is added as owner
+	memberIDs := []int{}
+	seen := map[int]bool{currentUser.ID: true}
+	for _, id := range req.MemberIDs {
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		memberIDs = append(memberIDs, id)
+	}
+	if len(memberIDs)+1 > maxRoomMembers {
+		http.Error(w, "Too many members", http.StatusBadRequest)
+		return
+	}
+	for _, id := range memberIDs {
+		if !h.userExists(id) {
+			http.Error(w, "User "+strconv.Itoa(id)+" not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	newRoom := &database.Room{Name: req.Name, CreatedBy: currentUser.ID}
+	if err := h.rooms.CreateRoom(newRoom, memberIDs); err != nil {
+		log.Printf("Error creating room: %v", err)
+		http.Error(w, "Failed to create room", http.StatusInternalServerError)
+		return
+	}
+
+	room, err := h.rooms.GetRoom(newRoom.ID)
+	if err != nil {
+		log.Printf("Error loading room %d: %v", newRoom.ID, err)
+		http.Error(w, "Failed to create room", http.StatusInternalServerError)
+		return
+	}
+
+	h.notifyRoomUpdated(room)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"room":    room,
+	})
+}
+
+// ListRooms returns every room the current user is a member of, most recently active first
+func (h *RoomsHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rooms, err := h.rooms.ListUserRooms(currentUser.ID)
+	if err != nil {
+		log.Printf("Error fetching rooms: %v", err)
+		http.Error(w, "Failed to fetch rooms", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"rooms":   rooms,
+	})
+}
+
+// AddMember invites a user into a room. Any member may invite.
+func (h *RoomsHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request
+	var req RoomMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, isMember := h.memberRole(req.RoomID, currentUser.ID); !isMember {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	if !h.userExists(req.UserID) {
+		http.Error(w, "User not found", http.StatusBadRequest)
+		return
+	}
+
+	if _, isMember := h.memberRole(req.RoomID, req.UserID); isMember {
+		http.Error(w, "User is already a member", http.StatusConflict)
+		return
+	}
+
+	// The store checks the size cap in the same transaction as the insert
+	err := h.rooms.AddRoomMember(req.RoomID, req.UserID, database.RoomRoleMember, maxRoomMembers)
+	switch {
+	case err == database.ErrRoomFull:
+		http.Error(w, "Room is full", http.StatusBadRequest)
+		return
+	case err == database.ErrNotFound:
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error adding room member: %v", err)
+		http.Error(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondWithRoom(w, req.RoomID)
+}
+
+// RemoveMember removes a user from a room.
+// Owners may remove anyone; members may only remove themselves (leave).
+// A user leaving gets 204 No Content since they can no longer see the room.
+func (h *RoomsHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request
+	var req RoomMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	role, isMember := h.memberRole(req.RoomID, currentUser.ID)
+	if !isMember {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	if req.UserID != currentUser.ID && role != database.RoomRoleOwner {
+		http.Error(w, "Only the room owner can remove other members", http.StatusForbidden)
+		return
+	}
+
+	roomDeleted, err := h.rooms.RemoveRoomMember(req.RoomID, req.UserID)
+	if err == database.ErrNotFound {
+		http.Error(w, "User is not a member", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing room member: %v", err)
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	// Let the removed user's devices drop the room
+	if _, err := h.hub.SendToUser(req.UserID, websocket.NewEvent(websocket.TypeRoomRemoved, map[string]interface{}{
+		"room_id": req.RoomID,
+	})); err != nil {
+		log.Printf("Error sending WebSocket message: %v", err)
+	}
+
+	if req.UserID != currentUser.ID {
+		h.respondWithRoom(w, req.RoomID)
+		return
+	}
+
+	if !roomDeleted {
+		room, err := h.rooms.GetRoom(req.RoomID)
+		if err != nil {
+			log.Printf("Error loading room %d: %v", req.RoomID, err)
+		} else {
+			h.notifyRoomUpdated(room)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendRoomMessage posts a message to a room the current user belongs to
+func (h *RoomsHandler) SendRoomMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request
+	var req SendRoomMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate with the same rules as the send_room_message command
+	payload := websocket.SendRoomMessagePayload{RoomID: req.RoomID, Content: req.Content}
+	if err := payload.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, isMember := h.memberRole(req.RoomID, currentUser.ID); !isMember {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	message, delivered, err := h.sendRoomMessage(req.RoomID, currentUser.ID, currentUser.Username, req.Content)
+	if err != nil {
+		log.Printf("Error saving room message: %v", err)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   message,
+		"delivered": delivered,
+	})
+}
+
+// handleSendRoomMessageCommand handles a send_room_message command received over WebSocket
+func (h *RoomsHandler) handleSendRoomMessageCommand(c *websocket.Client, payload interface{}) (interface{}, error) {
+	p := payload.(*websocket.SendRoomMessagePayload)
+
+	if _, isMember := h.memberRole(p.RoomID, c.UserID); !isMember {
+		return nil, websocket.NewCommandError(websocket.ErrCodeNotFound, "Room not found")
+	}
+
+	sender, err := h.users.GetUserByID(c.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, delivered, err := h.sendRoomMessage(p.RoomID, c.UserID, sender.Username, p.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"message":   message,
+		"delivered": delivered,
+	}, nil
+}
+
+// GetRoomHistory returns one page of a room's messages, paged like GetMessageHistory.
+// Without a cursor it returns the newest page; before_id pages step back through
+// older messages and after_id pages step forward through newer ones. Every page
+// lists its messages oldest first.
+func (h *RoomsHandler) GetRoomHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get current user
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := optionalIDParam(r, "room_id")
+	if err != nil || roomID == 0 {
+		http.Error(w, "room_id parameter required", http.StatusBadRequest)
+		return
+	}
+
+	if _, isMember := h.memberRole(roomID, currentUser.ID); !isMember {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	// Get limit (default 50, capped at maxHistoryLimit)
+	limit := defaultHistoryLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	beforeID, err := optionalIDParam(r, "before_id")
+	if err != nil {
+		http.Error(w, "Invalid before_id", http.StatusBadRequest)
+		return
+	}
+	afterID, err := optionalIDParam(r, "after_id")
+	if err != nil {
+		http.Error(w, "Invalid after_id", http.StatusBadRequest)
+		return
+	}
+	if beforeID > 0 && afterID > 0 {
+		http.Error(w, "before_id and after_id cannot be combined", http.StatusBadRequest)
+		return
+	}
+
+	// One extra row is fetched to know whether another page exists
+	messages, err := h.rooms.ListRoomMessages(roomID, database.MessagePage{
+		BeforeID: beforeID,
+		AfterID:  afterID,
+		Limit:    limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error fetching room messages: %v", err)
+		http.Error(w, "Failed to fetch messages", http.StatusInternalServerError)
+		return
+	}
+
+	// As for private messages, the extra row is at the end furthest from the cursor
+	hasMore := len(messages) > limit
+	var nextCursor *int
+	if hasMore {
+		if afterID > 0 {
+			messages = messages[:limit]
+			nextCursor = &messages[len(messages)-1].ID
+		} else {
+			messages = messages[1:]
+			nextCursor = &messages[0].ID
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"messages":    messages,
+		"has_more":    hasMore,
+		"next_cursor": nextCursor,
+	})
+}
+
+// sendRoomMessage stores a room message and fans it out to every online member.
+// Returns the stored message and the number of connections it was delivered to.
+func (h *RoomsHandler) sendRoomMessage(roomID, senderID int, senderUsername, content string) (*database.RoomMessage, int, error) {
+	message := &database.RoomMessage{
+		RoomID:         roomID,
+		SenderID:       senderID,
+		SenderUsername: senderUsername,
+		Content:        content,
+		CreatedAt:      time.Now(),
+	}
+	if err := h.rooms.CreateRoomMessage(message); err != nil {
+		return nil, 0, err
+	}
+
+	memberIDs, err := h.rooms.ListMemberIDs(roomID)
+	if err != nil {
+		log.Printf("Error fetching room members: %v", err)
+		return message, 0, nil
+	}
+
+	delivered, err := h.hub.SendToUsers(memberIDs, websocket.NewEvent(websocket.TypeRoomMessage, map[string]interface{}{
+		"message": message,
+	}))
+	if err != nil {
+		log.Printf("Error sending WebSocket message: %v", err)
+	}
+
+	return message, delivered, nil
+}
+
+// memberRole returns the user's role in a room and whether they are a member
+func (h *RoomsHandler) memberRole(roomID, userID int) (string, bool) {
+	role, err := h.rooms.GetMemberRole(roomID, userID)
+	if err != nil {
+		return "", false
+	}
+	return role, true
+}
+
+// userExists checks if a user with the given ID exists
+func (h *RoomsHandler) userExists(userID int) bool {
+	_, err := h.users.GetUserByID(userID)
+	return err == nil
+}
+
+// notifyRoomUpdated pushes the current room state to every member
+func (h *RoomsHandler) notifyRoomUpdated(room *database.Room) {
+	memberIDs := make([]int, 0, len(room.Members))
+	for _, member := range room.Members {
+		memberIDs = append(memberIDs, member.UserID)
+	}
+
+	_, err := h.hub.SendToUsers(memberIDs, websocket.NewEvent(websocket.TypeRoomUpdated, map[string]interface{}{
+		"room": room,
+	}))
+	if err != nil {
+		log.Printf("Error sending WebSocket message: %v", err)
+	}
+}
+
+// respondWithRoom reloads a room after a membership change, notifies members and returns it
+func (h *RoomsHandler) respondWithRoom(w http.ResponseWriter, roomID int) {
+	room, err := h.rooms.GetRoom(roomID)
+	if err != nil {
+		log.Printf("Error loading room %d: %v", roomID, err)
+		http.Error(w, "Failed to load room", http.StatusInternalServerError)
+		return
+	}
+
+	h.notifyRoomUpdated(room)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"room":    room,
+	})
+}
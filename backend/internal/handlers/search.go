@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/database"
+)
+
+// Page sizes for search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// searchDateLayout is the format of the from and to filters
+const searchDateLayout = "2006-01-02"
+
+// SearchHandler serves full-text search over posts and comments
+type SearchHandler struct {
+	search database.SearchStore
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(search database.SearchStore) *SearchHandler {
+	return &SearchHandler{search: search}
+}
+
+// SearchResponse is the JSON body returned by GET /api/search
+type SearchResponse struct {
+	Success    bool                    `json:"success"`
+	Query      string                  `json:"query"`
+	Results    []database.SearchResult `json:"results"`
+	Pagination database.PaginationInfo `json:"pagination"`
+}
+
+// Search handles GET /api/search?q=...
+// Optional filters: type (post|comment), category (ID), author (username),
+// from and to (YYYY-MM-DD, inclusive), page (1-based) and limit.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	query := database.SearchQuery{
+		Query:  strings.TrimSpace(params.Get("q")),
+		Author: strings.TrimSpace(params.Get("author")),
+		Limit:  defaultSearchLimit,
+	}
+	if query.Query == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+
+	switch searchType := params.Get("type"); searchType {
+	case "", database.SearchTypePost, database.SearchTypeComment:
+		query.Type = searchType
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Invalid type")
+		return
+	}
+
+	categoryID, err := optionalIDParam(r, "category")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid category")
+		return
+	}
+	if categoryID > 0 {
+		query.CategoryID = &categoryID
+	}
+
+	if from := params.Get("from"); from != "" {
+		since, err := time.Parse(searchDateLayout, from)
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+			return
+		}
+		query.Since = &since
+	}
+	if to := params.Get("to"); to != "" {
+		until, err := time.Parse(searchDateLayout, to)
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+			return
+		}
+		// The to date is inclusive
+		until = until.AddDate(0, 0, 1)
+		query.Until = &until
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit <= 0 {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		if query.Limit > maxSearchLimit {
+			query.Limit = maxSearchLimit
+		}
+	}
+
+	page := 1
+	if value := params.Get("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page <= 0 {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid page")
+			return
+		}
+	}
+	query.Offset = (page - 1) * query.Limit
+
+	results, total, err := h.search.Search(query)
+	if err != nil {
+		log.Printf("Error searching for %q: %v", query.Query, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Search failed")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SearchResponse{
+		Success:    true,
+		Query:      query.Query,
+		Results:    results,
+		Pagination: database.NewPaginationInfo(total, query.Limit, query.Offset),
+	})
+}
+
+func (h *SearchHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func (h *SearchHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
+)
+
+// SessionsHandler lets users review the devices they are logged in on and revoke them.
+// Revocations go through the auth middleware so its session cache forgets them too.
+type SessionsHandler struct {
+	sessions       database.SessionStore
+	hub            *websocket.Hub
+	authMiddleware *middleware.AuthMiddleware
+}
+
+// NewSessionsHandler creates a new sessions handler
+func NewSessionsHandler(sessions database.SessionStore, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *SessionsHandler {
+	return &SessionsHandler{
+		sessions:       sessions,
+		hub:            hub,
+		authMiddleware: authMiddleware,
+	}
+}
+
+// SessionsResponse is the JSON body returned by GET /api/sessions
+type SessionsResponse struct {
+	Sessions []database.Session `json:"sessions"`
+}
+
+// RevokeResponse reports how many sessions a revoke request ended
+type RevokeResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// Sessions handles /api/sessions.
+// GET lists the current user's active sessions, most recently used first,
+// flagging the one making the request. DELETE ?id=N revokes one of them.
+func (h *SessionsHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	current := middleware.SessionFromContext(r.Context())
+	if current == nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.listSessions(w, current)
+	case http.MethodDelete:
+		h.revokeSession(w, r, current)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *SessionsHandler) listSessions(w http.ResponseWriter, current *database.Session) {
+	sessions, err := h.sessions.ListUserSessions(current.UserID, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error listing sessions of user %d: %v", current.UserID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error loading sessions")
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current.ID
+	}
+	h.respondWithJSON(w, http.StatusOK, SessionsResponse{Sessions: sessions})
+}
+
+// revokeSession ends one of the user's sessions. Revoking the current one logs the user out.
+func (h *SessionsHandler) revokeSession(w http.ResponseWriter, r *http.Request, current *database.Session) {
+	sessionID, err := optionalIDParam(r, "id")
+	if err != nil || sessionID == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.authMiddleware.RevokeSession(current.UserID, sessionID); err != nil {
+		if err == database.ErrNotFound {
+			h.respondWithError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		log.Printf("Error revoking session %d: %v", sessionID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error revoking session")
+		return
+	}
+
+	h.closeConnections(current.UserID, []int{sessionID})
+	h.respondWithJSON(w, http.StatusOK, RevokeResponse{Revoked: 1})
+}
+
+// RevokeOthers handles POST /api/sessions/revoke-others, logging the user out
+// everywhere except the device making the request
+func (h *SessionsHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current := middleware.SessionFromContext(r.Context())
+	if current == nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	revoked, err := h.authMiddleware.RevokeOtherSessions(current.UserID, current.ID)
+	if err != nil {
+		log.Printf("Error revoking other sessions of user %d: %v", current.UserID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error revoking sessions")
+		return
+	}
+
+	h.closeConnections(current.UserID, revoked)
+	h.respondWithJSON(w, http.StatusOK, RevokeResponse{Revoked: len(revoked)})
+}
+
+// closeConnections drops the WebSocket connections of revoked sessions right
+// away instead of leaving them open until the client reconnects
+func (h *SessionsHandler) closeConnections(userID int, sessionIDs []int) {
+	if closed := h.hub.CloseSessions(sessionIDs); closed > 0 {
+		log.Printf("🔒 Closed %d connection(s) of revoked sessions for user %d", closed, userID)
+	}
+}
+
+func (h *SessionsHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func (h *SessionsHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
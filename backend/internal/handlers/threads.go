@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/database"
+)
+
+// Comment thread page sizes
+const (
+	commentsPageSize = 50 // Top-level comments per page
+	repliesPageSize  = 10 // Replies shown under a comment before "load more"
+	maxThreadDepth   = 4  // Levels of comments loaded in one response
+)
+
+// RepliesHandler loads more comments for a thread via JSON.
+// GET /comments/replies?parent_id=N lists replies to comment N,
+// GET /comments/replies?post_id=N lists a post's top-level comments;
+// after takes the next_cursor returned with the previous page.
+func (h *PostsHandler) RepliesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := optionalIDParam(r, "post_id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+	parentID, err := optionalIDParam(r, "parent_id")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid parent ID")
+		return
+	}
+	// Cursors start at 0 for comments whose replies were cut off by the depth limit
+	afterID := 0
+	if after := r.URL.Query().Get("after"); after != "" {
+		afterID, err = strconv.Atoi(after)
+		if err != nil || afterID < 0 {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	var parent *int
+	switch {
+	case parentID > 0:
+		parentPostID, err := h.comments.GetCommentPostID(parentID)
+		if err == database.ErrNotFound || (err == nil && postID > 0 && parentPostID != postID) {
+			h.respondWithError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading comment %d: %v", parentID, err)
+			h.respondWithError(w, http.StatusInternalServerError, "Error loading comments")
+			return
+		}
+		postID = parentPostID
+		parent = &parentID
+	case postID > 0:
+		// Deleted posts keep their threads, so only a missing post is an error
+		if _, err := h.posts.GetPost(postID); err != nil {
+			h.respondWithError(w, http.StatusNotFound, "Post not found")
+			return
+		}
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Post ID or parent ID is required")
+		return
+	}
+
+	comments, nextCursor, err := h.getCommentThreads(postID, parent, afterID, h.authMiddleware.GetCurrentUser(r))
+	if err != nil {
+		log.Printf("Error loading comments for post %d: %v", postID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error loading comments")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"comments":    comments,
+		"next_cursor": nextCursor,
+	})
+}
+
+// getCommentThreads loads one page of replies to parentID (top-level comments
+// when nil) after the afterID cursor, each with up to maxThreadDepth levels of
+// replies and their vote counts. Returns the cursor for the next page, or nil
+// when there is none.
+func (h *PostsHandler) getCommentThreads(postID int, parentID *int, afterID int, currentUser *database.User) ([]database.Comment, *int, error) {
+	pageSize := commentsPageSize
+	var parentIDs []int
+	if parentID != nil {
+		pageSize = repliesPageSize
+		parentIDs = []int{*parentID}
+	}
+
+	// One query per level; each fetches an extra reply per parent to detect more
+	roots, err := h.comments.ListReplies(postID, parentIDs, afterID, pageSize+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	roots, nextCursor := commentPage(roots, pageSize)
+	if roots == nil {
+		roots = []database.Comment{}
+	}
+
+	loaded := roots
+	level := roots
+	for depth := 1; depth < maxThreadDepth; depth++ {
+		var ids []int
+		for _, comment := range level {
+			if comment.ReplyCount > 0 {
+				ids = append(ids, comment.ID)
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		level, err = h.comments.ListReplies(postID, ids, 0, repliesPageSize+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The extra replies only signal another page; their own replies aren't shown
+		loaded = append(loaded, level...)
+		level = pageReplies(level, repliesPageSize)
+	}
+
+	ids := make([]int, len(loaded))
+	for i, comment := range loaded {
+		ids[i] = comment.ID
+	}
+	statsByID := h.getVoteStats(database.VoteTargetComment, ids, currentUser)
+
+	replies := make(map[int][]database.Comment)
+	for i := range loaded {
+		if stats, ok := statsByID[loaded[i].ID]; ok {
+			loaded[i].LikeCount = stats.LikeCount
+			loaded[i].DislikeCount = stats.DislikeCount
+			loaded[i].NetScore = stats.NetScore
+			loaded[i].UserVote = stats.UserVote
+		}
+		if i >= len(roots) {
+			replies[*loaded[i].ParentID] = append(replies[*loaded[i].ParentID], loaded[i])
+		}
+	}
+
+	return attachReplies(loaded[:len(roots)], replies), nextCursor, nil
+}
+
+// attachReplies nests loaded replies under their parents. Comments whose
+// replies were not loaded, or only partly, get a NextCursor to fetch the rest.
+func attachReplies(comments []database.Comment, replies map[int][]database.Comment) []database.Comment {
+	for i := range comments {
+		comment := &comments[i]
+		loaded, ok := replies[comment.ID]
+		if !ok {
+			if comment.ReplyCount > 0 {
+				start := 0
+				comment.NextCursor = &start
+			}
+			continue
+		}
+
+		comment.Replies, comment.NextCursor = commentPage(loaded, repliesPageSize)
+		comment.Replies = attachReplies(comment.Replies, replies)
+	}
+	return comments
+}
+
+// pageReplies keeps the first size replies of each parent, dropping the extra
+// reply fetched per parent to detect another page
+func pageReplies(replies []database.Comment, size int) []database.Comment {
+	var page []database.Comment
+	counts := make(map[int]int)
+	for _, reply := range replies {
+		counts[*reply.ParentID]++
+		if counts[*reply.ParentID] <= size {
+			page = append(page, reply)
+		}
+	}
+	return page
+}
+
+// commentPage trims comments fetched with one extra row to size and returns
+// the cursor to continue from when the extra row was present
+func commentPage(comments []database.Comment, size int) ([]database.Comment, *int) {
+	if len(comments) <= size {
+		return comments, nil
+	}
+	cursor := comments[size-1].ID
+	return comments[:size], &cursor
+}
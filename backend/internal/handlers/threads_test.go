@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"real-time-forum/internal/database"
+)
+
+// repliesRecorder records the parents whose replies are loaded
+type repliesRecorder struct {
+	database.CommentStore
+	parents map[int]bool
+}
+
+func (r *repliesRecorder) ListReplies(postID int, parentIDs []int, afterID, limit int) ([]database.Comment, error) {
+	for _, id := range parentIDs {
+		r.parents[id] = true
+	}
+	return r.CommentStore.ListReplies(postID, parentIDs, afterID, limit)
+}
+
+func TestCommentThreadsSkipRepliesBeyondPage(t *testing.T) {
+	e := newTestEnv(t)
+	aliceID, alice := e.signUp(t, "alice")
+	postID := e.createPost(t, alice, "Threads", "A post with a long thread")
+
+	comment := func(parentID *int) int {
+		t.Helper()
+		c := &database.Comment{PostID: postID, UserID: aliceID, ParentID: parentID, Content: "reply"}
+		if err := e.store.CreateComment(c); err != nil {
+			t.Fatal(err)
+		}
+		return c.ID
+	}
+
+	// One more reply than a page holds, each with a reply of its own
+	root := comment(nil)
+	var replies []int
+	for i := 0; i < repliesPageSize+1; i++ {
+		reply := comment(&root)
+		comment(&reply)
+		replies = append(replies, reply)
+	}
+
+	recorder := &repliesRecorder{CommentStore: e.store, parents: make(map[int]bool)}
+	e.posts.comments = recorder
+
+	rec := e.do(t, e.posts.RepliesHandler, http.MethodGet, "/comments/replies?post_id="+strconv.Itoa(postID), nil, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	var resp struct {
+		Comments []database.Comment `json:"comments"`
+	}
+	decode(t, rec, &resp)
+	if len(resp.Comments) != 1 {
+		t.Fatalf("got %d top-level comments, want 1", len(resp.Comments))
+	}
+	thread := resp.Comments[0]
+	if len(thread.Replies) != repliesPageSize || thread.NextCursor == nil || *thread.NextCursor != replies[repliesPageSize-1] {
+		t.Errorf("got %d replies with cursor %v, want %d with cursor %d",
+			len(thread.Replies), thread.NextCursor, repliesPageSize, replies[repliesPageSize-1])
+	}
+	for _, reply := range thread.Replies {
+		if len(reply.Replies) != 1 {
+			t.Errorf("reply %d has %d replies loaded, want 1", reply.ID, len(reply.Replies))
+		}
+	}
+
+	if extra := replies[repliesPageSize]; recorder.parents[extra] {
+		t.Errorf("loaded replies of comment %d, which is past the first page", extra)
+	}
+}
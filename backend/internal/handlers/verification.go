@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
+)
+
+const (
+	// emailVerificationTTL is how long an emailed verification link works
+	emailVerificationTTL = 48 * time.Hour
+
+	// verificationResendInterval is the minimum time between resent verification emails
+	verificationResendInterval = time.Minute
+)
+
+// VerificationHandler confirms that users own the email address they registered with
+type VerificationHandler struct {
+	verifications  database.EmailVerificationStore
+	mailer         mail.Mailer
+	authMiddleware *middleware.AuthMiddleware
+	baseURL        string // Public address of the site, used in emailed links
+	throttle       *sendThrottle
+}
+
+// NewVerificationHandler creates a new email verification handler
+func NewVerificationHandler(verifications database.EmailVerificationStore, mailer mail.Mailer, authMiddleware *middleware.AuthMiddleware, baseURL string) *VerificationHandler {
+	return &VerificationHandler{
+		verifications:  verifications,
+		mailer:         mailer,
+		authMiddleware: authMiddleware,
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		throttle:       newSendThrottle(verificationResendInterval),
+	}
+}
+
+// VerifyEmailRequest is the JSON payload for POST /api/email/verify
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// SendVerification emails user a link that verifies their address, replacing any earlier link
+func (h *VerificationHandler) SendVerification(user *database.User) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	verification := &database.EmailVerification{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(emailVerificationTTL),
+	}
+	if err := h.verifications.CreateEmailVerification(verification); err != nil {
+		return err
+	}
+
+	link := h.baseURL + "/#/verify-email?token=" + url.QueryEscape(token)
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your forum email address",
+		Body: fmt.Sprintf("Hi %s,\n\nWelcome to the forum! Please confirm this is your email address "+
+			"by opening this link within the next two days:\n\n%s\n\n"+
+			"If you didn't create an account, you can ignore this email.\n",
+			user.Username, link),
+	})
+}
+
+// VerifyEmail handles POST /api/email/verify, redeeming an emailed token
+func (h *VerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Verification token is required")
+		return
+	}
+
+	userID, err := h.verifications.VerifyEmail(hashToken(req.Token), time.Now().UTC())
+	if err != nil {
+		if err == database.ErrNotFound {
+			h.respondWithError(w, http.StatusBadRequest, "This verification link is invalid or has expired")
+			return
+		}
+		log.Printf("Error verifying email: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error verifying email")
+		return
+	}
+
+	// Cached sessions still hold the unverified profile
+	h.authMiddleware.ForgetUser(userID)
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Email address verified",
+		"user_id": userID,
+	})
+}
+
+// ResendVerification handles POST /api/email/resend for the logged-in user,
+// at most once per verificationResendInterval
+func (h *VerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	currentUser := middleware.UserFromContext(r.Context())
+	if currentUser == nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if currentUser.IsEmailVerified() {
+		h.respondWithError(w, http.StatusBadRequest, "Your email address is already verified")
+		return
+	}
+	if !h.throttle.allow(currentUser.ID, time.Now()) {
+		h.respondWithError(w, http.StatusTooManyRequests, "Please wait a minute before requesting another email")
+		return
+	}
+
+	if err := h.SendVerification(currentUser); err != nil {
+		log.Printf("Error resending verification to user %d: %v", currentUser.ID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error sending verification email")
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+// requireVerifiedEmail wraps a WebSocket command so that, like
+// RequireVerifiedEmail on HTTP routes, users who haven't verified their email are refused
+func requireVerifiedEmail(authMiddleware *middleware.AuthMiddleware, next websocket.CommandHandler) websocket.CommandHandler {
+	return func(c *websocket.Client, payload interface{}) (interface{}, error) {
+		if !authMiddleware.UserHasVerifiedEmail(c.UserID) {
+			return nil, websocket.NewCommandError(websocket.ErrCodeForbidden, middleware.EmailNotVerifiedMessage)
+		}
+		return next(c, payload)
+	}
+}
+
+func (h *VerificationHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func (h *VerificationHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -1,22 +1,44 @@
 package handlers
 
 import (
-	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
+	"real-time-forum/internal/database"
 	"real-time-forum/internal/middleware"
+	"real-time-forum/internal/websocket"
 )
 
 type VotesHandler struct {
-	db             *sql.DB
+	votes          database.VoteStore
+	comments       database.CommentStore
+	hub            *websocket.Hub
 	authMiddleware *middleware.AuthMiddleware
 }
 
-func NewVotesHandler(db *sql.DB, authMiddleware *middleware.AuthMiddleware) *VotesHandler {
+// VoteUpdatedEvent is published to thread subscribers after a vote changes the counts
+type VoteUpdatedEvent struct {
+	TargetType string `json:"target_type"` // "post" or "comment"
+	TargetID   int    `json:"target_id"`
+	PostID     int    `json:"post_id"` // Thread the target belongs to
+	database.VoteStats
+}
+
+// VoteRequest represents the JSON payload for casting a vote
+type VoteRequest struct {
+	TargetType string `json:"target_type"` // "post" or "comment"
+	TargetID   int    `json:"target_id"`
+	Type       string `json:"type"` // "like" or "dislike"
+}
+
+func NewVotesHandler(store database.Store, hub *websocket.Hub, authMiddleware *middleware.AuthMiddleware) *VotesHandler {
 	return &VotesHandler{
-		db:             db,
+		votes:          store,
+		comments:       store,
+		hub:            hub,
 		authMiddleware: authMiddleware,
 	}
 }
@@ -66,7 +88,11 @@ func (h *VotesHandler) VoteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process vote
-	err = h.processVote(currentUser.ID, voteType, targetType, targetID)
+	stats, err := h.processVote(currentUser.ID, voteType, targetType, targetID)
+	if err == database.ErrVoteTargetNotFound {
+		http.Error(w, "Vote target not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		fmt.Printf("❌ Vote error: %v\n", err)
 		http.Error(w, "Error processing vote", http.StatusInternalServerError)
@@ -76,6 +102,8 @@ func (h *VotesHandler) VoteHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("✅ Vote processed: user=%d, %s %s:%d\n",
 		currentUser.ID, voteType, targetType, targetID)
 
+	h.publishVoteUpdated(targetType, targetID, stats)
+
 	// Redirect back to where user came from
 	if redirectURL == "" {
 		redirectURL = "/"
@@ -83,74 +111,108 @@ func (h *VotesHandler) VoteHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 }
 
-func (h *VotesHandler) processVote(userID int, voteType, targetType string, targetID int) error {
-	isLike := voteType == "like"
-
-	// Check if user has already voted
-	var existingVote sql.NullBool
-	var query string
-
-	if targetType == "post" {
-		query = "SELECT is_like FROM likes WHERE user_id = ? AND post_id = ?"
-	} else {
-		query = "SELECT is_like FROM likes WHERE user_id = ? AND comment_id = ?"
+// VoteAPIHandler casts a vote via JSON and returns the updated vote stats
+func (h *VotesHandler) VoteAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	err := h.db.QueryRow(query, userID, targetID).Scan(&existingVote)
+	currentUser := h.authMiddleware.GetCurrentUser(r)
+	if currentUser == nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("error checking existing vote: %w", err)
+	var req VoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
 	}
 
-	// If no existing vote, insert new vote
-	if err == sql.ErrNoRows {
-		return h.insertVote(userID, targetType, targetID, isLike)
+	if req.Type != "like" && req.Type != "dislike" {
+		h.respondWithError(w, http.StatusBadRequest, "Vote type must be like or dislike")
+		return
+	}
+	if req.TargetType != database.VoteTargetPost && req.TargetType != database.VoteTargetComment {
+		h.respondWithError(w, http.StatusBadRequest, "Target type must be post or comment")
+		return
+	}
+	if req.TargetID <= 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Target ID is required")
+		return
 	}
 
-	// If existing vote is the same, remove it (toggle off)
-	if existingVote.Valid && existingVote.Bool == isLike {
-		return h.deleteVote(userID, targetType, targetID)
+	stats, err := h.processVote(currentUser.ID, req.Type, req.TargetType, req.TargetID)
+	if err == database.ErrVoteTargetNotFound {
+		h.respondWithError(w, http.StatusNotFound, "Vote target not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error processing vote: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "Error processing vote")
+		return
 	}
 
-	// If existing vote is different, update it
-	return h.updateVote(userID, targetType, targetID, isLike)
-}
+	h.publishVoteUpdated(req.TargetType, req.TargetID, stats)
 
-func (h *VotesHandler) insertVote(userID int, targetType string, targetID int, isLike bool) error {
-	var query string
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"target_type": req.TargetType,
+		"target_id":   req.TargetID,
+		"stats":       stats,
+	})
+}
 
-	if targetType == "post" {
-		query = "INSERT INTO likes (user_id, post_id, is_like) VALUES (?, ?, ?)"
-	} else {
-		query = "INSERT INTO likes (user_id, comment_id, is_like) VALUES (?, ?, ?)"
+// processVote toggles a vote in the vote store
+func (h *VotesHandler) processVote(userID int, voteType, targetType string, targetID int) (*database.VoteStats, error) {
+	value := database.VoteLike
+	if voteType == "dislike" {
+		value = database.VoteDislike
 	}
 
-	_, err := h.db.Exec(query, userID, targetID, isLike)
-	return err
+	return h.votes.ToggleVote(userID, targetType, targetID, value)
 }
 
-func (h *VotesHandler) updateVote(userID int, targetType string, targetID int, isLike bool) error {
-	var query string
-
-	if targetType == "post" {
-		query = "UPDATE likes SET is_like = ? WHERE user_id = ? AND post_id = ?"
-	} else {
-		query = "UPDATE likes SET is_like = ? WHERE user_id = ? AND comment_id = ?"
+// publishVoteUpdated pushes the new aggregate counts of a post or comment to
+// subscribers of its thread. Post scores are also shown in the feed, so post
+// votes go to feed subscribers as well.
+func (h *VotesHandler) publishVoteUpdated(targetType string, targetID int, stats *database.VoteStats) {
+	event := VoteUpdatedEvent{
+		TargetType: targetType,
+		TargetID:   targetID,
+		PostID:     targetID,
+		VoteStats:  *stats,
 	}
+	// The voter's own choice is not part of the shared counts
+	event.UserVote = nil
 
-	_, err := h.db.Exec(query, isLike, userID, targetID)
-	return err
-}
+	if targetType == database.VoteTargetComment {
+		postID, err := h.comments.GetCommentPostID(targetID)
+		if err != nil {
+			log.Printf("Error loading comment %d for vote update: %v", targetID, err)
+			return
+		}
+		event.PostID = postID
+	}
 
-func (h *VotesHandler) deleteVote(userID int, targetType string, targetID int) error {
-	var query string
+	topics := []string{websocket.PostTopic(event.PostID)}
+	if targetType == database.VoteTargetPost {
+		topics = append(topics, websocket.TopicFeed)
+	}
 
-	if targetType == "post" {
-		query = "DELETE FROM likes WHERE user_id = ? AND post_id = ?"
-	} else {
-		query = "DELETE FROM likes WHERE user_id = ? AND comment_id = ?"
+	if _, err := h.hub.Publish(topics, websocket.NewEvent(websocket.TypeVoteUpdated, event)); err != nil {
+		log.Printf("Error publishing vote update: %v", err)
 	}
+}
+
+func (h *VotesHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	h.respondWithJSON(w, code, map[string]string{"error": message})
+}
 
-	_, err := h.db.Exec(query, userID, targetID)
-	return err
+func (h *VotesHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
 }
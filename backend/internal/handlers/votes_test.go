@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"real-time-forum/internal/database"
+)
+
+// vote casts a vote through VoteAPIHandler and returns the response status and stats
+func (e *testEnv) vote(t *testing.T, session *http.Cookie, targetType string, targetID int, voteType string) (int, database.VoteStats) {
+	t.Helper()
+
+	rec := e.do(t, e.auth.RequireAuth(e.votes.VoteAPIHandler), http.MethodPost, "/api/vote",
+		VoteRequest{TargetType: targetType, TargetID: targetID, Type: voteType}, session)
+
+	var resp struct {
+		Stats database.VoteStats `json:"stats"`
+	}
+	if rec.Code == http.StatusOK {
+		decode(t, rec, &resp)
+	}
+	return rec.Code, resp.Stats
+}
+
+func TestVoteToggles(t *testing.T) {
+	e := newTestEnv(t)
+	_, alice := e.signUp(t, "alice")
+	_, bob := e.signUp(t, "bob")
+	postID := e.createPost(t, alice, "Vote on me", "Some post content")
+
+	steps := []struct {
+		session  *http.Cookie
+		voteType string
+		likes    int
+		dislikes int
+		userVote *bool
+	}{
+		{bob, "like", 1, 0, boolPtr(true)},
+		{bob, "like", 0, 0, nil},                 // same vote again withdraws it
+		{bob, "dislike", 0, 1, boolPtr(false)},   // a fresh dislike
+		{bob, "like", 1, 0, boolPtr(true)},       // switching replaces it
+		{alice, "dislike", 1, 1, boolPtr(false)}, // each user has their own vote
+	}
+	for i, step := range steps {
+		code, stats := e.vote(t, step.session, database.VoteTargetPost, postID, step.voteType)
+		if code != http.StatusOK {
+			t.Fatalf("step %d: status = %d, want 200", i, code)
+		}
+		if stats.LikeCount != step.likes || stats.DislikeCount != step.dislikes {
+			t.Errorf("step %d: counts = %d/%d, want %d/%d", i, stats.LikeCount, stats.DislikeCount, step.likes, step.dislikes)
+		}
+		if (stats.UserVote == nil) != (step.userVote == nil) || (stats.UserVote != nil && *stats.UserVote != *step.userVote) {
+			t.Errorf("step %d: user vote = %v, want %v", i, stats.UserVote, step.userVote)
+		}
+	}
+
+	post := e.listPosts(t, bob, "").Posts[0]
+	if post.LikeCount != 1 || post.DislikeCount != 1 {
+		t.Errorf("listed counts = %d/%d, want 1/1", post.LikeCount, post.DislikeCount)
+	}
+}
+
+func TestVoteValidation(t *testing.T) {
+	e := newTestEnv(t)
+	_, alice := e.signUp(t, "alice")
+	postID := e.createPost(t, alice, "Vote on me", "Some post content")
+
+	tests := []struct {
+		name       string
+		session    *http.Cookie
+		targetType string
+		targetID   int
+		voteType   string
+		want       int
+	}{
+		{"anonymous", nil, database.VoteTargetPost, postID, "like", http.StatusUnauthorized},
+		{"unknown vote type", alice, database.VoteTargetPost, postID, "love", http.StatusBadRequest},
+		{"unknown target type", alice, "user", postID, "like", http.StatusBadRequest},
+		{"missing post", alice, database.VoteTargetPost, postID + 100, "like", http.StatusNotFound},
+		{"missing comment", alice, database.VoteTargetComment, 1, "like", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := e.vote(t, tt.session, tt.targetType, tt.targetID, tt.voteType); code != tt.want {
+				t.Errorf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
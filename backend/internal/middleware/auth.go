@@ -1,10 +1,9 @@
 package middleware
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"real-time-forum/internal/database"
@@ -12,23 +11,63 @@ import (
 
 // AuthMiddleware provides authentication middleware for protecting routes
 type AuthMiddleware struct {
-	db *sql.DB
+	users    database.UserStore
+	sessions database.SessionStore
+	cache    *sessionCache
+
+	// requireVerifiedEmail makes RequireVerifiedEmail reject users who haven't confirmed their address
+	requireVerifiedEmail bool
 }
 
 // NewAuthMiddleware creates a new authentication middleware instance
-func NewAuthMiddleware(db *sql.DB) *AuthMiddleware {
+func NewAuthMiddleware(users database.UserStore, sessions database.SessionStore) *AuthMiddleware {
 	return &AuthMiddleware{
-		db: db,
+		users:    users,
+		sessions: sessions,
+		cache:    newSessionCache(),
+	}
+}
+
+// contextKey is the type of the keys this package stores in a request context
+type contextKey int
+
+// sessionContextKey holds the *database.Session resolved for a request, nil for guests
+const sessionContextKey contextKey = iota
+
+// SessionFromContext returns the session RequireAuth or AddUserToContext
+// resolved for the request, or nil if the request is not authenticated
+func SessionFromContext(ctx context.Context) *database.Session {
+	session, _ := ctx.Value(sessionContextKey).(*database.Session)
+	return session
+}
+
+// UserFromContext returns the user RequireAuth or AddUserToContext resolved
+// for the request, or nil if the request is not authenticated
+func UserFromContext(ctx context.Context) *database.User {
+	if session := SessionFromContext(ctx); session != nil {
+		return session.User
+	}
+	return nil
+}
+
+// withSession resolves the request's session once and stores it, even when
+// there is none, so later lookups on the same request don't repeat the work
+func (m *AuthMiddleware) withSession(r *http.Request) (*http.Request, *database.Session) {
+	if _, resolved := r.Context().Value(sessionContextKey).(*database.Session); resolved {
+		return r, SessionFromContext(r.Context())
 	}
+	session := m.GetCurrentSession(r)
+	return r.WithContext(context.WithValue(r.Context(), sessionContextKey, session)), session
 }
 
 // RequireAuth is a middleware that requires user authentication
-// It checks if the user has a valid session and redirects to login if not
+// It checks if the user has a valid session and rejects the request if not;
+// otherwise the user is available to the handler through UserFromContext
 func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if user is authenticated
-		user := m.GetCurrentUser(r)
-		if user == nil {
+		r, session := m.withSession(r)
+		if session == nil {
 			// User is not authenticated, return 401
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
@@ -41,6 +80,48 @@ func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// EmailNotVerifiedMessage is the error returned to users held back by RequireVerifiedEmail
+const EmailNotVerifiedMessage = "Please verify your email address first"
+
+// SetRequireVerifiedEmail turns gating on email verification on or off.
+// Call it before the server starts handling requests.
+func (m *AuthMiddleware) SetRequireVerifiedEmail(required bool) {
+	m.requireVerifiedEmail = required
+}
+
+// RequireVerifiedEmail is RequireAuth for routes that post or send messages:
+// while verification is required, users who haven't confirmed their email get a 403
+func (m *AuthMiddleware) RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return m.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !m.HasVerifiedEmail(UserFromContext(r.Context())) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "` + EmailNotVerifiedMessage + `"}`))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// HasVerifiedEmail reports whether user may use the features gated on email
+// verification. Always true when verification is not required.
+func (m *AuthMiddleware) HasVerifiedEmail(user *database.User) bool {
+	return !m.requireVerifiedEmail || user.IsEmailVerified()
+}
+
+// UserHasVerifiedEmail is HasVerifiedEmail for callers that only know the user ID,
+// such as WebSocket commands. The user is looked up only when verification is required.
+func (m *AuthMiddleware) UserHasVerifiedEmail(userID int) bool {
+	if !m.requireVerifiedEmail {
+		return true
+	}
+	user, err := m.users.GetUserByID(userID)
+	if err != nil {
+		return false
+	}
+	return user.IsEmailVerified()
+}
+
 // RequireGuest is a middleware that requires user to NOT be authenticated
 // It redirects authenticated users away from login/register pages
 func (m *AuthMiddleware) RequireGuest(next http.HandlerFunc) http.HandlerFunc {
@@ -58,87 +139,188 @@ func (m *AuthMiddleware) RequireGuest(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// sessionTouchInterval is how stale a session's last_used_at may get before a
+// request refreshes it, so every request doesn't turn into a write
+const sessionTouchInterval = time.Minute
+
+// sessionRenewInterval is how much idle time a session must have used up
+// before a request pushes its expiry forward again
+const sessionRenewInterval = 5 * time.Minute
+
+// SessionPolicy is how long a session lives. It expires after IdleTimeout
+// without requests, and after AbsoluteTimeout no matter how active it is.
+type SessionPolicy struct {
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+}
+
+var (
+	// DefaultSessionPolicy applies to ordinary logins
+	DefaultSessionPolicy = SessionPolicy{IdleTimeout: 24 * time.Hour, AbsoluteTimeout: 7 * 24 * time.Hour}
+
+	// RememberMeSessionPolicy applies to logins that asked to be remembered
+	RememberMeSessionPolicy = SessionPolicy{IdleTimeout: 30 * 24 * time.Hour, AbsoluteTimeout: 90 * 24 * time.Hour}
+)
+
+// SessionPolicyFor returns the policy for a session with the given remember-me choice
+func SessionPolicyFor(rememberMe bool) SessionPolicy {
+	if rememberMe {
+		return RememberMeSessionPolicy
+	}
+	return DefaultSessionPolicy
+}
+
+// Expiry returns the idle deadline for a session used at now, capped by its absolute deadline
+func (p SessionPolicy) Expiry(now, absolute time.Time) time.Time {
+	expiresAt := now.Add(p.IdleTimeout)
+	if expiresAt.After(absolute) {
+		return absolute
+	}
+	return expiresAt
+}
+
 // GetCurrentUser extracts the current user from the request session
 // Returns nil if user is not authenticated or session is invalid
 func (m *AuthMiddleware) GetCurrentUser(r *http.Request) *database.User {
+	session := m.GetCurrentSession(r)
+	if session == nil {
+		return nil
+	}
+	return session.User
+}
+
+// GetCurrentSession returns the unexpired session the request is authenticated
+// with, with its User populated, or nil if there is none.
+// Requests that went through RequireAuth or AddUserToContext are answered from
+// their context; others are served from the session cache when possible.
+func (m *AuthMiddleware) GetCurrentSession(r *http.Request) *database.Session {
+	if session, resolved := r.Context().Value(sessionContextKey).(*database.Session); resolved {
+		return session
+	}
+
 	// Get session cookie
 	cookie, err := r.Cookie("session_token")
 	if err != nil {
 		return nil // No session cookie found
 	}
 
-	// Look up session in database
-	var userID int
-	var expiresAt time.Time
-	err = m.db.QueryRow(`
-		SELECT user_id, expires_at FROM sessions 
-		WHERE token = ?
-	`, cookie.Value).Scan(&userID, &expiresAt)
-
-	f, _ := os.OpenFile("debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	defer f.Close()
-
-	if err != nil {
-		fmt.Fprintf(f, "Lookup failed. Token: %s, Error: %v\n", cookie.Value, err)
-		return nil // Session not found
+	now := time.Now().UTC()
+	session := m.cache.get(cookie.Value, now)
+	if session == nil {
+		generation := m.cache.currentGeneration()
+		session, err = m.loadSession(cookie.Value)
+		if err != nil {
+			return nil // Session or user not found
+		}
+		m.cache.put(session, generation, now)
 	}
 
-	if time.Now().UTC().After(expiresAt) {
-		fmt.Fprintf(f, "Expired. Token: %s, Expires: %v, Now: %v\n", cookie.Value, expiresAt, time.Now().UTC())
+	if now.After(session.ExpiresAt) {
+		m.cache.forget(func(cached *database.Session) bool { return cached.Token == session.Token })
 		return nil // Session expired
 	}
-	fmt.Fprintf(f, "Success. Token: %s, UserID: %d\n", cookie.Value, userID)
 
-	// Get user details
-	var user database.User
-	err = m.db.QueryRow(`
-		SELECT id, username, email, created_at, updated_at
-		FROM users WHERE id = ?
-	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	renewed := m.renewSession(session, now)
+	if now.Sub(session.LastUsedAt) >= sessionTouchInterval {
+		if err := m.sessions.TouchSession(session.Token, now); err == nil {
+			session.LastUsedAt = now
+			renewed = true
+		}
+	}
+	if renewed {
+		m.cache.update(session)
+	}
+
+	return session
+}
 
+// loadSession reads a session and its user from the stores
+func (m *AuthMiddleware) loadSession(token string) (*database.Session, error) {
+	session, err := m.sessions.GetSession(token)
 	if err != nil {
-		fmt.Fprintf(f, "User lookup failed for ID %d: %v\n", userID, err)
-		return nil // User not found
+		return nil, err
 	}
-	fmt.Fprintf(f, "User found: %s\n", user.Username)
 
-	return &user
+	user, err := m.users.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	session.User = user
+	return session, nil
+}
+
+// renewSession slides an active session's idle deadline forward. Renewal is
+// skipped until sessionRenewInterval of the idle timeout has been used, so a
+// busy session writes at most once per interval. Returns true if it renewed.
+func (m *AuthMiddleware) renewSession(session *database.Session, now time.Time) bool {
+	policy := SessionPolicyFor(session.RememberMe)
+	expiresAt := policy.Expiry(now, session.AbsoluteExpiresAt)
+	if expiresAt.Sub(session.ExpiresAt) < sessionRenewInterval {
+		return false
+	}
+
+	if err := m.sessions.ExtendSession(session.Token, expiresAt); err != nil {
+		log.Printf("⚠️ Error renewing session %d: %v", session.ID, err)
+		return false
+	}
+	session.ExpiresAt = expiresAt
+	return true
 }
 
 // CleanupExpiredSessions removes expired sessions from the database
 // This should be called periodically to keep the sessions table clean
 func (m *AuthMiddleware) CleanupExpiredSessions() error {
-	_, err := m.db.Exec(`
-		DELETE FROM sessions 
-		WHERE expires_at <= ?
-	`, time.Now())
+	now := time.Now().UTC()
+	m.cache.sweep(now)
+	return m.sessions.DeleteExpiredSessions(now)
+}
 
+// ForgetUser drops a user's cached sessions so the next request reloads their
+// profile, e.g. after they verified their email address
+func (m *AuthMiddleware) ForgetUser(userID int) {
+	m.cache.forget(func(cached *database.Session) bool { return cached.UserID == userID })
+}
+
+// EndSession deletes the session with the given token, as on logout
+func (m *AuthMiddleware) EndSession(token string) error {
+	err := m.sessions.DeleteSession(token)
+	m.cache.forget(func(cached *database.Session) bool { return cached.Token == token })
+	return err
+}
+
+// RevokeSession deletes one of a user's sessions.
+// Returns database.ErrNotFound unless the session belongs to the user.
+func (m *AuthMiddleware) RevokeSession(userID, sessionID int) error {
+	err := m.sessions.DeleteUserSession(userID, sessionID)
+	m.cache.forget(func(cached *database.Session) bool { return cached.ID == sessionID })
 	return err
 }
 
+// RevokeOtherSessions deletes every session of a user except keepID and
+// returns the IDs of the sessions it removed
+func (m *AuthMiddleware) RevokeOtherSessions(userID, keepID int) ([]int, error) {
+	revoked, err := m.sessions.DeleteOtherSessions(userID, keepID)
+	m.cache.forget(func(cached *database.Session) bool { return cached.UserID == userID && cached.ID != keepID })
+	return revoked, err
+}
+
 // RevokeUserSessions revokes all sessions for a specific user
 // Useful for logout from all devices functionality
 func (m *AuthMiddleware) RevokeUserSessions(userID int) error {
-	_, err := m.db.Exec(`
-		DELETE FROM sessions 
-		WHERE user_id = ?
-	`, userID)
-
+	err := m.sessions.DeleteUserSessions(userID)
+	m.cache.forget(func(cached *database.Session) bool { return cached.UserID == userID })
 	return err
 }
 
-// ExtendSession extends the expiration time of a session
-// Can be used to implement "remember me" functionality
+// ExtendSession extends the expiration time of a session by duration from now,
+// never past the session's absolute deadline
 func (m *AuthMiddleware) ExtendSession(token string, duration time.Duration) error {
-	newExpiresAt := time.Now().Add(duration)
-
-	_, err := m.db.Exec(`
-		UPDATE sessions 
-		SET expires_at = ? 
-		WHERE token = ?
-	`, newExpiresAt, token)
-
-	return err
+	session, err := m.sessions.GetSession(token)
+	if err != nil {
+		return err
+	}
+	expiresAt := SessionPolicy{IdleTimeout: duration}.Expiry(time.Now().UTC(), session.AbsoluteExpiresAt)
+	return m.sessions.ExtendSession(token, expiresAt)
 }
 
 // SessionStats provides statistics about active sessions
@@ -154,17 +336,9 @@ type SessionStats struct {
 func (m *AuthMiddleware) GetSessionStats() (*SessionStats, error) {
 	stats := &SessionStats{}
 
-	// Get total sessions
-	err := m.db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&stats.TotalSessions)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get active sessions (not expired)
-	err = m.db.QueryRow(`
-		SELECT COUNT(*) FROM sessions 
-		WHERE expires_at > ?
-	`, time.Now()).Scan(&stats.ActiveSessions)
+	// Active sessions are the ones not expired yet
+	var err error
+	stats.TotalSessions, stats.ActiveSessions, stats.UniqueUsers, err = m.sessions.CountSessions(time.Now().UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -172,29 +346,14 @@ func (m *AuthMiddleware) GetSessionStats() (*SessionStats, error) {
 	// Calculate expired sessions
 	stats.ExpiredSessions = stats.TotalSessions - stats.ActiveSessions
 
-	// Get unique users with active sessions
-	err = m.db.QueryRow(`
-		SELECT COUNT(DISTINCT user_id) FROM sessions 
-		WHERE expires_at > ?
-	`, time.Now()).Scan(&stats.UniqueUsers)
-	if err != nil {
-		return nil, err
-	}
-
 	return stats, nil
 }
 
-// AddUserToContext is a middleware that adds the current user to the request context
-// This allows handlers to access user information without database queries
+// AddUserToContext is a middleware that adds the current user, if any, to the request context
+// Use it on routes open to guests; handlers read the user with UserFromContext
 func (m *AuthMiddleware) AddUserToContext(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Get current user
-		_ = m.GetCurrentUser(r)
-
-		// Add user to request context (if needed in the future)
-		// For now, handlers can use GetCurrentUser directly
-
-		// Continue to next handler
+		r, _ = m.withSession(r)
 		next(w, r)
 	}
 }
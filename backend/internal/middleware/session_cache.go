@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"real-time-forum/internal/database"
+)
+
+// sessionCacheTTL is how long a resolved session is trusted before it is
+// looked up again. Revoking or ending a session through AuthMiddleware drops
+// it from the cache right away; the TTL bounds everything else, such as a role
+// change or a session deleted by another server process.
+const sessionCacheTTL = 30 * time.Second
+
+// sessionCache keeps recently resolved sessions, with their users, keyed by token.
+//
+// A request that misses the cache loads the session from the store and then
+// caches it. If the session is revoked in between, the revocation's forget
+// runs before that put, so the revoked session would be cached again. To stop
+// that, every forget bumps a generation; a load notes the generation before it
+// reads the store, and put drops the session if the generation has moved since.
+type sessionCache struct {
+	mu         sync.Mutex
+	entries    map[string]cachedSession
+	generation uint64
+	lastSweep  time.Time
+}
+
+// cachedSession is a session copy and when it was loaded from the store
+type cachedSession struct {
+	session  database.Session
+	loadedAt time.Time
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{entries: make(map[string]cachedSession)}
+}
+
+// copySession copies a session and its user, so callers never share the
+// cached user with each other
+func copySession(session *database.Session) database.Session {
+	copied := *session
+	if session.User != nil {
+		user := *session.User
+		copied.User = &user
+	}
+	return copied
+}
+
+// get returns a copy of the cached session for token, or nil if it is missing or stale
+func (c *sessionCache) get(token string, now time.Time) *database.Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[token]
+	if !ok {
+		return nil
+	}
+	if now.Sub(entry.loadedAt) >= sessionCacheTTL {
+		delete(c.entries, token)
+		return nil
+	}
+	session := copySession(&entry.session)
+	return &session
+}
+
+// currentGeneration returns the generation to pass to put for a session about
+// to be loaded from the store
+func (c *sessionCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// put caches a session loaded from the store, unless a forget has run since
+// the load started at generation. Stale entries are swept at most once per TTL.
+func (c *sessionCache) put(session *database.Session, generation uint64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) >= sessionCacheTTL {
+		c.sweepLocked(now)
+	}
+	if generation != c.generation {
+		return
+	}
+	c.entries[session.Token] = cachedSession{session: copySession(session), loadedAt: now}
+}
+
+// update replaces a cached session after it was renewed or touched, keeping its
+// load time so the entry still goes stale on schedule
+func (c *sessionCache) update(session *database.Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[session.Token]; ok {
+		entry.session = copySession(session)
+		c.entries[session.Token] = entry
+	}
+}
+
+// forget drops the sessions matching match and stops loads already under way
+// from caching anything
+func (c *sessionCache) forget(match func(session *database.Session) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for token, entry := range c.entries {
+		if match(&entry.session) {
+			delete(c.entries, token)
+		}
+	}
+}
+
+// sweep drops stale entries so tokens that are never seen again don't pile up
+func (c *sessionCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweepLocked(now)
+}
+
+func (c *sessionCache) sweepLocked(now time.Time) {
+	for token, entry := range c.entries {
+		if now.Sub(entry.loadedAt) >= sessionCacheTTL {
+			delete(c.entries, token)
+		}
+	}
+	c.lastSweep = now
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"real-time-forum/internal/database"
+)
+
+func testSession(id int, token string) *database.Session {
+	return &database.Session{ID: id, UserID: 7, Token: token, User: &database.User{ID: 7, Username: "alice"}}
+}
+
+func TestSessionCacheIgnoresPutAfterForget(t *testing.T) {
+	c := newSessionCache()
+	now := time.Now()
+
+	// A request misses the cache and starts loading the session...
+	generation := c.currentGeneration()
+	loaded := testSession(1, "token")
+
+	// ...while the session is revoked
+	c.forget(func(cached *database.Session) bool { return cached.ID == 1 })
+
+	c.put(loaded, generation, now)
+	if got := c.get("token", now); got != nil {
+		t.Fatalf("revoked session was cached: %+v", got)
+	}
+
+	// Loads that start after the revocation are cached as usual
+	c.put(testSession(2, "other"), c.currentGeneration(), now)
+	if c.get("other", now) == nil {
+		t.Fatal("session loaded after the revocation was not cached")
+	}
+}
+
+func TestSessionCacheCopiesUser(t *testing.T) {
+	c := newSessionCache()
+	now := time.Now()
+
+	loaded := testSession(1, "token")
+	c.put(loaded, c.currentGeneration(), now)
+	loaded.User.Username = "changed by the loader"
+
+	first := c.get("token", now)
+	first.User.Username = "changed by a request"
+	if second := c.get("token", now); second.User.Username != "alice" {
+		t.Errorf("cached username = %q, want alice", second.User.Username)
+	}
+}
+
+func TestSessionCacheSweepsOnPut(t *testing.T) {
+	c := newSessionCache()
+	start := time.Now()
+
+	c.put(testSession(1, "old"), c.currentGeneration(), start)
+	c.put(testSession(2, "new"), c.currentGeneration(), start.Add(sessionCacheTTL))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries["old"]; ok {
+		t.Error("stale entry survived a put one TTL later")
+	}
+	if _, ok := c.entries["new"]; !ok {
+		t.Error("new entry missing")
+	}
+}
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"encoding/json"
 	"log"
 	"time"
 
@@ -18,7 +19,7 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 
 	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	maxMessageSize = 8192
 )
 
 // Client represents a single websocket connection
@@ -27,6 +28,22 @@ type Client struct {
 	conn   *websocket.Conn
 	send   chan []byte
 	UserID int
+
+	// Login session the connection was opened with, so revoking it can close the connection
+	SessionID int
+
+	// Subscribed topics, only touched from the hub's Run loop
+	topics map[string]bool
+}
+
+// reply sends an envelope to this client only
+func (c *Client) reply(env *Envelope) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("❌ Error encoding reply for user %d: %v", c.UserID, err)
+		return
+	}
+	c.hub.direct <- clientMessage{client: c, data: data}
 }
 
 // readPump pumps messages from the websocket connection to the hub
@@ -51,7 +68,7 @@ func (c *Client) readPump() {
 			}
 			break
 		}
-		c.hub.broadcast <- message
+		c.hub.Router.dispatch(c, message)
 	}
 }
 
@@ -79,13 +96,7 @@ func (c *Client) writePump() {
 			}
 			w.Write(message)
 
-			// Add queued messages to the current websocket message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
+			// Each envelope goes out in its own frame so clients can JSON.parse it
 			if err := w.Close(); err != nil {
 				return
 			}
@@ -16,11 +16,12 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// HandleWebSocket upgrades HTTP connection to WebSocket and manages the client
-func HandleWebSocket(hub *Hub, getUserID func(*http.Request) (int, error)) http.HandlerFunc {
+// HandleWebSocket upgrades HTTP connection to WebSocket and manages the client.
+// getSession returns the user and login session the request is authenticated with.
+func HandleWebSocket(hub *Hub, getSession func(*http.Request) (userID, sessionID int, err error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get user ID from session
-		userID, err := getUserID(r)
+		userID, sessionID, err := getSession(r)
 		if err != nil {
 			log.Println("❌ Unauthorized WebSocket connection attempt")
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -36,10 +37,12 @@ func HandleWebSocket(hub *Hub, getUserID func(*http.Request) (int, error)) http.
 
 		// Create new client
 		client := &Client{
-			hub:    hub,
-			conn:   conn,
-			send:   make(chan []byte, 256),
-			UserID: userID,
+			hub:       hub,
+			conn:      conn,
+			send:      make(chan []byte, 256),
+			UserID:    userID,
+			SessionID: sessionID,
+			topics:    make(map[string]bool),
 		}
 
 		// Register client with hub
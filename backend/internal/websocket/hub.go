@@ -3,94 +3,372 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"time"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to clients
+// Hub maintains the set of active clients and broadcasts messages to clients.
+// All client bookkeeping is owned by the Run loop; other goroutines talk to it via channels.
 type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from clients
+	// Registered clients indexed by user, one entry per open device/tab
+	users map[int]map[*Client]bool
+
+	// Outbound messages for all clients
 	broadcast chan []byte
 
+	// Outbound messages for a single client (command replies)
+	direct chan clientMessage
+
+	// Outbound messages for every connection of one or more users
+	userMessages chan userMessage
+
+	// Outbound messages for clients subscribed to any of a set of topics
+	topicMessages chan topicMessage
+
+	// Requests to close every connection opened with one of a set of login sessions
+	sessionCloses chan sessionClose
+
+	// Requests for the list of online user IDs
+	onlineRequests chan chan []int
+
+	// Topic subscription changes from clients
+	subscriptions chan subscriptionChange
+
+	// Active typing indicators and their expiry times
+	typing        map[typingKey]time.Time
+	typingChanges chan typingChange
+	typingGuard   TypingGuard
+
 	// Register requests from clients
 	register chan *Client
 
 	// Unregister requests from clients
 	unregister chan *Client
+
+	// Router dispatches client commands to their handlers
+	Router *Router
+
+	// Presence changes waiting to be persisted
+	presenceUpdates chan PresenceStatus
+	presenceStore   PresenceStore
+}
+
+// clientMessage is a frame addressed to a single client
+type clientMessage struct {
+	client *Client
+	data   []byte
+}
+
+// userMessage is a frame addressed to all connections of a set of users.
+// The number of connections it was queued on is sent back on delivered.
+type userMessage struct {
+	userIDs   []int
+	data      []byte
+	delivered chan int
+}
+
+// topicMessage is a frame addressed to every client subscribed to one of topics.
+// The number of connections it was queued on is sent back on delivered.
+type topicMessage struct {
+	topics    []string
+	data      []byte
+	delivered chan int
+}
+
+// sessionClose asks the hub to send data to, then close, every connection
+// opened with one of sessionIDs. The number of connections closed is sent back on closed.
+type sessionClose struct {
+	sessionIDs []int
+	data       []byte
+	closed     chan int
+}
+
+// subscriptionChange adds or removes topics for a client
+type subscriptionChange struct {
+	client    *Client
+	topics    []string
+	subscribe bool
 }
 
 // NewHub creates a new Hub instance
 func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+	h := &Hub{
+		clients:        make(map[*Client]bool),
+		users:          make(map[int]map[*Client]bool),
+		broadcast:      make(chan []byte),
+		direct:         make(chan clientMessage),
+		userMessages:   make(chan userMessage),
+		topicMessages:  make(chan topicMessage),
+		sessionCloses:  make(chan sessionClose),
+		onlineRequests: make(chan chan []int),
+		subscriptions:  make(chan subscriptionChange),
+		typing:         make(map[typingKey]time.Time),
+		typingChanges:  make(chan typingChange),
+		register:       make(chan *Client),
+		unregister:     make(chan *Client),
+		Router:         NewRouter(),
+
+		presenceUpdates: make(chan PresenceStatus, 256),
 	}
+
+	h.Router.Handle(TypeSubscribe, h.handleSubscribe)
+	h.Router.Handle(TypeUnsubscribe, h.handleUnsubscribe)
+	h.Router.Handle(TypeTypingStart, h.handleTypingStart)
+	h.Router.Handle(TypeTypingStop, h.handleTypingStop)
+
+	return h
 }
 
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	if h.presenceStore != nil {
+		go h.persistPresence()
+	}
+
+	typingSweep := time.NewTicker(typingSweepPeriod)
+	defer typingSweep.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
-			h.clients[client] = true
-			log.Printf("✅ Client registered. Total clients: %d", len(h.clients))
+			h.addClient(client)
+			log.Printf("✅ Client registered for user %d. Total clients: %d", client.UserID, len(h.clients))
 
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				log.Printf("❌ Client unregistered. Total clients: %d", len(h.clients))
+				h.removeClient(client)
+				log.Printf("❌ Client unregistered for user %d. Total clients: %d", client.UserID, len(h.clients))
 			}
 
 		case message := <-h.broadcast:
 			// Broadcast message to all connected clients
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				h.deliver(client, message)
+			}
+
+		case message := <-h.direct:
+			if h.clients[message.client] {
+				h.deliver(message.client, message.data)
+			}
+
+		case message := <-h.userMessages:
+			delivered := 0
+			for _, userID := range message.userIDs {
+				delivered += h.deliverToUser(userID, message.data)
+			}
+			message.delivered <- delivered
+
+		case message := <-h.topicMessages:
+			message.delivered <- h.deliverToTopics(message.topics, message.data)
+
+		case request := <-h.sessionCloses:
+			request.closed <- h.closeSessions(request.sessionIDs, request.data)
+
+		case reply := <-h.onlineRequests:
+			userIDs := make([]int, 0, len(h.users))
+			for userID := range h.users {
+				userIDs = append(userIDs, userID)
+			}
+			reply <- userIDs
+
+		case change := <-h.subscriptions:
+			if !h.clients[change.client] {
+				continue
+			}
+			for _, topic := range change.topics {
+				if change.subscribe {
+					change.client.topics[topic] = true
+				} else {
+					delete(change.client.topics, topic)
 				}
 			}
+
+		case change := <-h.typingChanges:
+			h.applyTyping(change)
+
+		case now := <-typingSweep.C:
+			h.expireTyping(now)
 		}
 	}
 }
 
-// SendToUser sends a message to a specific user
-func (h *Hub) SendToUser(userID int, message interface{}) error {
-	data, err := json.Marshal(message)
-	if err != nil {
-		return err
+// addClient indexes a new connection. Must only be called from the Run loop.
+func (h *Hub) addClient(client *Client) {
+	h.clients[client] = true
+
+	devices, ok := h.users[client.UserID]
+	if !ok {
+		devices = make(map[*Client]bool)
+		h.users[client.UserID] = devices
 	}
+	devices[client] = true
 
+	// First connection for this user
+	if !ok {
+		h.setPresence(client.UserID, true)
+	}
+}
+
+// removeClient drops a connection and closes its send channel.
+// Must only be called from the Run loop.
+func (h *Hub) removeClient(client *Client) {
+	delete(h.clients, client)
+	close(client.send)
+
+	if devices, ok := h.users[client.UserID]; ok {
+		delete(devices, client)
+		if len(devices) == 0 {
+			delete(h.users, client.UserID)
+			h.setPresence(client.UserID, false)
+		}
+	}
+}
+
+// deliver queues data on the client's send buffer, dropping the client if it is full.
+// Returns true if the data was queued. Must only be called from the Run loop.
+func (h *Hub) deliver(client *Client, data []byte) bool {
+	if h.enqueue(client, data) {
+		return true
+	}
+	h.removeClient(client)
+	return false
+}
+
+// enqueue queues data on the client's send buffer without dropping the client
+// when it is full. Callers that are iterating clients use it to drop the slow
+// ones after the loop. Must only be called from the Run loop.
+func (h *Hub) enqueue(client *Client, data []byte) bool {
+	select {
+	case client.send <- data:
+		return true
+	default:
+		log.Printf("⚠️ Send buffer full for user %d, dropping connection", client.UserID)
+		return false
+	}
+}
+
+// deliverToUser queues data on every connection of a user and returns how many
+// connections received it. Must only be called from the Run loop.
+func (h *Hub) deliverToUser(userID int, data []byte) int {
+	delivered := 0
+	for client := range h.users[userID] {
+		if h.deliver(client, data) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// deliverToTopics queues data once on every client subscribed to at least one of topics.
+// Must only be called from the Run loop.
+func (h *Hub) deliverToTopics(topics []string, data []byte) int {
+	delivered := 0
 	for client := range h.clients {
-		if client.UserID == userID {
-			select {
-			case client.send <- data:
-			default:
-				close(client.send)
-				delete(h.clients, client)
+		for _, topic := range topics {
+			if client.topics[topic] {
+				if h.deliver(client, data) {
+					delivered++
+				}
+				break
 			}
-			break
 		}
 	}
-	return nil
+	return delivered
 }
 
-// GetOnlineUserIDs returns a list of all online user IDs
-func (h *Hub) GetOnlineUserIDs() []int {
-	userIDs := make([]int, 0, len(h.clients))
-	seen := make(map[int]bool)
+// closeSessions sends data to, then drops, every client opened with one of sessionIDs.
+// Returns how many clients were dropped. Must only be called from the Run loop.
+func (h *Hub) closeSessions(sessionIDs []int, data []byte) int {
+	revoked := make(map[int]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		revoked[id] = true
+	}
 
+	closed := 0
 	for client := range h.clients {
-		if !seen[client.UserID] {
-			userIDs = append(userIDs, client.UserID)
-			seen[client.UserID] = true
+		if !revoked[client.SessionID] {
+			continue
+		}
+		// writePump drains the notice before it sees the closed channel;
+		// a full buffer already drops the client inside deliver
+		if h.deliver(client, data) {
+			h.removeClient(client)
 		}
+		closed++
+	}
+	return closed
+}
+
+// handleSubscribe adds the requested topics to the client's subscriptions
+func (h *Hub) handleSubscribe(c *Client, payload interface{}) (interface{}, error) {
+	p := payload.(*SubscribePayload)
+	h.subscriptions <- subscriptionChange{client: c, topics: p.Topics, subscribe: true}
+	return map[string]interface{}{"topics": p.Topics}, nil
+}
+
+// handleUnsubscribe removes the requested topics from the client's subscriptions
+func (h *Hub) handleUnsubscribe(c *Client, payload interface{}) (interface{}, error) {
+	p := payload.(*SubscribePayload)
+	h.subscriptions <- subscriptionChange{client: c, topics: p.Topics, subscribe: false}
+	return map[string]interface{}{"topics": p.Topics}, nil
+}
+
+// SendToUser sends a message to every open connection of a user.
+// Returns the number of connections the message was delivered to (0 if offline).
+func (h *Hub) SendToUser(userID int, message interface{}) (int, error) {
+	return h.SendToUsers([]int{userID}, message)
+}
+
+// SendToUsers sends a message to every open connection of each of the given users.
+// Returns the total number of connections the message was delivered to.
+func (h *Hub) SendToUsers(userIDs []int, message interface{}) (int, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := make(chan int, 1)
+	h.userMessages <- userMessage{userIDs: userIDs, data: data, delivered: delivered}
+	return <-delivered, nil
+}
+
+// Publish sends a message to every client subscribed to any of the given topics.
+// A client subscribed to several of them receives the message once.
+// Returns the number of connections the message was delivered to.
+func (h *Hub) Publish(topics []string, message interface{}) (int, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return 0, err
 	}
 
-	return userIDs
+	delivered := make(chan int, 1)
+	h.topicMessages <- topicMessage{topics: topics, data: data, delivered: delivered}
+	return <-delivered, nil
+}
+
+// CloseSessions disconnects every connection opened with one of the given login
+// sessions, telling each one why first. Used when sessions are revoked.
+// Returns the number of connections closed.
+func (h *Hub) CloseSessions(sessionIDs []int) int {
+	if len(sessionIDs) == 0 {
+		return 0
+	}
+
+	data, err := json.Marshal(NewEvent(TypeSessionRevoked, nil))
+	if err != nil {
+		return 0
+	}
+
+	closed := make(chan int, 1)
+	h.sessionCloses <- sessionClose{sessionIDs: sessionIDs, data: data, closed: closed}
+	return <-closed
+}
+
+// GetOnlineUserIDs returns a list of all online user IDs
+func (h *Hub) GetOnlineUserIDs() []int {
+	reply := make(chan []int, 1)
+	h.onlineRequests <- reply
+	return <-reply
 }
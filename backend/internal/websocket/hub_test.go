@@ -0,0 +1,311 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// drain returns the frames currently queued for c, decoded, without waiting
+func drain(t *testing.T, c *Client) []Envelope {
+	t.Helper()
+
+	var frames []Envelope
+	for {
+		select {
+		case data, ok := <-c.send:
+			if !ok {
+				return frames
+			}
+			var env Envelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				t.Fatalf("decoding frame %s: %v", data, err)
+			}
+			frames = append(frames, env)
+		default:
+			return frames
+		}
+	}
+}
+
+// statuses returns the presence events among frames
+func statuses(t *testing.T, frames []Envelope) []PresenceStatus {
+	t.Helper()
+
+	var out []PresenceStatus
+	for _, env := range frames {
+		if env.Type != TypeUserStatus {
+			continue
+		}
+		var status PresenceStatus
+		if err := json.Unmarshal(env.Payload, &status); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// closed reports whether the hub has closed c's send channel
+func closed(c *Client) bool {
+	for {
+		select {
+		case _, ok := <-c.send:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+type recordingPresenceStore struct {
+	saved chan PresenceStatus
+}
+
+func (s *recordingPresenceStore) SavePresence(status PresenceStatus) error {
+	s.saved <- status
+	return nil
+}
+
+func TestHubSendToUserReachesEveryDevice(t *testing.T) {
+	h := startHub()
+	phone := newTestClient(h, 1, 16)
+	laptop := newTestClient(h, 1, 16)
+	other := newTestClient(h, 2, 16)
+	for _, c := range []*Client{phone, laptop, other} {
+		h.register <- c
+	}
+
+	delivered, err := h.SendToUser(1, NewEvent(TypeNewMessage, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 2 {
+		t.Errorf("delivered to %d connections, want 2", delivered)
+	}
+	for _, c := range []*Client{phone, laptop} {
+		if env := next(t, c); env.Type != TypeNewMessage {
+			t.Errorf("device got %q, want %q", env.Type, TypeNewMessage)
+		}
+	}
+	for _, env := range drain(t, other) {
+		if env.Type == TypeNewMessage {
+			t.Error("message for user 1 reached user 2")
+		}
+	}
+
+	if delivered, _ := h.SendToUsers([]int{1, 2, 3}, NewEvent(TypeNewMessage, nil)); delivered != 3 {
+		t.Errorf("delivered to %d connections of users 1, 2 and offline 3, want 3", delivered)
+	}
+}
+
+func TestHubPublishDedupesTopics(t *testing.T) {
+	h := startHub()
+	both := newTestClient(h, 1, 16)
+	feedOnly := newTestClient(h, 2, 16)
+	neither := newTestClient(h, 3, 16)
+	for _, c := range []*Client{both, feedOnly, neither} {
+		h.register <- c
+	}
+
+	subscribe := func(c *Client, topics ...string) {
+		if _, err := h.handleSubscribe(c, &SubscribePayload{Topics: topics}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscribe(both, TopicFeed, PostTopic(5))
+	subscribe(feedOnly, TopicFeed)
+	subscribe(neither, PostTopic(6))
+
+	delivered, err := h.Publish([]string{TopicFeed, PostTopic(5)}, NewEvent(TypePostCreated, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 2 {
+		t.Errorf("delivered to %d connections, want 2", delivered)
+	}
+
+	count := func(c *Client) int {
+		n := 0
+		for _, env := range drain(t, c) {
+			if env.Type == TypePostCreated {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count(both); n != 1 {
+		t.Errorf("client subscribed to both topics got the event %d times, want once", n)
+	}
+	if n := count(feedOnly); n != 1 {
+		t.Errorf("feed subscriber got the event %d times, want once", n)
+	}
+	if n := count(neither); n != 0 {
+		t.Errorf("unrelated subscriber got the event %d times, want none", n)
+	}
+
+	if _, err := h.handleUnsubscribe(both, &SubscribePayload{Topics: []string{TopicFeed, PostTopic(5)}}); err != nil {
+		t.Fatal(err)
+	}
+	if delivered, _ := h.Publish([]string{PostTopic(5)}, NewEvent(TypePostCreated, nil)); delivered != 0 {
+		t.Errorf("delivered to %d connections after unsubscribing, want 0", delivered)
+	}
+}
+
+func TestHubPresenceFollowsFirstAndLastConnection(t *testing.T) {
+	h := NewHub()
+	store := &recordingPresenceStore{saved: make(chan PresenceStatus, 8)}
+	h.SetPresenceStore(store)
+	go h.Run()
+
+	watcher := newTestClient(h, 9, 16)
+	h.register <- watcher
+	<-store.saved
+	drain(t, watcher)
+
+	phone := newTestClient(h, 1, 16)
+	laptop := newTestClient(h, 1, 16)
+	h.register <- phone
+	h.register <- laptop
+	h.unregister <- phone
+	h.unregister <- laptop
+
+	if online := h.GetOnlineUserIDs(); len(online) != 1 || online[0] != 9 {
+		t.Errorf("online users = %v, want only 9", online)
+	}
+
+	got := statuses(t, drain(t, watcher))
+	if len(got) != 2 || got[0].UserID != 1 || !got[0].Online || got[1].UserID != 1 || got[1].Online {
+		t.Fatalf("presence events = %+v, want user 1 online once, then offline once", got)
+	}
+	for _, want := range got {
+		select {
+		case saved := <-store.saved:
+			if saved.UserID != want.UserID || saved.Online != want.Online {
+				t.Errorf("saved %+v, want %+v", saved, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("presence change %+v was not persisted", want)
+		}
+	}
+}
+
+func TestHubDropsSlowClientsAfterPresenceBroadcast(t *testing.T) {
+	h := NewHub()
+	// Unbuffered send channels are always full, as nothing is reading them
+	stuckA := newTestClient(h, 1, 0)
+	stuckB := newTestClient(h, 2, 0)
+	h.clients[stuckA] = true
+	h.users[1] = map[*Client]bool{stuckA: true}
+	h.clients[stuckB] = true
+	h.users[2] = map[*Client]bool{stuckB: true}
+
+	watcher := newTestClient(h, 3, 16)
+	h.addClient(watcher)
+
+	if !closed(stuckA) || !closed(stuckB) {
+		t.Fatal("slow clients were not dropped")
+	}
+	if len(h.clients) != 1 || len(h.users) != 1 {
+		t.Errorf("hub still tracks %d clients and %d users, want only the watcher", len(h.clients), len(h.users))
+	}
+
+	offline := map[int]bool{}
+	for _, status := range statuses(t, drain(t, watcher)) {
+		if !status.Online {
+			offline[status.UserID] = true
+		}
+	}
+	if !offline[1] || !offline[2] {
+		t.Errorf("watcher saw users %v go offline, want 1 and 2", offline)
+	}
+}
+
+func TestHubTypingIndicators(t *testing.T) {
+	h := NewHub()
+	sender := newTestClient(h, 1, 16)
+	receiver := newTestClient(h, 2, 16)
+	h.addClient(sender)
+	h.addClient(receiver)
+	drain(t, sender)
+	drain(t, receiver)
+
+	key := typingKey{senderID: 1, receiverID: 2}
+	typingEvents := func() []string {
+		var types []string
+		for _, env := range drain(t, receiver) {
+			types = append(types, env.Type)
+		}
+		for _, env := range drain(t, sender) {
+			t.Errorf("sender got its own %q", env.Type)
+		}
+		return types
+	}
+	expect := func(step string, want ...string) {
+		t.Helper()
+		got := typingEvents()
+		if len(got) != len(want) {
+			t.Fatalf("%s: receiver got %v, want %v", step, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: receiver got %v, want %v", step, got, want)
+			}
+		}
+	}
+
+	h.applyTyping(typingChange{key: key, typing: true})
+	expect("start", TypeTypingStart)
+
+	h.applyTyping(typingChange{key: key, typing: true})
+	expect("refresh")
+
+	h.expireTyping(time.Now())
+	expect("sweep before expiry")
+
+	h.expireTyping(time.Now().Add(typingTimeout + time.Second))
+	expect("sweep after expiry", TypeTypingStop)
+
+	h.applyTyping(typingChange{key: key, typing: false})
+	expect("stop when already stopped")
+
+	h.applyTyping(typingChange{key: key, typing: true})
+	h.applyTyping(typingChange{key: key, typing: false})
+	expect("explicit stop", TypeTypingStart, TypeTypingStop)
+
+	h.applyTyping(typingChange{key: key, typing: true})
+	drain(t, receiver)
+	h.removeClient(sender)
+	h.expireTyping(time.Now())
+	got := typingEvents()
+	if len(got) != 2 || got[1] != TypeTypingStop {
+		t.Errorf("after the sender disconnected the receiver got %v, want offline status then %q", got, TypeTypingStop)
+	}
+}
+
+func TestHubCloseSessions(t *testing.T) {
+	h := startHub()
+	revoked := newTestClient(h, 1, 16)
+	kept := newTestClient(h, 1, 16)
+	kept.SessionID = 99
+	h.register <- revoked
+	h.register <- kept
+
+	if n := h.CloseSessions([]int{revoked.SessionID}); n != 1 {
+		t.Fatalf("closed %d connections, want 1", n)
+	}
+	if env := next(t, revoked); env.Type != TypeSessionRevoked {
+		t.Errorf("revoked connection got %q, want %q", env.Type, TypeSessionRevoked)
+	}
+	if !closed(revoked) {
+		t.Error("revoked connection was not closed")
+	}
+	if closed(kept) {
+		t.Error("connection of another session was closed")
+	}
+	if online := h.GetOnlineUserIDs(); len(online) != 1 {
+		t.Errorf("online users = %v, want user 1 still online", online)
+	}
+}
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// TypeUserStatus is the server event sent when a user comes online or goes offline
+const TypeUserStatus = "user_status"
+
+// PresenceStatus describes a user's online state
+type PresenceStatus struct {
+	UserID   int       `json:"user_id"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// PresenceStore persists presence changes emitted by the hub
+type PresenceStore interface {
+	SavePresence(status PresenceStatus) error
+}
+
+// SetPresenceStore sets where presence changes are persisted.
+// Must be called before Run.
+func (h *Hub) SetPresenceStore(store PresenceStore) {
+	h.presenceStore = store
+}
+
+// setPresence broadcasts a user's new presence to all clients and queues it for persistence.
+// Must only be called from the Run loop.
+func (h *Hub) setPresence(userID int, online bool) {
+	status := PresenceStatus{
+		UserID:   userID,
+		Online:   online,
+		LastSeen: time.Now().UTC(),
+	}
+
+	if data, err := json.Marshal(NewEvent(TypeUserStatus, status)); err == nil {
+		// Dropping a client can take its user offline, which calls back in here,
+		// so slow clients are only removed once this broadcast is done
+		var slow []*Client
+		for client := range h.clients {
+			if !h.enqueue(client, data) {
+				slow = append(slow, client)
+			}
+		}
+		for _, client := range slow {
+			// An earlier removal may already have dropped it
+			if h.clients[client] {
+				h.removeClient(client)
+			}
+		}
+	}
+
+	if h.presenceStore == nil {
+		return
+	}
+	select {
+	case h.presenceUpdates <- status:
+	default:
+		log.Printf("⚠️ Presence queue full, dropping update for user %d", userID)
+	}
+}
+
+// persistPresence writes queued presence changes in order
+func (h *Hub) persistPresence() {
+	for status := range h.presenceUpdates {
+		if err := h.presenceStore.SavePresence(status); err != nil {
+			log.Printf("⚠️ Error saving presence for user %d: %v", status.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProtocolVersion is the current version of the WebSocket message envelope.
+// Clients may omit the version; anything else must match exactly.
+const ProtocolVersion = 1
+
+// Client-to-server command types
+const (
+	TypeSendMessage     = "send_message"
+	TypeSendRoomMessage = "send_room_message"
+	TypeTypingStart     = "typing_start"
+	TypeTypingStop      = "typing_stop"
+	TypeMarkRead        = "mark_read"
+	TypeSubscribe       = "subscribe"
+	TypeUnsubscribe     = "unsubscribe"
+	TypePing            = "ping"
+)
+
+// Server-to-client message types
+const (
+	TypeAck         = "ack"
+	TypeError       = "error"
+	TypePong        = "pong"
+	TypeNewMessage  = "new_message"
+	TypeMessageRead = "message_read"
+	TypeRoomMessage = "room_message"
+	TypeRoomUpdated = "room_updated"
+	TypeRoomRemoved = "room_removed"
+
+	TypePostCreated    = "post_created"
+	TypePostUpdated    = "post_updated" // Edited or soft-deleted
+	TypeCommentCreated = "comment_created"
+	TypeCommentUpdated = "comment_updated" // Edited or soft-deleted
+	TypeVoteUpdated    = "vote_updated"
+
+	// Sent to a connection right before the server closes it
+	TypeSessionRevoked = "session_revoked"
+)
+
+// Error codes returned in error replies
+const (
+	ErrCodeBadEnvelope    = "bad_envelope"
+	ErrCodeBadVersion     = "unsupported_version"
+	ErrCodeUnknownType    = "unknown_type"
+	ErrCodeInvalidPayload = "invalid_payload"
+	ErrCodeNotFound       = "not_found"
+	ErrCodeForbidden      = "forbidden"
+	ErrCodeInternal       = "internal_error"
+)
+
+const (
+	// Maximum length of a chat message sent over the socket
+	maxMessageContentChars = 2000
+
+	// Maximum number of topics in a single subscribe/unsubscribe command
+	maxTopicsPerRequest = 20
+)
+
+// Envelope is the JSON frame exchanged in both directions over the socket.
+// ID is chosen by the client and echoed back in the matching ack/error reply.
+type Envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ErrorPayload is the payload of an error reply
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CommandError is returned by command handlers to produce a structured error reply
+type CommandError struct {
+	Code    string
+	Message string
+}
+
+// Error implements the error interface for CommandError
+func (e *CommandError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// NewCommandError creates a CommandError with the given code and message
+func NewCommandError(code, message string) *CommandError {
+	return &CommandError{Code: code, Message: message}
+}
+
+// NewEvent builds a server-originated envelope with the given type and payload
+func NewEvent(eventType string, payload interface{}) *Envelope {
+	env := &Envelope{Version: ProtocolVersion, Type: eventType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err == nil {
+			env.Payload = data
+		}
+	}
+	return env
+}
+
+// Validator is implemented by command payloads that can check their own fields
+type Validator interface {
+	Validate() error
+}
+
+// SendMessagePayload is the payload of a send_message command
+type SendMessagePayload struct {
+	ReceiverID int    `json:"receiver_id"`
+	Content    string `json:"content"`
+}
+
+// Validate checks the send_message payload
+func (p *SendMessagePayload) Validate() error {
+	if p.ReceiverID <= 0 {
+		return fmt.Errorf("receiver_id is required")
+	}
+	content := strings.TrimSpace(p.Content)
+	if content == "" {
+		return fmt.Errorf("content cannot be empty")
+	}
+	if utf8.RuneCountInString(p.Content) > maxMessageContentChars {
+		return fmt.Errorf("content must be %d characters or less", maxMessageContentChars)
+	}
+	return nil
+}
+
+// SendRoomMessagePayload is the payload of a send_room_message command
+type SendRoomMessagePayload struct {
+	RoomID  int    `json:"room_id"`
+	Content string `json:"content"`
+}
+
+// Validate checks the send_room_message payload
+func (p *SendRoomMessagePayload) Validate() error {
+	if p.RoomID <= 0 {
+		return fmt.Errorf("room_id is required")
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return fmt.Errorf("content cannot be empty")
+	}
+	if utf8.RuneCountInString(p.Content) > maxMessageContentChars {
+		return fmt.Errorf("content must be %d characters or less", maxMessageContentChars)
+	}
+	return nil
+}
+
+// TypingPayload is the payload of typing_start and typing_stop commands
+type TypingPayload struct {
+	ReceiverID int `json:"receiver_id"`
+}
+
+// Validate checks the typing payload
+func (p *TypingPayload) Validate() error {
+	if p.ReceiverID <= 0 {
+		return fmt.Errorf("receiver_id is required")
+	}
+	return nil
+}
+
+// MarkReadPayload is the payload of a mark_read command.
+// All messages from SenderID up to and including MessageID are marked as read.
+type MarkReadPayload struct {
+	SenderID  int `json:"sender_id"`
+	MessageID int `json:"message_id"`
+}
+
+// Validate checks the mark_read payload
+func (p *MarkReadPayload) Validate() error {
+	if p.SenderID <= 0 {
+		return fmt.Errorf("sender_id is required")
+	}
+	if p.MessageID <= 0 {
+		return fmt.Errorf("message_id is required")
+	}
+	return nil
+}
+
+// SubscribePayload is the payload of subscribe and unsubscribe commands
+type SubscribePayload struct {
+	Topics []string `json:"topics"`
+}
+
+// Validate checks the subscribe payload
+func (p *SubscribePayload) Validate() error {
+	if len(p.Topics) == 0 {
+		return fmt.Errorf("at least one topic is required")
+	}
+	if len(p.Topics) > maxTopicsPerRequest {
+		return fmt.Errorf("at most %d topics per request", maxTopicsPerRequest)
+	}
+	for _, topic := range p.Topics {
+		if !ValidTopic(topic) {
+			return fmt.Errorf("invalid topic %q", topic)
+		}
+	}
+	return nil
+}
+
+// Subscription topics
+const (
+	TopicFeed           = "feed"
+	topicCategoryPrefix = "category:"
+	topicPostPrefix     = "post:"
+)
+
+// CategoryTopic returns the topic name for a category feed
+func CategoryTopic(categoryID int) string {
+	return topicCategoryPrefix + strconv.Itoa(categoryID)
+}
+
+// PostTopic returns the topic name for a single post thread
+func PostTopic(postID int) string {
+	return topicPostPrefix + strconv.Itoa(postID)
+}
+
+// ValidTopic reports whether topic is one of the supported subscription topics
+func ValidTopic(topic string) bool {
+	if topic == TopicFeed {
+		return true
+	}
+	for _, prefix := range []string{topicCategoryPrefix, topicPostPrefix} {
+		if strings.HasPrefix(topic, prefix) {
+			id, err := strconv.Atoi(strings.TrimPrefix(topic, prefix))
+			return err == nil && id > 0
+		}
+	}
+	return false
+}
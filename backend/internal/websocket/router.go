@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+)
+
+// CommandHandler handles a validated client command.
+// payload is the decoded payload struct registered for the command type
+// (e.g. *SendMessagePayload). A non-nil result becomes the payload of the ack reply.
+type CommandHandler func(c *Client, payload interface{}) (interface{}, error)
+
+// commandPayloads maps each client command type to a constructor for its payload
+var commandPayloads = map[string]func() Validator{
+	TypeSendMessage:     func() Validator { return &SendMessagePayload{} },
+	TypeSendRoomMessage: func() Validator { return &SendRoomMessagePayload{} },
+	TypeTypingStart:     func() Validator { return &TypingPayload{} },
+	TypeTypingStop:      func() Validator { return &TypingPayload{} },
+	TypeMarkRead:        func() Validator { return &MarkReadPayload{} },
+	TypeSubscribe:       func() Validator { return &SubscribePayload{} },
+	TypeUnsubscribe:     func() Validator { return &SubscribePayload{} },
+}
+
+// Router dispatches inbound client envelopes to registered command handlers
+type Router struct {
+	mu       sync.RWMutex
+	handlers map[string]CommandHandler
+}
+
+// NewRouter creates an empty command router
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[string]CommandHandler),
+	}
+}
+
+// Handle registers the handler for a command type, replacing any existing one
+func (r *Router) Handle(commandType string, handler CommandHandler) {
+	if _, ok := commandPayloads[commandType]; !ok {
+		log.Printf("⚠️ Registering handler for unknown command type %q", commandType)
+		return
+	}
+
+	r.mu.Lock()
+	r.handlers[commandType] = handler
+	r.mu.Unlock()
+}
+
+func (r *Router) handler(commandType string) CommandHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handlers[commandType]
+}
+
+// dispatch decodes a raw frame from the client, runs the matching handler
+// and replies with an ack or a structured error
+func (r *Router) dispatch(c *Client, raw []byte) {
+	var env Envelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.Type == "" {
+		c.reply(replyError("", ErrCodeBadEnvelope, "Frame must be a JSON envelope with a type"))
+		return
+	}
+
+	if env.Version != 0 && env.Version != ProtocolVersion {
+		c.reply(replyError(env.ID, ErrCodeBadVersion, "Unsupported protocol version"))
+		return
+	}
+
+	if env.Type == TypePing {
+		c.reply(&Envelope{Version: ProtocolVersion, Type: TypePong, ID: env.ID})
+		return
+	}
+
+	newPayload, known := commandPayloads[env.Type]
+	handler := r.handler(env.Type)
+	if !known || handler == nil {
+		c.reply(replyError(env.ID, ErrCodeUnknownType, "Unsupported command type: "+env.Type))
+		return
+	}
+
+	payload := newPayload()
+	if len(env.Payload) == 0 {
+		c.reply(replyError(env.ID, ErrCodeInvalidPayload, "Payload is required"))
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(env.Payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(payload); err != nil {
+		c.reply(replyError(env.ID, ErrCodeInvalidPayload, "Malformed payload"))
+		return
+	}
+	if err := payload.Validate(); err != nil {
+		c.reply(replyError(env.ID, ErrCodeInvalidPayload, err.Error()))
+		return
+	}
+
+	result, err := handler(c, payload)
+	if err != nil {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
+			c.reply(replyError(env.ID, cmdErr.Code, cmdErr.Message))
+		} else {
+			log.Printf("❌ Error handling %s from user %d: %v", env.Type, c.UserID, err)
+			c.reply(replyError(env.ID, ErrCodeInternal, "Internal server error"))
+		}
+		return
+	}
+
+	ack := NewEvent(TypeAck, result)
+	ack.ID = env.ID
+	c.reply(ack)
+}
+
+func replyError(id, code, message string) *Envelope {
+	env := NewEvent(TypeError, ErrorPayload{Code: code, Message: message})
+	env.ID = id
+	return env
+}
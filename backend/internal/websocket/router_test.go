@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient creates a client without a connection; frames queued for it
+// are read straight off its send buffer
+func newTestClient(h *Hub, userID, buffer int) *Client {
+	return &Client{
+		hub:       h,
+		send:      make(chan []byte, buffer),
+		UserID:    userID,
+		SessionID: userID,
+		topics:    make(map[string]bool),
+	}
+}
+
+// startHub runs a hub in the background for the rest of the test binary
+func startHub() *Hub {
+	h := NewHub()
+	go h.Run()
+	return h
+}
+
+// next returns the next frame queued for c that is not a presence event
+func next(t *testing.T, c *Client) *Envelope {
+	t.Helper()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			if !ok {
+				t.Fatalf("connection of user %d was closed", c.UserID)
+			}
+			var env Envelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				t.Fatalf("decoding frame %s: %v", data, err)
+			}
+			if env.Type != TypeUserStatus {
+				return &env
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no frame for user %d", c.UserID)
+		}
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	h := startHub()
+	h.Router.Handle(TypeSendMessage, func(c *Client, payload interface{}) (interface{}, error) {
+		switch payload.(*SendMessagePayload).Content {
+		case "forbidden":
+			return nil, NewCommandError(ErrCodeForbidden, "Not allowed")
+		case "boom":
+			return nil, errors.New("database is down")
+		}
+		return map[string]int{"message_id": 7}, nil
+	})
+
+	c := newTestClient(h, 1, 16)
+	h.register <- c
+
+	tests := []struct {
+		name     string
+		frame    string
+		wantType string
+		wantCode string
+		wantID   string
+	}{
+		{"not json", `nope`, TypeError, ErrCodeBadEnvelope, ""},
+		{"missing type", `{"v":1,"id":"a"}`, TypeError, ErrCodeBadEnvelope, ""},
+		{"future version", `{"v":2,"type":"ping","id":"a"}`, TypeError, ErrCodeBadVersion, "a"},
+		{"ping without version", `{"type":"ping","id":"a"}`, TypePong, "", "a"},
+		{"ping with version", `{"v":1,"type":"ping","id":"b"}`, TypePong, "", "b"},
+		{"unknown type", `{"v":1,"type":"dance","id":"a","payload":{}}`, TypeError, ErrCodeUnknownType, "a"},
+		{"server event type", `{"v":1,"type":"new_message","id":"a","payload":{}}`, TypeError, ErrCodeUnknownType, "a"},
+		{"no registered handler", `{"v":1,"type":"mark_read","id":"a","payload":{"sender_id":2,"message_id":3}}`, TypeError, ErrCodeUnknownType, "a"},
+		{"missing payload", `{"v":1,"type":"send_message","id":"a"}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"payload of wrong shape", `{"v":1,"type":"send_message","id":"a","payload":[1]}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"unknown payload field", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"hi","urgent":true}}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"missing receiver", `{"v":1,"type":"send_message","id":"a","payload":{"content":"hi"}}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"blank content", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"  "}}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"content too long", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"` + strings.Repeat("x", maxMessageContentChars+1) + `"}}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"invalid topic", `{"v":1,"type":"subscribe","id":"a","payload":{"topics":["post:0"]}}`, TypeError, ErrCodeInvalidPayload, "a"},
+		{"command error", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"forbidden"}}`, TypeError, ErrCodeForbidden, "a"},
+		{"internal error", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"boom"}}`, TypeError, ErrCodeInternal, "a"},
+		{"ack", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"hi"}}`, TypeAck, "", "a"},
+		{"content at limit in multibyte characters", `{"v":1,"type":"send_message","id":"a","payload":{"receiver_id":2,"content":"` + strings.Repeat("é", maxMessageContentChars) + `"}}`, TypeAck, "", "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.Router.dispatch(c, []byte(tt.frame))
+			env := next(t, c)
+
+			if env.Type != tt.wantType {
+				t.Fatalf("reply type = %q (%s), want %q", env.Type, env.Payload, tt.wantType)
+			}
+			if env.ID != tt.wantID {
+				t.Errorf("reply id = %q, want %q", env.ID, tt.wantID)
+			}
+			if env.Version != ProtocolVersion {
+				t.Errorf("reply version = %d, want %d", env.Version, ProtocolVersion)
+			}
+			if tt.wantType != TypeError {
+				return
+			}
+
+			var payload ErrorPayload
+			if err := json.Unmarshal(env.Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Code != tt.wantCode {
+				t.Errorf("error code = %q (%s), want %q", payload.Code, payload.Message, tt.wantCode)
+			}
+			if tt.wantCode == ErrCodeInternal && strings.Contains(payload.Message, "database") {
+				t.Errorf("internal error leaked to the client: %q", payload.Message)
+			}
+		})
+	}
+}
+
+func TestRouterAckPayload(t *testing.T) {
+	h := startHub()
+	h.Router.Handle(TypeSendMessage, func(c *Client, payload interface{}) (interface{}, error) {
+		p := payload.(*SendMessagePayload)
+		return map[string]interface{}{"receiver_id": p.ReceiverID, "sender_id": c.UserID}, nil
+	})
+
+	c := newTestClient(h, 3, 16)
+	h.register <- c
+	h.Router.dispatch(c, []byte(`{"type":"send_message","id":"m1","payload":{"receiver_id":4,"content":"hello"}}`))
+
+	env := next(t, c)
+	var ack struct {
+		ReceiverID int `json:"receiver_id"`
+		SenderID   int `json:"sender_id"`
+	}
+	if err := json.Unmarshal(env.Payload, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if env.Type != TypeAck || ack.ReceiverID != 4 || ack.SenderID != 3 {
+		t.Errorf("reply = %s %s, want an ack carrying the handler result", env.Type, env.Payload)
+	}
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// How long a typing indicator stays active without a refreshing typing_start
+	typingTimeout = 6 * time.Second
+
+	// How often the hub checks for expired typing indicators
+	typingSweepPeriod = time.Second
+)
+
+// TypingEvent is the payload relayed to the conversation partner
+type TypingEvent struct {
+	SenderID int `json:"sender_id"`
+}
+
+// TypingGuard decides whether a user may send typing indicators to another user.
+// A returned *CommandError is passed on to the client as is.
+type TypingGuard interface {
+	CheckTyping(senderID, receiverID int) error
+}
+
+// SetTypingGuard sets the check run before relaying typing indicators.
+// Must be called before the server accepts connections.
+func (h *Hub) SetTypingGuard(guard TypingGuard) {
+	h.typingGuard = guard
+}
+
+// typingKey identifies one direction of a private conversation
+type typingKey struct {
+	senderID   int
+	receiverID int
+}
+
+// typingChange starts or stops a typing indicator
+type typingChange struct {
+	key    typingKey
+	typing bool
+}
+
+// handleTypingStart relays a typing_start command to the conversation partner
+func (h *Hub) handleTypingStart(c *Client, payload interface{}) (interface{}, error) {
+	return h.changeTyping(c, payload.(*TypingPayload), true)
+}
+
+// handleTypingStop relays a typing_stop command to the conversation partner
+func (h *Hub) handleTypingStop(c *Client, payload interface{}) (interface{}, error) {
+	return h.changeTyping(c, payload.(*TypingPayload), false)
+}
+
+func (h *Hub) changeTyping(c *Client, p *TypingPayload, typing bool) (interface{}, error) {
+	if p.ReceiverID == c.UserID {
+		return nil, NewCommandError(ErrCodeInvalidPayload, "Cannot send typing indicator to yourself")
+	}
+	if h.typingGuard != nil {
+		if err := h.typingGuard.CheckTyping(c.UserID, p.ReceiverID); err != nil {
+			return nil, err
+		}
+	}
+
+	h.typingChanges <- typingChange{
+		key:    typingKey{senderID: c.UserID, receiverID: p.ReceiverID},
+		typing: typing,
+	}
+	return nil, nil
+}
+
+// applyTyping records a typing change and notifies the partner when the state flips.
+// Repeated typing_start commands only push the expiry forward.
+// Must only be called from the Run loop.
+func (h *Hub) applyTyping(change typingChange) {
+	_, active := h.typing[change.key]
+
+	if change.typing {
+		h.typing[change.key] = time.Now().Add(typingTimeout)
+		if !active {
+			h.relayTyping(change.key, TypeTypingStart)
+		}
+		return
+	}
+
+	if active {
+		delete(h.typing, change.key)
+		h.relayTyping(change.key, TypeTypingStop)
+	}
+}
+
+// expireTyping stops indicators that have not been refreshed in time,
+// or whose sender no longer has any open connection.
+// Must only be called from the Run loop.
+func (h *Hub) expireTyping(now time.Time) {
+	for key, expiresAt := range h.typing {
+		if now.After(expiresAt) || len(h.users[key.senderID]) == 0 {
+			delete(h.typing, key)
+			h.relayTyping(key, TypeTypingStop)
+		}
+	}
+}
+
+// relayTyping sends a typing event to the receiver of the conversation only.
+// Must only be called from the Run loop.
+func (h *Hub) relayTyping(key typingKey, eventType string) {
+	data, err := json.Marshal(NewEvent(eventType, TypingEvent{SenderID: key.senderID}))
+	if err != nil {
+		return
+	}
+	h.deliverToUser(key.receiverID, data)
+}
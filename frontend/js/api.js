@@ -36,6 +36,10 @@ const API = {
         register: (userData) => API.request('/register', 'POST', userData),
         login: (credentials) => API.request('/login', 'POST', credentials),
         logout: () => API.request('/logout', 'POST'),
+        forgotPassword: (email) => API.request('/api/password/forgot', 'POST', { email }),
+        resetPassword: (token, password) => API.request('/api/password/reset', 'POST', { token, password }),
+        verifyEmail: (token) => API.request('/api/email/verify', 'POST', { token }),
+        resendVerification: () => API.request('/api/email/resend', 'POST'),
         checkSession: async () => {
             // We don't have a dedicated check-session endpoint, 
             // but we can try to get the user info or online users to check auth
@@ -57,20 +61,53 @@ const API = {
 
     // Posts API
     posts: {
-        getAll: () => API.request('/posts'), // Need to update backend to return JSON for this
+        // params: category, author, filter, search, min_likes, max_age, sort_by, sort_order, rank, window, limit, offset
+        getAll: (params = {}) => API.request(`/posts?${new URLSearchParams(params)}`),
         create: (postData) => API.request('/posts/create', 'POST', postData),
         getOne: (id) => API.request(`/posts/view?id=${id}`),
+        update: (id, postData) => API.request(`/api/posts?id=${id}`, 'PUT', postData),
+        remove: (id) => API.request(`/api/posts?id=${id}`, 'DELETE'),
     },
 
     // Comments API
     comments: {
         create: (commentData) => API.request('/comments/create', 'POST', commentData),
+        // params: post_id or parent_id, after
+        getReplies: (params) => API.request(`/comments/replies?${new URLSearchParams(params)}`),
+        update: (id, content) => API.request(`/api/comments?id=${id}`, 'PUT', { content }),
+        remove: (id) => API.request(`/api/comments?id=${id}`, 'DELETE'),
+    },
+
+    // Edit history API
+    revisions: {
+        // type: post or comment
+        list: (type, id) => API.request(`/api/revisions?type=${type}&id=${id}`),
+    },
+
+    // Login sessions API
+    sessions: {
+        list: () => API.request('/api/sessions'),
+        revoke: (id) => API.request(`/api/sessions?id=${id}`, 'DELETE'),
+        revokeOthers: () => API.request('/api/sessions/revoke-others', 'POST'),
+    },
+
+    // Votes API
+    votes: {
+        cast: (targetType, targetId, type) => API.request('/api/votes', 'POST', {
+            target_type: targetType,
+            target_id: targetId,
+            type
+        }),
     },
 
     // Messages API
     messages: {
         send: (data) => API.request('/api/messages/send', 'POST', data),
-        getHistory: (userId, limit = 50) => API.request(`/api/messages/history?user_id=${userId}&limit=${limit}`),
+        getHistory: (userId, limit = 50, beforeId = null) => {
+            const cursor = beforeId ? `&before_id=${beforeId}` : '';
+            return API.request(`/api/messages/history?user_id=${userId}&limit=${limit}${cursor}`);
+        },
         getOnlineUsers: () => API.request('/api/online-users'),
+        markRead: (senderId, messageId) => API.request('/api/messages/read', 'POST', { sender_id: senderId, message_id: messageId }),
     }
 };
\ No newline at end of file
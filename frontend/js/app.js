@@ -37,12 +37,26 @@ const App = {
         } else if (hash === '#/register') {
             appContainer.innerHTML = Views.getRegisterView();
             App.bindRegisterEvents();
+        } else if (hash === '#/forgot-password') {
+            appContainer.innerHTML = Views.getForgotPasswordView();
+            App.bindForgotPasswordEvents();
+        } else if (hash.startsWith('#/verify-email')) {
+            // Emailed links look like #/verify-email?token=...
+            const token = new URLSearchParams(hash.split('?')[1] || '').get('token');
+            App.verifyEmail(token);
+        } else if (hash.startsWith('#/reset-password')) {
+            // Emailed links look like #/reset-password?token=...
+            const token = new URLSearchParams(hash.split('?')[1] || '').get('token');
+            appContainer.innerHTML = Views.getResetPasswordView();
+            App.bindResetPasswordEvents(token);
         } else if (hash === '#/') {
             if (!App.state.user) {
                 window.location.hash = '#/login';
                 return;
             }
             appContainer.innerHTML = Views.getHomeView(App.state.user);
+            App.bindFeedControls();
+            App.bindVerifyEmailBanner();
             App.loadFeed();
             // Initialize WebSocket if logged in
             if (window.WebSocketHandler) {
@@ -76,6 +90,12 @@ const App = {
             ];
             appContainer.innerHTML = Views.getCreatePostView(categories);
             App.bindCreatePostEvents();
+        } else if (hash === '#/sessions') {
+            if (!App.state.user) {
+                window.location.hash = '#/login';
+                return;
+            }
+            App.loadSessions();
         } else if (hash.startsWith('#/post/')) {
             if (!App.state.user) {
                 window.location.hash = '#/login';
@@ -106,6 +126,7 @@ const App = {
             e.preventDefault();
             const formData = new FormData(form);
             const data = Object.fromEntries(formData.entries());
+            data.remember_me = form.elements.remember_me.checked;
 
             try {
                 const response = await API.auth.login(data);
@@ -119,6 +140,65 @@ const App = {
         });
     },
 
+    verifyEmail: async (token) => {
+        const appContainer = document.getElementById('app');
+        appContainer.innerHTML = '<p>Verifying your email address...</p>';
+
+        try {
+            const response = await API.auth.verifyEmail(token);
+            if (App.state.user && App.state.user.id === response.user_id) {
+                App.state.user.email_verified_at = new Date().toISOString();
+                localStorage.setItem('user', JSON.stringify(App.state.user));
+            }
+            appContainer.innerHTML = Views.getVerifyEmailView(`✅ ${response.message}. You can now post and send messages.`);
+        } catch (error) {
+            appContainer.innerHTML = Views.getVerifyEmailView(`❌ ${error.message}`);
+        }
+    },
+
+    bindVerifyEmailBanner: () => {
+        const resendBtn = document.getElementById('resend-verification-btn');
+        if (!resendBtn) return;
+
+        resendBtn.addEventListener('click', async () => {
+            try {
+                const response = await API.auth.resendVerification();
+                alert(response.message);
+            } catch (error) {
+                alert(error.message);
+            }
+        });
+    },
+
+    bindForgotPasswordEvents: () => {
+        const form = document.getElementById('forgot-password-form');
+        form.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            try {
+                const response = await API.auth.forgotPassword(form.elements.email.value);
+                alert(response.message);
+                window.location.hash = '#/login';
+            } catch (error) {
+                App.showError(error.message);
+            }
+        });
+    },
+
+    bindResetPasswordEvents: (token) => {
+        const form = document.getElementById('reset-password-form');
+        form.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            try {
+                const response = await API.auth.resetPassword(token, form.elements.password.value);
+                alert(response.message);
+                // The reset logged this account out everywhere, this browser included
+                App.clearUser();
+            } catch (error) {
+                App.showError(error.message);
+            }
+        });
+    },
+
     bindRegisterEvents: () => {
         const form = document.getElementById('register-form');
         form.addEventListener('submit', async (e) => {
@@ -130,8 +210,8 @@ const App = {
             data.age = parseInt(data.age);
 
             try {
-                await API.auth.register(data);
-                alert('Registration successful! Please login.');
+                const response = await API.auth.register(data);
+                alert(response.message);
                 window.location.hash = '#/login';
             } catch (error) {
                 App.showError(error.message);
@@ -170,15 +250,63 @@ const App = {
     handleLogout: async () => {
         try {
             await API.auth.logout();
-            App.state.user = null;
-            localStorage.removeItem('user');
-            App.renderNavbar();
-            window.location.hash = '#/login';
+            App.clearUser();
         } catch (error) {
             console.error('Logout failed:', error);
         }
     },
 
+    // Forget the logged-in user and go back to the login page
+    clearUser: () => {
+        App.state.user = null;
+        localStorage.removeItem('user');
+        App.renderNavbar();
+        window.location.hash = '#/login';
+    },
+
+    // This device's session was revoked from another one; the server has closed the socket
+    handleSessionRevoked: () => {
+        alert('You were logged out from another device.');
+        App.clearUser();
+    },
+
+    loadSessions: async () => {
+        const appContainer = document.getElementById('app');
+        appContainer.innerHTML = '<p>Loading sessions...</p>';
+
+        try {
+            const data = await API.sessions.list();
+            appContainer.innerHTML = Views.getSessionsView(data.sessions);
+        } catch (error) {
+            appContainer.innerHTML = `<p class="error">Error loading sessions: ${error.message}</p>`;
+            return;
+        }
+
+        appContainer.querySelectorAll('.revoke-session-btn').forEach(btn => {
+            btn.addEventListener('click', async () => {
+                try {
+                    await API.sessions.revoke(btn.dataset.id);
+                    App.loadSessions();
+                } catch (error) {
+                    alert('Error logging out device: ' + error.message);
+                }
+            });
+        });
+
+        const revokeOthersBtn = document.getElementById('revoke-others-btn');
+        if (revokeOthersBtn) {
+            revokeOthersBtn.addEventListener('click', async () => {
+                if (!confirm('Log out every other device?')) return;
+                try {
+                    await API.sessions.revokeOthers();
+                    App.loadSessions();
+                } catch (error) {
+                    alert('Error logging out devices: ' + error.message);
+                }
+            });
+        }
+    },
+
     showError: (message) => {
         const errorDiv = document.getElementById('error-message');
         if (errorDiv) {
@@ -189,12 +317,33 @@ const App = {
         }
     },
 
+    // Reload the feed when the ranking mode or top window changes
+    bindFeedControls: () => {
+        const rank = document.getElementById('feed-rank');
+        const topWindow = document.getElementById('feed-window');
+
+        rank.addEventListener('change', () => {
+            topWindow.hidden = rank.value !== 'top';
+            App.loadFeed();
+        });
+        topWindow.addEventListener('change', App.loadFeed);
+    },
+
     loadFeed: async () => {
         const feedContainer = document.getElementById('posts-feed');
         feedContainer.innerHTML = '<p>Loading posts...</p>';
 
+        const params = {};
+        const rank = document.getElementById('feed-rank');
+        if (rank) {
+            params.rank = rank.value;
+            if (rank.value === 'top') {
+                params.window = document.getElementById('feed-window').value;
+            }
+        }
+
         try {
-            const posts = await API.posts.getAll();
+            const { posts } = await API.posts.getAll(params);
             if (posts && posts.length > 0) {
                 feedContainer.innerHTML = posts.map(post => Views.getPostCard(post)).join('');
             } else {
@@ -205,33 +354,244 @@ const App = {
         }
     },
 
+    // Prepend a post published over WebSocket if the feed is on screen
+    handlePostCreated: (post) => {
+        const feedContainer = document.getElementById('posts-feed');
+        if (!feedContainer) return;
+
+        if (!feedContainer.querySelector('.post-card')) {
+            feedContainer.innerHTML = '';
+        }
+        feedContainer.insertAdjacentHTML('afterbegin', Views.getPostCard(post));
+    },
+
+    // Replace or drop a post edited or deleted elsewhere, in the feed or on its own page
+    handlePostUpdated: (post) => {
+        if (window.location.hash === `#/post/${post.id}`) {
+            App.loadPostDetail(post.id);
+            return;
+        }
+
+        const stats = document.querySelector(`#posts-feed .post-stats[data-post-id="${post.id}"]`);
+        if (!stats) return;
+        const card = stats.closest('.post-card');
+        if (post.deleted_at) {
+            card.remove();
+        } else {
+            card.outerHTML = Views.getPostCard(post);
+        }
+    },
+
+    // Refresh the open post when someone else comments on it
+    handleCommentCreated: (comment) => {
+        if (window.location.hash === `#/post/${comment.post_id}` && comment.user_id !== App.state.user?.id) {
+            App.loadPostDetail(comment.post_id);
+        }
+    },
+
+    // Update post scoreboards on screen with counts pushed over WebSocket
+    handleVoteUpdated: (update) => {
+        if (update.target_type !== 'post') return;
+
+        document.querySelectorAll(`.post-stats[data-post-id="${update.target_id}"]`).forEach(stats => {
+            stats.querySelector('.like-count').textContent = update.like_count;
+            stats.querySelector('.dislike-count').textContent = update.dislike_count;
+        });
+    },
+
     loadPostDetail: async (postId) => {
         const appContainer = document.getElementById('app');
         appContainer.innerHTML = '<p>Loading post...</p>';
 
         try {
             const data = await API.posts.getOne(postId);
-            appContainer.innerHTML = Views.getPostDetailView(data.post, data.comments, App.state.user);
+            appContainer.innerHTML = Views.getPostDetailView(data.post, data.comments, data.next_cursor, App.state.user);
+            App.bindPostActions(data.post);
+
+            // Receive new comments on this thread live
+            if (typeof Chat !== 'undefined') {
+                Chat.subscribe([`post:${postId}`]);
+            }
+
+            // Bind vote buttons
+            document.querySelectorAll('.post-detail-card .vote-btn').forEach(btn => {
+                btn.addEventListener('click', () => App.handleVote(postId, btn.dataset.vote));
+            });
 
             // Bind comment form event
             const commentForm = document.getElementById('create-comment-form');
             if (commentForm) {
                 commentForm.addEventListener('submit', App.handleCreateComment);
             }
+
+            // Reply and load-more buttons live inside threads that grow, so delegate
+            const commentsList = appContainer.querySelector('.comments-list');
+            commentsList.addEventListener('click', App.handleThreadClick);
+            commentsList.addEventListener('submit', (e) => {
+                if (e.target.classList.contains('edit-comment-form')) {
+                    App.handleEditComment(e);
+                } else {
+                    App.handleCreateComment(e);
+                }
+            });
         } catch (error) {
             appContainer.innerHTML = `<h2>Error</h2><p>${error.message}</p><a href="#/">Back to Home</a>`;
         }
     },
 
+    handleVote: async (postId, type) => {
+        try {
+            const data = await API.votes.cast('post', parseInt(postId), type);
+            App.handleVoteUpdated({ target_type: 'post', target_id: parseInt(postId), ...data.stats });
+        } catch (error) {
+            alert('Error voting: ' + error.message);
+        }
+    },
+
+    // Bind the edit, delete and history buttons of the open post
+    bindPostActions: (post) => {
+        const card = document.querySelector('.post-detail-card');
+
+        const historyBtn = card.querySelector('.history-btn');
+        if (historyBtn) {
+            historyBtn.addEventListener('click', () => App.toggleRevisions(historyBtn, card));
+        }
+
+        const editBtn = document.getElementById('edit-post-btn');
+        if (!editBtn) return;
+
+        editBtn.addEventListener('click', () => {
+            if (card.querySelector('#edit-post-form')) return;
+            card.querySelector('.post-content').insertAdjacentHTML('afterend', Views.getEditPostForm());
+
+            const form = card.querySelector('#edit-post-form');
+            form.elements.title.value = post.title;
+            form.elements.content.value = post.content;
+            form.querySelector('.cancel-edit-btn').addEventListener('click', () => form.remove());
+            form.addEventListener('submit', async (e) => {
+                e.preventDefault();
+                try {
+                    await API.posts.update(post.id, { title: form.elements.title.value, content: form.elements.content.value });
+                    App.loadPostDetail(post.id);
+                } catch (error) {
+                    alert('Error updating post: ' + error.message);
+                }
+            });
+        });
+
+        document.getElementById('delete-post-btn').addEventListener('click', async () => {
+            if (!confirm('Delete this post? Its comments will stay visible.')) return;
+            try {
+                await API.posts.remove(post.id);
+                App.loadPostDetail(post.id);
+            } catch (error) {
+                alert('Error deleting post: ' + error.message);
+            }
+        });
+    },
+
+    // Show or hide the edit history below a post or comment
+    toggleRevisions: async (historyBtn, container) => {
+        const panel = container.querySelector(':scope > .revisions-panel');
+        if (panel.innerHTML.trim()) {
+            panel.innerHTML = '';
+            return;
+        }
+
+        try {
+            const data = await API.revisions.list(historyBtn.dataset.type, historyBtn.dataset.id);
+            panel.innerHTML = Views.getRevisionsView(data.revisions);
+        } catch (error) {
+            alert('Error loading history: ' + error.message);
+        }
+    },
+
+    handleThreadClick: async (e) => {
+        const historyBtn = e.target.closest('.history-btn');
+        if (historyBtn) {
+            App.toggleRevisions(historyBtn, historyBtn.closest('.comment-card'));
+            return;
+        }
+
+        const editBtn = e.target.closest('.edit-comment-btn');
+        if (editBtn) {
+            const card = editBtn.closest('.comment-card');
+            if (card.querySelector(':scope > .edit-comment-form')) return;
+
+            const content = card.querySelector(':scope > .comment-content');
+            content.insertAdjacentHTML('afterend', Views.getEditCommentForm(editBtn.dataset.commentId));
+            const form = card.querySelector(':scope > .edit-comment-form');
+            form.elements.content.value = content.textContent.trim();
+            form.querySelector('.cancel-edit-btn').addEventListener('click', () => form.remove());
+            return;
+        }
+
+        const deleteBtn = e.target.closest('.delete-comment-btn');
+        if (deleteBtn) {
+            if (!confirm('Delete this comment? Replies to it will stay visible.')) return;
+            try {
+                const data = await API.comments.remove(deleteBtn.dataset.commentId);
+                App.loadPostDetail(data.comment.post_id);
+            } catch (error) {
+                alert('Error deleting comment: ' + error.message);
+            }
+            return;
+        }
+
+        const replyBtn = e.target.closest('.reply-btn');
+        if (replyBtn) {
+            const card = replyBtn.closest('.comment-card');
+            const existing = card.querySelector(':scope > .reply-form');
+            if (existing) {
+                existing.remove();
+            } else {
+                const postId = card.closest('.comments-section').querySelector('#create-comment-form').dataset.postId;
+                card.querySelector(':scope > .comment-actions').insertAdjacentHTML('afterend', Views.getReplyForm(postId, replyBtn.dataset.commentId));
+            }
+            return;
+        }
+
+        const moreBtn = e.target.closest('.load-more-btn');
+        if (moreBtn) {
+            const { postId, parentId, after } = moreBtn.dataset;
+            const params = parentId ? { parent_id: parentId, after } : { post_id: postId, after };
+            moreBtn.disabled = true;
+
+            try {
+                const data = await API.comments.getReplies(params);
+                const label = moreBtn.textContent;
+                moreBtn.outerHTML = data.comments.map(comment => Views.getCommentCard(comment, App.state.user)).join('')
+                    + Views.getLoadMoreButton(postId, parentId, data.next_cursor, label);
+            } catch (error) {
+                moreBtn.disabled = false;
+                alert('Error loading comments: ' + error.message);
+            }
+        }
+    },
+
+    handleEditComment: async (e) => {
+        e.preventDefault();
+        const form = e.target;
+
+        try {
+            const data = await API.comments.update(form.dataset.commentId, form.elements.content.value);
+            App.loadPostDetail(data.comment.post_id);
+        } catch (error) {
+            alert('Error updating comment: ' + error.message);
+        }
+    },
+
     handleCreateComment: async (e) => {
         e.preventDefault();
         const form = e.target;
         const postId = form.dataset.postId;
+        const parentId = form.dataset.parentId;
         const content = form.querySelector('textarea[name="content"]').value;
 
         try {
             await API.comments.create({
                 post_id: parseInt(postId),
+                parent_id: parentId ? parseInt(parentId) : null,
                 content: content
             });
             // Reload post detail to show new comment
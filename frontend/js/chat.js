@@ -6,9 +6,15 @@ const Chat = {
     onlineUsers: [],
     unreadCounts: {}, // userId -> count
 
+    // Typing indicator state
+    typingRefreshMs: 3000, // typing_start is repeated this often while typing; the server expires it after 6s
+    typingIdleMs: 2000, // typing_stop is sent after this long without a keystroke
+    typingSentAt: 0,
+    typingStopTimer: null,
+
     // Pagination state
     messageLimit: 10,
-    currentOffset: 0,
+    nextCursor: null, // before_id for the next page of older messages
     isLoadingHistory: false,
     hasMoreMessages: true,
 
@@ -25,6 +31,8 @@ const Chat = {
             console.log('✅ WebSocket Connected');
             // Request online users update
             Chat.updateOnlineUsers();
+            // Receive new posts live
+            Chat.subscribe(['feed']);
         });
 
         Chat.ws.onMessage((message) => {
@@ -39,6 +47,13 @@ const Chat = {
         window.WebSocketHandler = Chat.ws;
     },
 
+    // Subscribe to live feed topics ('feed', 'category:ID' or 'post:ID')
+    subscribe: (topics) => {
+        if (Chat.ws && Chat.ws.isConnected()) {
+            Chat.ws.send({ v: 1, type: 'subscribe', payload: { topics } });
+        }
+    },
+
     connect: () => {
         if (Chat.ws) {
             Chat.ws.connect();
@@ -90,7 +105,7 @@ const Chat = {
         Chat.renderOnlineUsers(); // Update UI to show active state and clear badge
 
         // Reset pagination
-        Chat.currentOffset = 0;
+        Chat.nextCursor = null;
         Chat.hasMoreMessages = true;
 
         // Create or show chat window
@@ -111,15 +126,23 @@ const Chat = {
         // Bind send event
         const form = document.getElementById('chat-form');
         if (form) {
+            const input = form.querySelector('input');
             form.addEventListener('submit', (e) => {
                 e.preventDefault();
-                const input = form.querySelector('input');
                 const content = input.value.trim();
                 if (content) {
+                    Chat.stopTyping(userId);
                     Chat.sendMessage(userId, content);
                     input.value = '';
                 }
             });
+            input.addEventListener('input', () => {
+                if (input.value.trim()) {
+                    Chat.startTyping(userId);
+                } else {
+                    Chat.stopTyping(userId);
+                }
+            });
         }
 
         // Bind scroll event for throttling
@@ -132,6 +155,7 @@ const Chat = {
         const closeBtn = chatWindow.querySelector('.close-chat');
         if (closeBtn) {
             closeBtn.addEventListener('click', () => {
+                Chat.stopTyping(userId);
                 chatWindow.classList.add('hidden');
                 Chat.activeChatUserId = null;
                 Chat.renderOnlineUsers();
@@ -139,6 +163,42 @@ const Chat = {
         }
     },
 
+    // Tell userId we are typing, refreshing the indicator while keystrokes keep coming
+    startTyping: (userId) => {
+        const now = Date.now();
+        if (now - Chat.typingSentAt >= Chat.typingRefreshMs) {
+            if (Chat.ws && Chat.ws.isConnected()) {
+                Chat.ws.send({ v: 1, type: 'typing_start', payload: { receiver_id: userId } });
+            }
+            Chat.typingSentAt = now;
+        }
+
+        clearTimeout(Chat.typingStopTimer);
+        Chat.typingStopTimer = setTimeout(() => Chat.stopTyping(userId), Chat.typingIdleMs);
+    },
+
+    // Tell userId we stopped typing, if we told them we started
+    stopTyping: (userId) => {
+        clearTimeout(Chat.typingStopTimer);
+        Chat.typingStopTimer = null;
+        if (Chat.typingSentAt === 0) return;
+
+        Chat.typingSentAt = 0;
+        if (Chat.ws && Chat.ws.isConnected()) {
+            Chat.ws.send({ v: 1, type: 'typing_stop', payload: { receiver_id: userId } });
+        }
+    },
+
+    // Show or hide "is typing" for the open conversation
+    showTyping: (senderId, typing) => {
+        if (Chat.activeChatUserId !== senderId) return;
+
+        const indicator = document.getElementById('chat-typing');
+        if (indicator) {
+            indicator.classList.toggle('hidden', !typing);
+        }
+    },
+
     // Throttling utility
     throttle: (func, limit) => {
         let inThrottle;
@@ -175,45 +235,21 @@ const Chat = {
         }
 
         try {
-            // We need to support offset in API or just use limit.
-            // The current API `getHistory` uses `limit`.
-            // To implement pagination properly, we need `offset` or `before_id`.
-            // The backend `GetMessageHistory` handler only takes `limit`.
-            // It returns the *latest* N messages.
-            // To get older messages, we need to modify the backend to accept `offset` or `before_id`.
-            // Since I can't modify backend right now (or I should?), I'll stick to `limit`.
-            // Wait, I CAN modify backend.
-            // But for now, let's assume I just load a larger limit.
-            // Actually, the requirement says "Implement scroll throttling/debouncing".
-            // So I should implement pagination.
-
-            // Let's modify the request to include offset if I updated backend.
-            // But I didn't update backend to support offset.
-            // So I'll just simulate it by increasing limit? No that's inefficient.
-            // I'll assume for this task, I just implement the frontend logic 
-            // and maybe the backend already supports it or I'll update backend next.
-            // Actually, `GetMessageHistory` in `messages.go` uses `LIMIT ?`.
-            // It doesn't use OFFSET.
-            // So I can only get the latest N messages.
-
-            // I'll update the frontend to request `limit` based on `currentOffset + messageLimit`.
-            // And `currentOffset` tracks how many we have loaded.
-            // So `limit` = `currentOffset + messageLimit`.
-            // This means we always fetch 0 to N. This is inefficient but works without backend changes for OFFSET.
-            // Ideally I should add OFFSET to backend.
-
-            const limit = Chat.currentOffset + Chat.messageLimit;
-            const response = await API.messages.getHistory(userId, limit);
+            // Older pages are requested with the cursor returned by the previous page,
+            // so messages arriving meanwhile don't shift what we get back
+            const beforeId = isPagination ? Chat.nextCursor : null;
+            const response = await API.messages.getHistory(userId, Chat.messageLimit, beforeId);
 
             if (response.success) {
                 const messages = response.messages || [];
 
-                if (messages.length <= Chat.currentOffset) {
-                    Chat.hasMoreMessages = false;
-                }
-
-                Chat.currentOffset = messages.length;
+                Chat.hasMoreMessages = response.has_more;
+                Chat.nextCursor = response.next_cursor;
                 Chat.renderMessages(messages, isPagination);
+
+                if (!isPagination) {
+                    Chat.markRead(userId, messages);
+                }
             }
         } catch (error) {
             if (!isPagination) {
@@ -224,38 +260,45 @@ const Chat = {
         }
     },
 
+    // Mark everything received from userId in this batch as read
+    markRead: (userId, messages) => {
+        const lastReceived = messages
+            .filter(msg => msg.sender_id === userId)
+            .reduce((maxId, msg) => Math.max(maxId, msg.id), 0);
+
+        if (lastReceived > 0) {
+            API.messages.markRead(userId, lastReceived).catch(error => {
+                console.error('Error marking messages as read:', error);
+            });
+        }
+    },
+
     renderMessages: (messages, isPagination = false) => {
         const container = document.getElementById('chat-messages');
         if (!container) return;
 
-        // Sort messages by date (oldest first)
-        messages.sort((a, b) => new Date(a.created_at) - new Date(b.created_at));
-
+        // History pages arrive oldest first
         const html = messages.map(msg => {
             const isMe = msg.sender_id === App.state.user.id;
             const typeClass = isMe ? 'sent' : 'received';
             const time = new Date(msg.created_at).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+            const ticks = isMe ? Chat.statusTicks(msg.status) : '';
 
             return `
-            <div class="message ${typeClass}">
+            <div class="message ${typeClass}" data-id="${msg.id}">
                 <div class="message-content">${msg.content}</div>
-                <div class="message-time">${time}</div>
+                <div class="message-time">${time}${ticks}</div>
             </div>
             `;
         }).join('');
 
         if (isPagination) {
-            // Maintain scroll position
+            // Prepend older messages and keep the current view in place
             const oldHeight = container.scrollHeight;
-            const oldTop = container.scrollTop;
 
-            container.innerHTML = html;
+            container.insertAdjacentHTML('afterbegin', html);
 
-            // Restore scroll position relative to new content
-            const newHeight = container.scrollHeight;
-            container.scrollTop = newHeight - oldHeight + oldTop; // Roughly keeps position
-            // Actually, if we prepend, we want to scroll to (newHeight - oldHeight).
-            container.scrollTop = newHeight - oldHeight;
+            container.scrollTop = container.scrollHeight - oldHeight;
         } else {
             container.innerHTML = html;
             // Scroll to bottom
@@ -263,6 +306,31 @@ const Chat = {
         }
     },
 
+    // Receipt ticks for a message we sent: ✓ sent, ✓✓ delivered, blue ✓✓ read
+    statusTicks: (status) => {
+        const ticks = status === 'sent' ? '✓' : '✓✓';
+        return ` <span class="message-status ${status}" title="${status}">${ticks}</span>`;
+    },
+
+    // Update the ticks on a sent message element
+    setMessageStatus: (element, status) => {
+        const ticks = element.querySelector('.message-status');
+        if (ticks) {
+            ticks.outerHTML = Chat.statusTicks(status).trim();
+        }
+    },
+
+    // The partner read our messages up to upToId
+    handleMessageRead: (event) => {
+        if (Chat.activeChatUserId !== event.reader_id) return;
+
+        document.querySelectorAll('#chat-messages .message.sent[data-id]').forEach(element => {
+            if (Number(element.dataset.id) <= event.up_to_id) {
+                Chat.setMessageStatus(element, 'read');
+            }
+        });
+    },
+
     sendMessage: async (receiverId, content) => {
         // Optimistic UI update
         const tempMsg = {
@@ -279,23 +347,26 @@ const Chat = {
             container.insertAdjacentHTML('beforeend', `
                 <div class="message sent pending">
                     <div class="message-content">${content}</div>
-                    <div class="message-time">${time}</div>
+                    <div class="message-time">${time}${Chat.statusTicks('sent')}</div>
                 </div>
             `);
             container.scrollTop = container.scrollHeight;
         }
 
         try {
-            await API.messages.send({
+            const response = await API.messages.send({
                 receiver_id: receiverId,
                 content: content
             });
 
             const pending = container.querySelector('.pending');
-            if (pending) pending.classList.remove('pending');
-
-            // Update offset since we added a message
-            Chat.currentOffset++;
+            if (pending) {
+                pending.classList.remove('pending');
+                if (response.message) {
+                    pending.dataset.id = response.message.id;
+                    Chat.setMessageStatus(pending, response.message.status);
+                }
+            }
 
         } catch (error) {
             console.error('Error sending message:', error);
@@ -307,9 +378,10 @@ const Chat = {
         console.log('📩 Received message:', payload);
 
         if (payload.type === 'new_message') {
-            const msg = payload.message;
+            const msg = payload.payload.message;
 
             if (Chat.activeChatUserId === msg.sender_id) {
+                Chat.showTyping(msg.sender_id, false);
                 const container = document.getElementById('chat-messages');
                 if (container) {
                     const time = new Date(msg.created_at).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
@@ -320,15 +392,30 @@ const Chat = {
                         </div>
                     `);
                     container.scrollTop = container.scrollHeight;
-                    // Update offset
-                    Chat.currentOffset++;
+                    Chat.markRead(msg.sender_id, [msg]);
                 }
             } else {
                 Chat.unreadCounts[msg.sender_id] = (Chat.unreadCounts[msg.sender_id] || 0) + 1;
                 Chat.renderOnlineUsers();
             }
+        } else if (payload.type === 'message_read') {
+            Chat.handleMessageRead(payload.payload);
+        } else if (payload.type === 'typing_start' || payload.type === 'typing_stop') {
+            Chat.showTyping(payload.payload.sender_id, payload.type === 'typing_start');
         } else if (payload.type === 'user_status') {
             Chat.updateOnlineUsers();
+        } else if (payload.type === 'post_created') {
+            App.handlePostCreated(payload.payload.post);
+        } else if (payload.type === 'post_updated') {
+            App.handlePostUpdated(payload.payload.post);
+        } else if (payload.type === 'comment_created' || payload.type === 'comment_updated') {
+            App.handleCommentCreated(payload.payload.comment);
+        } else if (payload.type === 'vote_updated') {
+            App.handleVoteUpdated(payload.payload);
+        } else if (payload.type === 'session_revoked') {
+            // Reconnecting would only be refused
+            Chat.ws.disconnect();
+            App.handleSessionRevoked();
         }
     }
 };
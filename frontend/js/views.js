@@ -14,13 +14,66 @@ const Views = {
                     <label for="password">Password</label>
                     <input type="password" id="password" name="password" required placeholder="Enter password">
                 </div>
+                <div class="form-group remember-me">
+                    <input type="checkbox" id="remember_me" name="remember_me">
+                    <label for="remember_me">Remember me</label>
+                </div>
                 <button type="submit" class="btn btn-primary">Login</button>
             </form>
+            <p class="auth-link"><a href="#/forgot-password">Forgot your password?</a></p>
             <p class="auth-link">Don't have an account? <a href="#/register">Register here</a></p>
             <div id="error-message" class="error hidden"></div>
         </div>
     `,
 
+    // Banner asking users who haven't verified their email yet to do so
+    getVerifyEmailBanner: () => `
+        <div class="verify-banner">
+            📧 Please verify your email address to post and send messages. Check your inbox for the link.
+            <button type="button" id="resend-verification-btn" class="btn btn-small btn-secondary">Resend email</button>
+        </div>
+    `,
+
+    // Email Verification View
+    getVerifyEmailView: (message) => `
+        <div class="auth-container">
+            <h2>📧 Email Verification</h2>
+            <p>${message}</p>
+            <p class="auth-link"><a href="#/">Go to the forum</a></p>
+        </div>
+    `,
+
+    // Forgot Password View
+    getForgotPasswordView: () => `
+        <div class="auth-container">
+            <h2>🔑 Forgot Password</h2>
+            <form id="forgot-password-form">
+                <div class="form-group">
+                    <label for="email">Email</label>
+                    <input type="email" id="email" name="email" required placeholder="Enter your account's email">
+                </div>
+                <button type="submit" class="btn btn-primary">Send reset link</button>
+            </form>
+            <p class="auth-link"><a href="#/login">Back to login</a></p>
+            <div id="error-message" class="error hidden"></div>
+        </div>
+    `,
+
+    // Reset Password View
+    getResetPasswordView: () => `
+        <div class="auth-container">
+            <h2>🔑 Choose a New Password</h2>
+            <form id="reset-password-form">
+                <div class="form-group">
+                    <label for="password">New password</label>
+                    <input type="password" id="password" name="password" required minlength="6" placeholder="At least 6 characters">
+                </div>
+                <button type="submit" class="btn btn-primary">Reset password</button>
+            </form>
+            <div id="error-message" class="error hidden"></div>
+        </div>
+    `,
+
     // Registration View
     getRegisterView: () => `
         <div class="auth-container">
@@ -88,9 +141,24 @@ const Views = {
                 </div>
             </aside>
             <main class="feed">
+                ${user.email_verified_at === null ? Views.getVerifyEmailBanner() : ''}
                 <div class="create-post-teaser">
                     <a href="#/create-post" class="btn btn-primary">➕ Create New Post</a>
                 </div>
+                <div class="feed-controls">
+                    <select id="feed-rank">
+                        <option value="new">🆕 New</option>
+                        <option value="hot">🔥 Hot</option>
+                        <option value="top">🏆 Top</option>
+                        <option value="controversial">⚡ Controversial</option>
+                    </select>
+                    <select id="feed-window" hidden>
+                        <option value="day">Today</option>
+                        <option value="week">This week</option>
+                        <option value="month">This month</option>
+                        <option value="all">All time</option>
+                    </select>
+                </div>
                 <div id="posts-feed">
                     <!-- Posts will be loaded here -->
                     <p>Loading posts...</p>
@@ -128,7 +196,7 @@ const Views = {
                     
                     <div class="form-group">
                         <label for="content">📄 Post Content *</label>
-                        <textarea id="content" name="content" required 
+                        <textarea id="content" name="content" required maxlength="20000" 
                                   placeholder="Write your post content here..."></textarea>
                     </div>
                     
@@ -151,22 +219,15 @@ const Views = {
     },
 
     // Post Detail View
-    getPostDetailView: (post, comments, currentUser) => {
+    getPostDetailView: (post, comments, nextCursor, currentUser) => {
         const categoriesHTML = post.categories ? post.categories.map(c => `<span class="badge">${c.name}</span>`).join(' ') : '';
         const date = new Date(post.created_at).toLocaleDateString();
 
         // Comments HTML
         let commentsHTML = '';
         if (comments && comments.length > 0) {
-            commentsHTML = comments.map(comment => `
-                <div class="comment-card">
-                    <div class="comment-header">
-                        <strong>👤 ${comment.author.username}</strong>
-                        <span class="comment-meta">• ${new Date(comment.created_at).toLocaleString()}</span>
-                    </div>
-                    <div class="comment-content">${comment.content}</div>
-                </div>
-            `).join('');
+            commentsHTML = comments.map(comment => Views.getCommentCard(comment, currentUser)).join('')
+                + Views.getLoadMoreButton(post.id, null, nextCursor, 'Load more comments');
         } else {
             commentsHTML = '<p class="no-comments">No comments yet. Be the first!</p>';
         }
@@ -177,23 +238,31 @@ const Views = {
                 <a href="#/" class="back-link">← Back to Feed</a>
                 <h1>${post.title}</h1>
                 <div class="post-meta">
-                    <span>👤 ${post.author.username}</span> • <span>📅 ${date}</span>
+                    <span>👤 ${post.author ? post.author.username : '[deleted]'}</span> • <span>📅 ${date}</span>
+                    ${Views.getHistoryButton('post', post)}
                     <div class="post-categories">${categoriesHTML}</div>
                 </div>
                 <div class="post-content">
                     ${post.content}
                 </div>
-                <div class="post-stats">
-                    <span>👍 ${post.like_count || 0}</span> • <span>👎 ${post.dislike_count || 0}</span>
+                <div class="post-stats" data-post-id="${post.id}">
+                    <button class="vote-btn" data-vote="like">👍 <span class="like-count">${post.like_count || 0}</span></button>
+                    <button class="vote-btn" data-vote="dislike">👎 <span class="dislike-count">${post.dislike_count || 0}</span></button>
                 </div>
+                ${Views.canModify(post, currentUser) ? `
+                <div class="post-actions">
+                    <button type="button" id="edit-post-btn" class="btn btn-secondary">✏️ Edit</button>
+                    <button type="button" id="delete-post-btn" class="btn btn-secondary">🗑️ Delete</button>
+                </div>` : ''}
+                <div class="revisions-panel"></div>
             </div>
 
             <div class="comments-section">
-                <h3>💬 Comments (${comments ? comments.length : 0})</h3>
+                <h3>💬 Comments (${post.comment_count || 0})</h3>
                 
                 <div class="create-comment-form">
                     <form id="create-comment-form" data-post-id="${post.id}">
-                        <textarea name="content" required placeholder="Write a comment..."></textarea>
+                        <textarea name="content" required maxlength="5000" placeholder="Write a comment..."></textarea>
                         <button type="submit" class="btn btn-primary">Post Comment</button>
                     </form>
                 </div>
@@ -206,6 +275,101 @@ const Views = {
         `;
     },
 
+    // Helper to render a comment with its loaded replies
+    getCommentCard: (comment, currentUser) => {
+        const replies = (comment.replies || []).map(reply => Views.getCommentCard(reply, currentUser)).join('');
+        const actions = Views.canModify(comment, currentUser) ? `
+                    <button type="button" class="edit-comment-btn" data-comment-id="${comment.id}">✏️ Edit</button>
+                    <button type="button" class="delete-comment-btn" data-comment-id="${comment.id}">🗑️ Delete</button>` : '';
+
+        return `
+            <div class="comment-card${comment.deleted_at ? ' deleted' : ''}" data-comment-id="${comment.id}">
+                <div class="comment-header">
+                    <strong>👤 ${comment.author ? comment.author.username : '[deleted]'}</strong>
+                    <span class="comment-meta">• ${new Date(comment.created_at).toLocaleString()}</span>
+                    ${Views.getHistoryButton('comment', comment)}
+                </div>
+                <div class="comment-content">${comment.content}</div>
+                <div class="comment-actions">
+                    ${comment.deleted_at ? '' : `<button type="button" class="reply-btn" data-comment-id="${comment.id}">↩ Reply</button>`}
+                    ${actions}
+                </div>
+                <div class="revisions-panel"></div>
+                <div class="comment-replies">
+                    ${replies}
+                    ${Views.getLoadMoreButton(comment.post_id, comment.id, comment.next_cursor, 'Load more replies')}
+                </div>
+            </div>
+        `;
+    },
+
+    // Whether the user may edit or delete a post or comment
+    canModify: (item, currentUser) => {
+        if (!currentUser || item.deleted_at) return false;
+        return currentUser.id === item.user_id || currentUser.role === 'moderator';
+    },
+
+    // Helper to render the "(edited)" marker that opens a post's or comment's history
+    getHistoryButton: (type, item) => {
+        if (item.deleted_at || new Date(item.updated_at) <= new Date(item.created_at)) return '';
+        return `<button type="button" class="history-btn" data-type="${type}" data-id="${item.id}">(edited)</button>`;
+    },
+
+    // Edit History View
+    getRevisionsView: (revisions) => {
+        const escape = (text) => text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+        const diffLine = (line) => {
+            const kind = line.startsWith('+ ') ? 'diff-add' : line.startsWith('- ') ? 'diff-del' : '';
+            return `<span class="${kind}">${escape(line)}</span>`;
+        };
+
+        return `
+            <div class="revisions-list">
+                ${revisions.map(rev => `
+                    <div class="revision">
+                        <div class="revision-meta">
+                            <strong>${rev.action}</strong> by ${rev.editor.username} • ${new Date(rev.created_at).toLocaleString()}
+                        </div>
+                        ${rev.diff ? `<pre class="revision-diff">${rev.diff.split('\n').filter(Boolean).map(diffLine).join('\n')}</pre>` : ''}
+                    </div>
+                `).join('')}
+            </div>
+        `;
+    },
+
+    // Helper to render an inline form for editing a post
+    getEditPostForm: () => `
+        <form id="edit-post-form" class="edit-form">
+            <input type="text" name="title" required maxlength="200">
+            <textarea name="content" required minlength="10" maxlength="20000"></textarea>
+            <button type="submit" class="btn btn-primary">Save</button>
+            <button type="button" class="btn btn-secondary cancel-edit-btn">Cancel</button>
+        </form>
+    `,
+
+    // Helper to render an inline form for editing a comment
+    getEditCommentForm: (commentId) => `
+        <form class="edit-comment-form edit-form" data-comment-id="${commentId}">
+            <textarea name="content" required maxlength="5000"></textarea>
+            <button type="submit" class="btn btn-primary">Save</button>
+            <button type="button" class="btn btn-secondary cancel-edit-btn">Cancel</button>
+        </form>
+    `,
+
+    // Helper to render a "load more" button for the next page of a thread
+    getLoadMoreButton: (postId, parentId, cursor, label) => {
+        if (cursor === null || cursor === undefined) return '';
+        return `<button type="button" class="load-more-btn" data-post-id="${postId}" data-parent-id="${parentId || ''}" data-after="${cursor}">${label}</button>`;
+    },
+
+    // Helper to render an inline reply form
+    getReplyForm: (postId, parentId) => `
+        <form class="reply-form" data-post-id="${postId}" data-parent-id="${parentId}">
+            <textarea name="content" required maxlength="5000" placeholder="Write a reply..."></textarea>
+            <button type="submit" class="btn btn-primary">Reply</button>
+        </form>
+    `,
+
     // Helper to render a single post card
     getPostCard: (post) => {
         const categoriesHTML = post.categories ? post.categories.map(c => `<span class="badge">${c.name}</span>`).join(' ') : '';
@@ -222,8 +386,8 @@ const Views = {
             <div class="post-preview">
                 <p>${snippet}</p>
             </div>
-            <div class="post-stats">
-                <span>👍 ${post.like_count || 0}</span> • <span>👎 ${post.dislike_count || 0}</span>
+            <div class="post-stats" data-post-id="${post.id}">
+                <span>👍 <span class="like-count">${post.like_count || 0}</span></span> • <span>👎 <span class="dislike-count">${post.dislike_count || 0}</span></span> • <span>💬 ${post.comment_count || 0}</span>
             </div>
         </div>
         `;
@@ -242,6 +406,7 @@ const Views = {
             <!-- Messages will be loaded here -->
             <p class="loading">Loading history...</p>
         </div>
+        <div id="chat-typing" class="chat-typing hidden">${username} is typing…</div>
         <div class="chat-input-area">
             <form id="chat-form">
                 <input type="text" placeholder="Type a message..." required autocomplete="off">
@@ -250,11 +415,39 @@ const Views = {
         </div>
     `,
 
+    // Sessions View: every device the user is logged in on
+    getSessionsView: (sessions) => {
+        const escape = (text) => text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+
+        return `
+            <div class="form-container sessions-container">
+                <h2>Logged-in devices</h2>
+                <div class="sessions-list">
+                    ${sessions.map(session => `
+                        <div class="session-card ${session.current ? 'current' : ''}">
+                            <div class="session-device">${escape(session.user_agent || 'Unknown device')}</div>
+                            <div class="session-meta">
+                                ${escape(session.ip_address || 'Unknown address')} •
+                                signed in ${new Date(session.created_at).toLocaleString()} •
+                                last active ${new Date(session.last_used_at).toLocaleString()}
+                            </div>
+                            ${session.current
+                                ? '<span class="session-current">This device</span>'
+                                : `<button type="button" class="btn btn-small btn-secondary revoke-session-btn" data-id="${session.id}">Log out</button>`}
+                        </div>
+                    `).join('')}
+                </div>
+                ${sessions.length > 1 ? '<button type="button" id="revoke-others-btn" class="btn btn-primary">Log out all other devices</button>' : ''}
+            </div>
+        `;
+    },
+
     // Navbar User Info
     getNavbarUserInfo: (user) => {
         if (user) {
             return `
                 <span>${user.username}</span>
+                <a href="#/sessions" class="btn btn-small btn-secondary">Devices</a>
                 <button id="btn-logout" class="btn btn-small btn-secondary">Logout</button>
             `;
         } else {
@@ -5,6 +5,7 @@ class WebSocketClient {
         this.connected = false;
         this.reconnectAttempts = 0;
         this.maxReconnectAttempts = 5;
+        this.shouldReconnect = true;
         
         // Callbacks
         this.onMessageCallback = null;
@@ -16,6 +17,7 @@ class WebSocketClient {
     // Connect to WebSocket server
     connect() {
         debugLog('Attempting to connect to WebSocket...', this.url);
+        this.shouldReconnect = true;
 
         try {
             this.ws = new WebSocket(this.url);
@@ -58,8 +60,10 @@ class WebSocketClient {
                     this.onDisconnectCallback();
                 }
 
-                // Attempt to reconnect
-                this.attemptReconnect();
+                // Attempt to reconnect unless we closed the connection ourselves
+                if (this.shouldReconnect) {
+                    this.attemptReconnect();
+                }
             };
 
         } catch (error) {
@@ -97,6 +101,7 @@ class WebSocketClient {
 
     // Disconnect
     disconnect() {
+        this.shouldReconnect = false;
         if (this.ws) {
             this.ws.close();
             this.connected = false;